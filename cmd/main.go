@@ -1,43 +1,158 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/api"
+	"github.com/SonnLarissa/wallet/pkg/query"
 	"github.com/SonnLarissa/wallet/pkg/wallet"
 )
 
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: wallet [-data dir] [-fraud rules.json] <command> [arguments]
+
+commands:
+  filter <expression>   print payments matching the expression, e.g.
+                        account = 3 and amount > 500 and category in ("food", "taxi") and status != FAIL
+  budgets <account>     print monthly budgets of the account: spent, limit and percent used
+  serve [-addr :9999]   start HTTP API
+
+environment:
+  WALLET_OTP_SECRET     secret for confirmation codes; without it pending codes from the dump do not match after restart
+
+flags:`)
+	flag.PrintDefaults()
+}
+
 func main() {
+	data := flag.String("data", "", "directory with dump files to import")
+	fraud := flag.String("fraud", "", "JSON file with fraud rules")
+	flag.Usage = usage
+	flag.Parse()
+
 	svc := &wallet.Service{}
-	account, err := svc.RegisterAccount("+992927895456")
-	if err != nil {
-		fmt.Println(err)
-		return
+	if secret := os.Getenv("WALLET_OTP_SECRET"); secret != "" {
+		svc.SetOTPSecret([]byte(secret))
+	}
+	if *data != "" {
+		err := svc.Import(*data)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *fraud != "" {
+		err := svc.LoadFraudRules(*fraud)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
-	fmt.Println(account)
 
-	account, err = svc.RegisterAccount("+992927895456")
-	if err != nil {
-		fmt.Println(err)
-		return
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "filter":
+		os.Exit(filter(svc, args[1:]))
+	case "budgets":
+		os.Exit(budgets(svc, args[1:]))
+	case "serve":
+		os.Exit(serve(svc, args[1:]))
+	default:
+		usage()
+		os.Exit(2)
 	}
-	fmt.Println(account)
+}
 
-	err = svc.Deposit(account.ID, 10)
+func filter(svc *wallet.Service, args []string) int {
+	src := strings.Join(args, " ")
+	fn, err := query.Compile(src)
 	if err != nil {
-		switch err {
-		case wallet.ErrAccountNotFound:
-			fmt.Println("not found")
-		case wallet.ErrPhoneRegistered:
-			fmt.Println("already registered")
-		case wallet.ErrAmountMustBePositive:
-			fmt.Println("must be positive")
+		fmt.Fprintln(os.Stderr, src)
+		if perr, ok := err.(*query.ParseError); ok {
+			fmt.Fprintln(os.Stderr, strings.Repeat(" ", perr.Pos-1)+"^")
 		}
-		fmt.Println(err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	payments, err := svc.FilterPaymentsByFn(fn, runtime.NumCPU())
+	if err == wallet.ErrAccountNotFound {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, payment := range payments {
+		fmt.Println(payment.ToString())
+	}
+	return 0
+}
 
+func budgets(svc *wallet.Service, args []string) int {
+	if len(args) != 1 {
+		usage()
+		return 2
+	}
+	accountID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid account:", args[0])
+		return 2
+	}
+	statuses, err := svc.Budgets(accountID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, status := range statuses {
+		fmt.Printf("%-20s %12s / %-12s %3d%%\n", status.Category, status.Spent.Decimal(), status.Limit.Decimal(), status.Percent)
 	}
-	fmt.Println(account.Balance) //10
-	svc.RegisterAccount("+992927895456")
-	svc.Deposit(1, 10)
-	svc.RegisterAccount("+992231236554")
+	return 0
+}
+
+func serve(svc *wallet.Service, args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":9999", "listen address")
+	tick := fs.Duration("tick", time.Minute, "interval of scheduled payments run")
+	_ = fs.Parse(args)
 
+	srv := api.NewServer(svc)
+	go func() {
+		for range time.Tick(*tick) {
+			srv.Do(func(svc *wallet.Service) {
+				for _, run := range svc.RunDueSchedules() {
+					if run.Err != nil {
+						log.Printf("schedule %s: %v", run.ScheduleID, run.Err)
+					}
+				}
+				svc.RejectExpiredPayments()
+				svc.ExpireHolds()
+				svc.ProcessDisputeDeadlines()
+				svc.ExpireRequests()
+				svc.AccrueInterest()
+				for _, run := range svc.RunDueInstallments() {
+					if run.Err != nil {
+						log.Printf("installment %s/%d: %v", run.PlanID, run.Number, run.Err)
+					}
+				}
+			})
+		}
+	}()
+
+	log.Printf("listening on %s", *addr)
+	err := http.ListenAndServe(*addr, srv)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,352 @@
+//Package api предоставляет HTTP API поверх wallet.Service
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SonnLarissa/wallet/pkg/query"
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/SonnLarissa/wallet/pkg/wallet"
+)
+
+var (
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrNotFound         = errors.New("not found")
+)
+
+//Server обрабатывает HTTP запросы. wallet.Service не потокобезопасен, поэтому все обращения к нему идут под мьютексом
+type Server struct {
+	mu         sync.Mutex
+	svc        *wallet.Service
+	mux        *http.ServeMux
+	goroutines int
+}
+
+func NewServer(svc *wallet.Service) *Server {
+	s := &Server{
+		svc:        svc,
+		mux:        http.NewServeMux(),
+		goroutines: runtime.NumCPU(),
+	}
+	s.mux.HandleFunc("/payments", s.handlePayments)
+	s.mux.HandleFunc("/accounts/", s.handleAccounts)
+	s.mux.HandleFunc("/reviews", s.handleReviews)
+	s.mux.HandleFunc("/reviews/", s.handleReview)
+	s.mux.HandleFunc("/disputes", s.handleDisputes)
+	s.mux.HandleFunc("/disputes/", s.handleDispute)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+//Do выполняет fn под мьютексом сервера, например для фоновых задач над тем же сервисом
+func (s *Server) Do(fn func(svc *wallet.Service)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.svc)
+}
+
+//handlePayments GET /payments?filter=<выражение> возвращает платежи, подходящие под выражение
+func (s *Server) handlePayments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+	filter := query.Filter(func(payment types.Payment) bool { return true })
+	if src := r.URL.Query().Get("filter"); src != "" {
+		var err error
+		filter, err = query.Compile(src)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	payments, err := s.svc.FilterPaymentsByFn(filter, s.goroutines)
+	s.mu.Unlock()
+	if err == wallet.ErrAccountNotFound {
+		payments, err = []types.Payment{}, nil
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, payments)
+}
+
+//handleAccounts разбирает пути вида /accounts/{id}/...
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/accounts/"), "/"), "/")
+	if len(parts) != 2 {
+		writeError(w, http.StatusNotFound, ErrNotFound)
+		return
+	}
+	accountID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrNotFound)
+		return
+	}
+	switch parts[1] {
+	case "history":
+		s.handleHistory(w, r, accountID)
+	case "budgets":
+		s.handleBudgets(w, r, accountID)
+	default:
+		writeError(w, http.StatusNotFound, ErrNotFound)
+	}
+}
+
+type historyResponse struct {
+	Payments   []types.Payment `json:"payments"`
+	Total      int             `json:"total"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+//handleHistory GET /accounts/{id}/history?sort=time|amount|id&order=asc|desc&limit=&offset=&cursor=&filter=
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request, accountID int64) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	q := wallet.HistoryQuery{
+		AccountID: accountID,
+		Sort:      wallet.SortField(params.Get("sort")),
+		Desc:      params.Get("order") == "desc",
+		Cursor:    params.Get("cursor"),
+	}
+	var err error
+	for name, dst := range map[string]*int{"limit": &q.Limit, "offset": &q.Offset} {
+		if value := params.Get(name); value != "" {
+			*dst, err = strconv.Atoi(value)
+			if err != nil || *dst < 0 {
+				writeError(w, http.StatusBadRequest, errors.New("invalid "+name))
+				return
+			}
+		}
+	}
+	if src := params.Get("filter"); src != "" {
+		filter, err := query.Compile(src)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		q.Filter = filter
+	}
+
+	s.mu.Lock()
+	page, err := s.svc.QueryHistory(q, s.goroutines)
+	s.mu.Unlock()
+	if err == wallet.ErrAccountNotFound {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, historyResponse{
+		Payments:   page.Payments,
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+	})
+}
+
+//handleBudgets GET /accounts/{id}/budgets возвращает состояние бюджетов за текущий месяц,
+//POST /accounts/{id}/budgets?category=&limit= задает бюджет категории, limit в минимальных единицах
+func (s *Server) handleBudgets(w http.ResponseWriter, r *http.Request, accountID int64) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid limit"))
+			return
+		}
+		s.mu.Lock()
+		err = s.svc.SetBudget(accountID, types.PaymentCategory(r.URL.Query().Get("category")), types.Money(limit))
+		s.mu.Unlock()
+		if err == wallet.ErrAccountNotFound {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	budgets, err := s.svc.Budgets(accountID)
+	s.mu.Unlock()
+	if err == wallet.ErrAccountNotFound {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if budgets == nil {
+		budgets = []wallet.BudgetStatus{}
+	}
+	writeJSON(w, http.StatusOK, budgets)
+}
+
+//handleReviews GET /reviews возвращает очередь платежей на ручной проверке
+func (s *Server) handleReviews(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	payments := s.svc.ReviewQueue()
+	s.mu.Unlock()
+	if payments == nil {
+		payments = []types.Payment{}
+	}
+	writeJSON(w, http.StatusOK, payments)
+}
+
+//handleReview POST /reviews/{id}/approve или POST /reviews/{id}/decline?reason=
+func (s *Server) handleReview(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/reviews/"), "/"), "/")
+	if len(parts) != 2 {
+		writeError(w, http.StatusNotFound, ErrNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+	paymentID := parts[0]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	switch parts[1] {
+	case "approve":
+		err = s.svc.ApproveReview(paymentID)
+	case "decline":
+		err = s.svc.DeclineReview(paymentID, r.URL.Query().Get("reason"))
+	default:
+		writeError(w, http.StatusNotFound, ErrNotFound)
+		return
+	}
+	switch err {
+	case nil:
+	case wallet.ErrPaymentNotFound:
+		writeError(w, http.StatusNotFound, err)
+		return
+	case wallet.ErrPaymentNotInReview:
+		writeError(w, http.StatusConflict, err)
+		return
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	payment, err := s.svc.FindPaymentByID(paymentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, payment)
+}
+
+//handleDisputes GET /disputes возвращает очередь открытых споров, ближайший срок первым
+func (s *Server) handleDisputes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	disputes := s.svc.DisputeQueue()
+	s.mu.Unlock()
+	if disputes == nil {
+		disputes = []types.Dispute{}
+	}
+	writeJSON(w, http.StatusOK, disputes)
+}
+
+//handleDispute POST /disputes/{id}/respond?response= или POST /disputes/{id}/resolve?result=won|lost
+func (s *Server) handleDispute(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/disputes/"), "/"), "/")
+	if len(parts) != 2 {
+		writeError(w, http.StatusNotFound, ErrNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+	disputeID := parts[0]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	switch parts[1] {
+	case "respond":
+		err = s.svc.RespondDispute(disputeID, r.URL.Query().Get("response"))
+	case "resolve":
+		err = s.svc.ResolveDispute(disputeID, types.DisputeStatus(strings.ToUpper(r.URL.Query().Get("result"))))
+	default:
+		writeError(w, http.StatusNotFound, ErrNotFound)
+		return
+	}
+	switch err {
+	case nil:
+	case wallet.ErrDisputeNotFound:
+		writeError(w, http.StatusNotFound, err)
+		return
+	case wallet.ErrDisputeClosed:
+		writeError(w, http.StatusConflict, err)
+		return
+	case wallet.ErrInvalidResolution:
+		writeError(w, http.StatusBadRequest, err)
+		return
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	dispute, err := s.svc.FindDisputeByID(disputeID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dispute)
+}
+
+type errorResponse struct {
+	Error    string `json:"error"`
+	Position int    `json:"position,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	res := errorResponse{Error: err.Error()}
+	if perr, ok := err.(*query.ParseError); ok {
+		res.Position = perr.Pos
+	}
+	writeJSON(w, status, res)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/SonnLarissa/wallet/pkg/wallet"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	svc := &wallet.Service{}
+	for _, phone := range []types.Phone{"+992928885522", "+992928885523"} {
+		account, err := svc.RegisterAccount(phone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = svc.Deposit(account.ID, 10_000)
+		_, _ = svc.Pay(account.ID, 100, "food")
+		_, _ = svc.Pay(account.ID, 900, "taxi")
+	}
+	srv := httptest.NewServer(NewServer(svc))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, rawurl string, v interface{}) int {
+	res, err := http.Get(rawurl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	err = json.NewDecoder(res.Body).Decode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+func TestServer_payments(t *testing.T) {
+	srv := newTestServer(t)
+	var payments []types.Payment
+	status := get(t, srv.URL+"/payments?filter="+url.QueryEscape(`account = 2 and amount > 500`), &payments)
+	if status != http.StatusOK || len(payments) != 1 || payments[0].Category != "taxi" {
+		t.Errorf("GET /payments: status = %d, payments = %v", status, payments)
+	}
+
+	status = get(t, srv.URL+"/payments?filter="+url.QueryEscape(`account = 3`), &payments)
+	if status != http.StatusOK || len(payments) != 0 {
+		t.Errorf("GET /payments: status = %d, payments = %v", status, payments)
+	}
+}
+
+func TestServer_payments_parseError(t *testing.T) {
+	srv := newTestServer(t)
+	var res errorResponse
+	status := get(t, srv.URL+"/payments?filter="+url.QueryEscape(`amount >`), &res)
+	if status != http.StatusBadRequest || res.Position != 9 || res.Error == "" {
+		t.Errorf("GET /payments: status = %d, response = %+v", status, res)
+	}
+}
+
+func TestServer_history(t *testing.T) {
+	srv := newTestServer(t)
+	var page historyResponse
+	status := get(t, srv.URL+"/accounts/1/history?sort=amount&order=desc&limit=1", &page)
+	if status != http.StatusOK || page.Total != 2 || len(page.Payments) != 1 || page.Payments[0].Amount != 900 || page.NextCursor == "" {
+		t.Errorf("GET /accounts/1/history: status = %d, page = %+v", status, page)
+		return
+	}
+	cursor := page.NextCursor
+	page = historyResponse{}
+	status = get(t, srv.URL+"/accounts/1/history?sort=amount&order=desc&limit=1&cursor="+cursor, &page)
+	if status != http.StatusOK || len(page.Payments) != 1 || page.Payments[0].Amount != 100 || page.NextCursor != "" {
+		t.Errorf("GET /accounts/1/history: status = %d, page = %+v", status, page)
+	}
+}
+
+func TestServer_history_errors(t *testing.T) {
+	srv := newTestServer(t)
+	var res errorResponse
+	for path, want := range map[string]int{
+		"/accounts/7/history":             http.StatusNotFound,
+		"/accounts/x/history":             http.StatusNotFound,
+		"/accounts/1/history?limit=-1":    http.StatusBadRequest,
+		"/accounts/1/history?cursor=zzz":  http.StatusBadRequest,
+		"/accounts/1/history?sort=weight": http.StatusBadRequest,
+	} {
+		status := get(t, srv.URL+path, &res)
+		if status != want {
+			t.Errorf("GET %s: status = %d, want %d", path, status, want)
+		}
+	}
+}
+
+func post(t *testing.T, rawurl string, v interface{}) int {
+	res, err := http.Post(rawurl, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	err = json.NewDecoder(res.Body).Decode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+func TestServer_reviews(t *testing.T) {
+	svc := &wallet.Service{}
+	account, _ := svc.RegisterAccount("+992928885522")
+	_ = svc.Deposit(account.ID, 10_000)
+	_ = svc.SetFraudRules([]wallet.FraudRule{{Name: "big", Kind: wallet.FraudAboveAverage, Action: wallet.FraudReview, Multiplier: 3}})
+	_, _ = svc.Pay(account.ID, 100, "food")
+	first, _ := svc.Pay(account.ID, 1000, "taxi")
+	second, _ := svc.Pay(account.ID, 2000, "taxi")
+	srv := httptest.NewServer(NewServer(svc))
+	defer srv.Close()
+
+	var payments []types.Payment
+	status := get(t, srv.URL+"/reviews", &payments)
+	if status != http.StatusOK || len(payments) != 2 || payments[0].ID != first.ID || payments[0].Reason != "big" {
+		t.Errorf("GET /reviews: status = %d, payments = %v", status, payments)
+	}
+
+	var payment types.Payment
+	status = post(t, srv.URL+"/reviews/"+first.ID+"/approve", &payment)
+	if status != http.StatusOK || payment.Status != types.PaymentStatusInProgress {
+		t.Errorf("POST approve: status = %d, payment = %v", status, payment)
+	}
+	status = post(t, srv.URL+"/reviews/"+second.ID+"/decline?reason=stolen", &payment)
+	if status != http.StatusOK || payment.Status != types.PaymentStatusFail || payment.Reason != "stolen" {
+		t.Errorf("POST decline: status = %d, payment = %v", status, payment)
+	}
+	if account.Balance != 10_000-100-1000 {
+		t.Errorf("balance = %d", account.Balance)
+	}
+
+	var res errorResponse
+	if status := post(t, srv.URL+"/reviews/"+first.ID+"/approve", &res); status != http.StatusConflict {
+		t.Errorf("POST approve twice: status = %d", status)
+	}
+	if status := post(t, srv.URL+"/reviews/unknown/decline", &res); status != http.StatusNotFound {
+		t.Errorf("POST unknown: status = %d", status)
+	}
+	if status := get(t, srv.URL+"/reviews/"+first.ID+"/approve", &res); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET approve: status = %d", status)
+	}
+}
+
+func TestServer_disputes(t *testing.T) {
+	svc := &wallet.Service{}
+	account, _ := svc.RegisterAccount("+992928885522")
+	_ = svc.Deposit(account.ID, 10_000)
+	first, _ := svc.Pay(account.ID, 1000, "shop")
+	second, _ := svc.Pay(account.ID, 2000, "shop")
+	firstDispute, _ := svc.OpenDispute(first.ID, "not delivered", "")
+	secondDispute, _ := svc.OpenDispute(second.ID, "not delivered", "")
+	srv := httptest.NewServer(NewServer(svc))
+	defer srv.Close()
+
+	var dispute types.Dispute
+	status := post(t, srv.URL+"/disputes/"+firstDispute.ID+"/respond?response=shipped", &dispute)
+	if status != http.StatusOK || dispute.Status != types.DisputeResponded || dispute.Response != "shipped" {
+		t.Errorf("POST respond: status = %d, dispute = %v", status, dispute)
+	}
+	var disputes []types.Dispute
+	status = get(t, srv.URL+"/disputes", &disputes)
+	if status != http.StatusOK || len(disputes) != 2 || disputes[0].ID != secondDispute.ID {
+		t.Errorf("GET /disputes: status = %d, disputes = %v", status, disputes)
+	}
+
+	status = post(t, srv.URL+"/disputes/"+firstDispute.ID+"/resolve?result=lost", &dispute)
+	if status != http.StatusOK || dispute.Status != types.DisputeLost {
+		t.Errorf("POST resolve: status = %d, dispute = %v", status, dispute)
+	}
+	var res errorResponse
+	if status := post(t, srv.URL+"/disputes/"+secondDispute.ID+"/resolve?result=maybe", &res); status != http.StatusBadRequest {
+		t.Errorf("POST resolve: status = %d", status)
+	}
+	if status := post(t, srv.URL+"/disputes/"+firstDispute.ID+"/resolve?result=won", &res); status != http.StatusConflict {
+		t.Errorf("POST resolve twice: status = %d", status)
+	}
+	if status := post(t, srv.URL+"/disputes/unknown/respond", &res); status != http.StatusNotFound {
+		t.Errorf("POST unknown: status = %d", status)
+	}
+}
+
+func TestServer_budgets(t *testing.T) {
+	srv := newTestServer(t)
+	var budgets []wallet.BudgetStatus
+	status := post(t, srv.URL+"/accounts/1/budgets?category=taxi&limit=1000", &budgets)
+	if status != http.StatusOK || len(budgets) != 1 || budgets[0].Spent != 900 || budgets[0].Percent != 90 {
+		t.Errorf("POST /accounts/1/budgets: status = %d, budgets = %v", status, budgets)
+	}
+	status = get(t, srv.URL+"/accounts/2/budgets", &budgets)
+	if status != http.StatusOK || len(budgets) != 0 {
+		t.Errorf("GET /accounts/2/budgets: status = %d, budgets = %v", status, budgets)
+	}
+
+	var res errorResponse
+	if status := post(t, srv.URL+"/accounts/1/budgets?category=taxi&limit=-5", &res); status != http.StatusBadRequest {
+		t.Errorf("POST invalid limit: status = %d", status)
+	}
+	if status := get(t, srv.URL+"/accounts/10/budgets", &res); status != http.StatusNotFound {
+		t.Errorf("GET unknown account: status = %d", status)
+	}
+}
@@ -0,0 +1,234 @@
+//Package bot реализует чат-бота для кошелька поверх messenger.Messenger
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/messenger"
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/SonnLarissa/wallet/pkg/wallet"
+)
+
+//DefaultHistorySize число платежей в ответе на /history без аргумента
+const DefaultHistorySize = 5
+
+const (
+	textStart    = "Здравствуйте! Поделитесь номером телефона, чтобы привязать кошелек."
+	textHelp     = "Команды: /balance - баланс, /history [n] - последние платежи, /pay <избранное> - оплатить избранное"
+	textUnlinked = "Сначала поделитесь номером телефона, чтобы привязать кошелек."
+	textUnknown  = "Неизвестная команда. " + textHelp
+)
+
+//Bot обрабатывает команды пользователей чата. Пользователь привязывается к счету по телефону, которым поделился в чате
+type Bot struct {
+	svc       *wallet.Service
+	messenger messenger.Messenger
+	users     map[string]int64
+	//pending избранное, ожидающее подтверждения /yes
+	pending map[string]string
+	//confirming платеж, ожидающий кода /code
+	confirming map[string]string
+}
+
+func New(svc *wallet.Service, m messenger.Messenger) *Bot {
+	return &Bot{
+		svc:        svc,
+		messenger:  m,
+		users:      map[string]int64{},
+		pending:    map[string]string{},
+		confirming: map[string]string{},
+	}
+}
+
+//Run принимает сообщения и отвечает на них, пока не отменен ctx
+func (b *Bot) Run(ctx context.Context) error {
+	for {
+		msg, err := b.messenger.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		reply := b.Handle(msg)
+		if reply == "" {
+			continue
+		}
+		err = b.messenger.Send(msg.From, reply)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//Handle обрабатывает одно сообщение и возвращает ответ
+func (b *Bot) Handle(msg messenger.Message) string {
+	if msg.Phone != "" {
+		return b.link(msg.From, types.Phone(msg.Phone))
+	}
+	fields := strings.Fields(msg.Text)
+	if len(fields) == 0 {
+		return ""
+	}
+	command, args := fields[0], fields[1:]
+	if command == "/start" || command == "/help" {
+		if _, ok := b.users[msg.From]; ok {
+			return textHelp
+		}
+		return textStart
+	}
+
+	accountID, ok := b.users[msg.From]
+	if !ok {
+		return textUnlinked
+	}
+	switch command {
+	case "/balance":
+		return b.balance(accountID)
+	case "/history":
+		return b.history(accountID, args)
+	case "/pay":
+		return b.pay(msg.From, accountID, strings.Join(args, " "))
+	case "/yes":
+		return b.confirm(msg.From)
+	case "/no":
+		delete(b.pending, msg.From)
+		return "Платеж отменен."
+	case "/code":
+		return b.code(msg.From, args)
+	}
+	return textUnknown
+}
+
+func (b *Bot) link(chat string, phone types.Phone) string {
+	account, err := b.svc.FindAccountByPhone(phone)
+	if err != nil {
+		return "Кошелек с номером " + string(phone) + " не найден."
+	}
+	b.users[chat] = account.ID
+	return "Кошелек привязан. " + textHelp
+}
+
+func (b *Bot) balance(accountID int64) string {
+	account, err := b.svc.FindAccountByID(accountID)
+	if err != nil {
+		return errorText(err)
+	}
+	text := "Баланс: " + account.Balance.Decimal()
+	if account.Held > 0 || account.CreditLimit > 0 {
+		text += ", доступно: " + account.Available().Decimal()
+	}
+	if account.Points != 0 {
+		text += fmt.Sprintf(", баллы: %d", account.Points)
+	}
+	return text
+}
+
+func (b *Bot) history(accountID int64, args []string) string {
+	limit := DefaultHistorySize
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return "Использование: /history [n]"
+		}
+		limit = n
+	}
+	page, err := b.svc.QueryHistory(wallet.HistoryQuery{
+		AccountID: accountID,
+		Sort:      wallet.SortByTime,
+		Desc:      true,
+		Limit:     limit,
+	}, 1)
+	if err != nil {
+		return errorText(err)
+	}
+	if len(page.Payments) == 0 {
+		return "Платежей пока нет."
+	}
+	lines := []string{"Последние платежи:"}
+	for _, payment := range page.Payments {
+		lines = append(lines, fmt.Sprintf("%s %s %s %s",
+			time.Unix(payment.Created, 0).UTC().Format("02.01.2006"), payment.Category, payment.Amount.Decimal(), payment.Status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (b *Bot) pay(chat string, accountID int64, name string) string {
+	if name == "" {
+		return "Использование: /pay <избранное>"
+	}
+	favorite, err := b.svc.FindFavoriteByName(accountID, name)
+	if err != nil {
+		return "Избранное «" + name + "» не найдено."
+	}
+	b.pending[chat] = favorite.ID
+	return fmt.Sprintf("Оплатить «%s» (%s) на сумму %s? /yes - да, /no - нет", favorite.Name, favorite.Category, favorite.Amount.Decimal())
+}
+
+func (b *Bot) confirm(chat string) string {
+	favoriteID, ok := b.pending[chat]
+	if !ok {
+		return "Нет платежа для подтверждения."
+	}
+	delete(b.pending, chat)
+	payment, err := b.svc.PayFromFavorite(favoriteID)
+	if err != nil {
+		return errorText(err)
+	}
+	if payment.Status == types.PaymentStatusPending {
+		b.confirming[chat] = payment.ID
+		return "Мы отправили код подтверждения. Введите /code <код>"
+	}
+	if payment.Status == types.PaymentStatusReview {
+		return "Платеж отправлен на проверку, мы сообщим о результате."
+	}
+	return b.paid(payment)
+}
+
+func (b *Bot) code(chat string, args []string) string {
+	paymentID, ok := b.confirming[chat]
+	if !ok {
+		return "Нет платежа, ожидающего код."
+	}
+	if len(args) != 1 {
+		return "Использование: /code <код>"
+	}
+	err := b.svc.ConfirmPayment(paymentID, args[0])
+	if err == wallet.ErrInvalidCode {
+		return "Неверный код, попробуйте еще раз."
+	}
+	delete(b.confirming, chat)
+	if err != nil {
+		return errorText(err)
+	}
+	payment, err := b.svc.FindPaymentByID(paymentID)
+	if err != nil {
+		return errorText(err)
+	}
+	return b.paid(payment)
+}
+
+func (b *Bot) paid(payment *types.Payment) string {
+	account, err := b.svc.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return errorText(err)
+	}
+	return fmt.Sprintf("Оплачено %s (%s). Баланс: %s", payment.Amount.Decimal(), payment.Category, account.Balance.Decimal())
+}
+
+func errorText(err error) string {
+	switch err {
+	case wallet.ErrNotEnoughBalance:
+		return "Недостаточно средств."
+	case wallet.ErrCodeExpired:
+		return "Срок действия кода истек, платеж отменен."
+	case wallet.ErrTooManyAttempts:
+		return "Слишком много попыток, платеж отменен."
+	case wallet.ErrPaymentBlocked:
+		return "Платеж заблокирован службой безопасности."
+	case wallet.ErrCreditLimitExceeded:
+		return "Превышен кредитный лимит, пополните счет."
+	}
+	return "Ошибка: " + err.Error()
+}
@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/messenger"
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/SonnLarissa/wallet/pkg/wallet"
+)
+
+func newTestBot(t *testing.T) (*Bot, *wallet.Service, *types.Account) {
+	svc := &wallet.Service{}
+	svc.SetClock(func() time.Time { return time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC) })
+	account, err := svc.RegisterAccount("+992928885522")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = svc.Deposit(account.ID, 100_00)
+	payment, _ := svc.Pay(account.ID, 15_00, "phone")
+	_, _ = svc.FavoritePayment(payment.ID, "мобильный")
+	return New(svc, messenger.NewMemory()), svc, account
+}
+
+func TestBot_Handle(t *testing.T) {
+	b, _, _ := newTestBot(t)
+	steps := []struct {
+		msg  messenger.Message
+		want string
+	}{
+		{messenger.Message{From: "1", Text: "/balance"}, textUnlinked},
+		{messenger.Message{From: "1", Phone: "+992000000000"}, "Кошелек с номером +992000000000 не найден."},
+		{messenger.Message{From: "1", Phone: "+992928885522"}, "Кошелек привязан. " + textHelp},
+		{messenger.Message{From: "1", Text: "/balance"}, "Баланс: 85.00"},
+		{messenger.Message{From: "1", Text: "/pay такси"}, "Избранное «такси» не найдено."},
+		{messenger.Message{From: "1", Text: "/pay мобильный"}, "Оплатить «мобильный» (phone) на сумму 15.00? /yes - да, /no - нет"},
+		{messenger.Message{From: "1", Text: "/no"}, "Платеж отменен."},
+		{messenger.Message{From: "1", Text: "/yes"}, "Нет платежа для подтверждения."},
+		{messenger.Message{From: "1", Text: "/pay мобильный"}, "Оплатить «мобильный» (phone) на сумму 15.00? /yes - да, /no - нет"},
+		{messenger.Message{From: "1", Text: "/yes"}, "Оплачено 15.00 (phone). Баланс: 70.00"},
+		{messenger.Message{From: "1", Text: "/history 1"}, "Последние платежи:\n01.03.2021 phone 15.00 INPROGRESS"},
+		{messenger.Message{From: "1", Text: "/history x"}, "Использование: /history [n]"},
+		{messenger.Message{From: "1", Text: "/transfer"}, textUnknown},
+		{messenger.Message{From: "2", Text: "/start"}, textStart},
+	}
+	for i, step := range steps {
+		if got := b.Handle(step.msg); got != step.want {
+			t.Errorf("Handle() step %d: got %q, want %q", i, got, step.want)
+		}
+	}
+}
+
+func TestBot_Handle_code(t *testing.T) {
+	b, svc, account := newTestBot(t)
+	m := messenger.NewMemory()
+	svc.SetMessenger(m)
+	_ = svc.SetConfirmationThreshold(account.ID, 10_00)
+	b.Handle(messenger.Message{From: "1", Phone: "+992928885522"})
+	b.Handle(messenger.Message{From: "1", Text: "/pay мобильный"})
+
+	got := b.Handle(messenger.Message{From: "1", Text: "/yes"})
+	if got != "Мы отправили код подтверждения. Введите /code <код>" {
+		t.Fatalf("Handle(/yes): got %q", got)
+	}
+	text := m.Sent()[0].Text
+	code := text[strings.LastIndex(text, " ")+1:]
+	if got := b.Handle(messenger.Message{From: "1", Text: "/code 1"}); got != "Неверный код, попробуйте еще раз." {
+		t.Errorf("Handle(/code): got %q", got)
+	}
+	if got := b.Handle(messenger.Message{From: "1", Text: "/code " + code}); got != "Оплачено 15.00 (phone). Баланс: 70.00" {
+		t.Errorf("Handle(/code): got %q", got)
+	}
+}
+
+func TestBot_Handle_fraud(t *testing.T) {
+	b, svc, _ := newTestBot(t)
+	b.Handle(messenger.Message{From: "1", Phone: "+992928885522"})
+	rules := []wallet.FraudRule{{Name: "repeats", Kind: wallet.FraudRepeats, Action: wallet.FraudReview, Count: 1, Window: wallet.Duration(time.Hour)}}
+	_ = svc.SetFraudRules(rules)
+	b.Handle(messenger.Message{From: "1", Text: "/pay мобильный"})
+	if got := b.Handle(messenger.Message{From: "1", Text: "/yes"}); got != "Платеж отправлен на проверку, мы сообщим о результате." {
+		t.Errorf("Handle(/yes): got %q", got)
+	}
+
+	rules[0].Action = wallet.FraudBlock
+	_ = svc.SetFraudRules(rules)
+	b.Handle(messenger.Message{From: "1", Text: "/pay мобильный"})
+	if got := b.Handle(messenger.Message{From: "1", Text: "/yes"}); got != "Платеж заблокирован службой безопасности." {
+		t.Errorf("Handle(/yes): got %q", got)
+	}
+}
+
+func TestBot_Handle_balanceHeld(t *testing.T) {
+	b, svc, account := newTestBot(t)
+	b.Handle(messenger.Message{From: "1", Phone: "+992928885522"})
+	_, _ = svc.Authorize(account.ID, 20_00, "hotel")
+	if got := b.Handle(messenger.Message{From: "1", Text: "/balance"}); got != "Баланс: 85.00, доступно: 65.00" {
+		t.Errorf("Handle(/balance): got %q", got)
+	}
+}
+
+func TestBot_Handle_balancePoints(t *testing.T) {
+	b, svc, account := newTestBot(t)
+	b.Handle(messenger.Message{From: "1", Phone: "+992928885522"})
+	_, _ = svc.AddCampaign(types.Campaign{Kind: types.CampaignPoints, Percent: 10})
+	payment, _ := svc.Pay(account.ID, 20_00, "taxi")
+	_ = svc.Complete(payment.ID)
+	if got := b.Handle(messenger.Message{From: "1", Text: "/balance"}); got != "Баланс: 65.00, баллы: 200" {
+		t.Errorf("Handle(/balance): got %q", got)
+	}
+}
+
+func TestBot_Run(t *testing.T) {
+	svc := &wallet.Service{}
+	_, _ = svc.RegisterAccount("+992928885522")
+	m := messenger.NewMemory()
+	b := New(svc, m)
+	m.Deliver(messenger.Message{From: "7", Phone: "+992928885522"})
+	m.Deliver(messenger.Message{From: "7", Text: "/balance"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- b.Run(ctx)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for len(m.Sent()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run(): must return context.Canceled, returned = %v", err)
+	}
+	sent := m.Sent()
+	if len(sent) != 2 || sent[1] != (messenger.Outgoing{To: "7", Text: "Баланс: 0.00"}) {
+		t.Errorf("Run(): sent %v", sent)
+	}
+}
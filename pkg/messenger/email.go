@@ -0,0 +1,98 @@
+package messenger
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+//Email реализует Messenger через SMTP. Получатель задается адресом почты или телефоном из AddressBook
+type Email struct {
+	//Addr адрес SMTP сервера host:port
+	Addr    string
+	Auth    smtp.Auth
+	From    string
+	Subject string
+	//AddressBook сопоставляет телефоны адресам почты
+	AddressBook map[string]string
+	//Limiter ограничивает частоту отправки, может быть nil
+	Limiter *Limiter
+
+	mu       sync.Mutex
+	statuses map[string]DeliveryStatus
+}
+
+func NewEmail(addr string, from string) *Email {
+	return &Email{
+		Addr:        addr,
+		From:        from,
+		Subject:     "Wallet",
+		AddressBook: map[string]string{},
+	}
+}
+
+func (e *Email) Send(to string, message string) error {
+	_, err := e.SendEmail(to, message)
+	return err
+}
+
+//SendEmail отправляет письмо и возвращает его идентификатор, по которому можно узнать статус
+func (e *Email) SendEmail(to string, message string) (string, error) {
+	rcpt := to
+	if !strings.Contains(rcpt, "@") {
+		rcpt = e.AddressBook[to]
+		if rcpt == "" {
+			return "", ErrUnknownRecipient
+		}
+	}
+	err := e.Limiter.Wait(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.New().String()
+	host := e.From[strings.LastIndex(e.From, "@")+1:]
+	data := strings.Builder{}
+	fmt.Fprintf(&data, "From: %s\r\n", e.From)
+	fmt.Fprintf(&data, "To: %s\r\n", rcpt)
+	fmt.Fprintf(&data, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", e.Subject))
+	fmt.Fprintf(&data, "Message-ID: <%s@%s>\r\n", id, host)
+	data.WriteString("MIME-Version: 1.0\r\n")
+	data.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	data.WriteString("\r\n")
+	data.WriteString(strings.ReplaceAll(message, "\n", "\r\n"))
+	data.WriteString("\r\n")
+
+	err = smtp.SendMail(e.Addr, e.Auth, e.From, []string{rcpt}, []byte(data.String()))
+	status := StatusSent
+	if err != nil {
+		status = StatusFailed
+	}
+	e.mu.Lock()
+	if e.statuses == nil {
+		e.statuses = map[string]DeliveryStatus{}
+	}
+	e.statuses[id] = status
+	e.mu.Unlock()
+	return id, err
+}
+
+//Status возвращает SENT, если SMTP сервер принял письмо, и FAILED в противном случае
+func (e *Email) Status(id string) (DeliveryStatus, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status, ok := e.statuses[id]
+	if !ok {
+		return "", ErrMessageNotFound
+	}
+	return status, nil
+}
+
+func (e *Email) Receive(ctx context.Context) (Message, error) {
+	return Message{}, ErrReceiveNotSupported
+}
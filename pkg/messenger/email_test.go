@@ -0,0 +1,145 @@
+package messenger
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//fakeSMTP минимальный SMTP сервер, который принимает письма и сохраняет их
+type fakeSMTP struct {
+	mu       sync.Mutex
+	listener net.Listener
+	mails    []string
+	rcpts    []string
+	reject   bool
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeSMTP{listener: listener}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	write := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+	write("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			write("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			write("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			f.mu.Lock()
+			reject := f.reject
+			if !reject {
+				f.rcpts = append(f.rcpts, strings.TrimSpace(line[len("RCPT TO:"):]))
+			}
+			f.mu.Unlock()
+			if reject {
+				write("550 mailbox unavailable")
+				continue
+			}
+			write("250 OK")
+		case cmd == "DATA":
+			write("354 end data with <CR><LF>.<CR><LF>")
+			data := strings.Builder{}
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			f.mu.Lock()
+			f.mails = append(f.mails, data.String())
+			f.mu.Unlock()
+			write("250 OK")
+		case cmd == "QUIT":
+			write("221 bye")
+			return
+		default:
+			write("250 OK")
+		}
+	}
+}
+
+func TestEmail_Send(t *testing.T) {
+	srv := newFakeSMTP(t)
+	email := NewEmail(srv.listener.Addr().String(), "wallet@example.com")
+	email.Subject = "Кошелек"
+	email.AddressBook["+992928885522"] = "user@example.com"
+
+	id, err := email.SendEmail("+992928885522", "Баланс 10.00")
+	if err != nil {
+		t.Fatalf("SendEmail(): error = %v", err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.mails) != 1 || len(srv.rcpts) != 1 || srv.rcpts[0] != "<user@example.com>" {
+		t.Fatalf("SendEmail(): wrong mails = %v, recipients = %v", srv.mails, srv.rcpts)
+	}
+	mail := srv.mails[0]
+	for _, part := range []string{"To: user@example.com\r\n", "Subject: =?utf-8?q?", "<" + id + "@example.com>", "\r\n\r\nБаланс 10.00\r\n"} {
+		if !strings.Contains(mail, part) {
+			t.Errorf("SendEmail(): %q not found in mail\n%s", part, mail)
+		}
+	}
+	status, err := email.Status(id)
+	if err != nil || status != StatusSent {
+		t.Errorf("Status(): status = %v, error = %v", status, err)
+	}
+}
+
+func TestEmail_Send_errors(t *testing.T) {
+	srv := newFakeSMTP(t)
+	email := NewEmail(srv.listener.Addr().String(), "wallet@example.com")
+	err := email.Send("+992928885522", "hello")
+	if err != ErrUnknownRecipient {
+		t.Errorf("Send(): must return ErrUnknownRecipient, returned = %v", err)
+	}
+
+	srv.mu.Lock()
+	srv.reject = true
+	srv.mu.Unlock()
+	id, err := email.SendEmail("user@example.com", "hello")
+	if err == nil {
+		t.Error("SendEmail(): must return error, returned nil")
+	}
+	status, _ := email.Status(id)
+	if status != StatusFailed {
+		t.Errorf("Status(): status = %v, want %v", status, StatusFailed)
+	}
+	_, err = email.Status("unknown")
+	if err != ErrMessageNotFound {
+		t.Errorf("Status(): must return ErrMessageNotFound, returned = %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package messenger
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//Limiter ограничивает частоту отправки: не больше rate сообщений за период per, равномерно по времени
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func NewLimiter(rate int, per time.Duration) *Limiter {
+	if rate < 1 {
+		rate = 1
+	}
+	return &Limiter{interval: per / time.Duration(rate)}
+}
+
+//Wait ждет, пока можно будет отправить следующее сообщение
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
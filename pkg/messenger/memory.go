@@ -0,0 +1,51 @@
+package messenger
+
+import (
+	"context"
+	"sync"
+)
+
+//Outgoing представляет сообщение, отправленное через Memory
+type Outgoing struct {
+	To   string
+	Text string
+}
+
+//Memory хранит сообщения в памяти. Используется в тестах и для локальной отладки
+type Memory struct {
+	mu    sync.Mutex
+	sent  []Outgoing
+	inbox chan Message
+}
+
+func NewMemory() *Memory {
+	return &Memory{inbox: make(chan Message, 100)}
+}
+
+func (m *Memory) Send(to string, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, Outgoing{To: to, Text: message})
+	return nil
+}
+
+func (m *Memory) Receive(ctx context.Context) (Message, error) {
+	select {
+	case msg := <-m.inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+//Deliver кладет входящее сообщение в очередь Receive
+func (m *Memory) Deliver(msg Message) {
+	m.inbox <- msg
+}
+
+//Sent возвращает копию отправленных сообщений
+func (m *Memory) Sent() []Outgoing {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Outgoing(nil), m.sent...)
+}
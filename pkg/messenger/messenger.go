@@ -1,18 +1,20 @@
 package messenger
 
-import "debug/dwarf"
+import "context"
 
-type Messenger interface {
-	Send(message string) bool
-	Receiver() (message string, ok dwarf.BoolType)
-}
-
-type Telegram struct {
+//Message представляет входящее сообщение
+type Message struct {
+	//From адрес отправителя, на который можно ответить через Send
+	From string
+	Text string
+	//Phone телефон, которым отправитель поделился в сообщении, если есть
+	Phone string
 }
 
-func (t *Telegram) Send(message string) bool {
-	return true
-}
-func (t *Telegram) Receiver() (message string, ok bool) {
-	return "", true
+//Messenger отправляет и принимает текстовые сообщения
+type Messenger interface {
+	//Send отправляет сообщение получателю to, формат адреса зависит от реализации
+	Send(to string, message string) error
+	//Receive ждет следующее входящее сообщение, пока не отменен ctx
+	Receive(ctx context.Context) (Message, error)
 }
@@ -0,0 +1,119 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+//GatewayError представляет ошибку, которую вернул SMS шлюз
+type GatewayError struct {
+	Code    int
+	Message string
+}
+
+func (e *GatewayError) Error() string {
+	return fmt.Sprintf("sms gateway: %d %s", e.Code, e.Message)
+}
+
+//SMS реализует Messenger через HTTP SMS шлюз. Адресом получателя служит номер телефона.
+//
+//Протокол шлюза:
+//	POST {Endpoint}/messages {"from": "...", "to": "...", "text": "..."} -> {"id": "...", "status": "QUEUED"}
+//	GET {Endpoint}/messages/{id} -> {"id": "...", "status": "DELIVERED"}
+//Ошибки возвращаются с кодом не 2xx и телом {"error": "..."}
+type SMS struct {
+	Endpoint string
+	APIKey   string
+	Sender   string
+	Client   *http.Client
+	//Limiter ограничивает частоту отправки, может быть nil
+	Limiter *Limiter
+}
+
+func NewSMS(endpoint string, apiKey string) *SMS {
+	return &SMS{
+		Endpoint: endpoint,
+		APIKey:   apiKey,
+		Client:   &http.Client{},
+	}
+}
+
+type smsMessage struct {
+	ID     string         `json:"id,omitempty"`
+	From   string         `json:"from,omitempty"`
+	To     string         `json:"to,omitempty"`
+	Text   string         `json:"text,omitempty"`
+	Status DeliveryStatus `json:"status,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+func (s *SMS) Send(to string, message string) error {
+	_, err := s.SendSMS(to, message)
+	return err
+}
+
+//SendSMS отправляет сообщение и возвращает его идентификатор в шлюзе
+func (s *SMS) SendSMS(to string, message string) (string, error) {
+	err := s.Limiter.Wait(context.Background())
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(smsMessage{From: s.Sender, To: to, Text: message})
+	if err != nil {
+		return "", err
+	}
+	var res smsMessage
+	err = s.do(http.MethodPost, s.Endpoint+"/messages", body, &res)
+	if err != nil {
+		return "", err
+	}
+	return res.ID, nil
+}
+
+func (s *SMS) Status(id string) (DeliveryStatus, error) {
+	var res smsMessage
+	err := s.do(http.MethodGet, s.Endpoint+"/messages/"+url.PathEscape(id), nil, &res)
+	if err != nil {
+		if gwErr, ok := err.(*GatewayError); ok && gwErr.Code == http.StatusNotFound {
+			return "", ErrMessageNotFound
+		}
+		return "", err
+	}
+	return res.Status, nil
+}
+
+func (s *SMS) Receive(ctx context.Context) (Message, error) {
+	return Message{}, ErrReceiveNotSupported
+}
+
+func (s *SMS) do(method string, endpoint string, body []byte, result *smsMessage) error {
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	err = json.NewDecoder(res.Body).Decode(result)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg := result.Error
+		if msg == "" {
+			msg = http.StatusText(res.StatusCode)
+		}
+		return &GatewayError{Code: res.StatusCode, Message: msg}
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package messenger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//fakeGateway имитирует HTTP SMS шлюз
+type fakeGateway struct {
+	mu       sync.Mutex
+	messages map[string]*smsMessage
+}
+
+func (f *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") != "Bearer key" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"invalid api key"}`)
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Path == "/messages" {
+		var msg smsMessage
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		if !strings.HasPrefix(msg.To, "+") {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"error":"invalid phone"}`)
+			return
+		}
+		msg.ID = fmt.Sprint("m", len(f.messages)+1)
+		msg.Status = StatusQueued
+		f.messages[msg.ID] = &msg
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(msg)
+		return
+	}
+	msg, ok := f.messages[strings.TrimPrefix(r.URL.Path, "/messages/")]
+	if r.Method != http.MethodGet || !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"not found"}`)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(msg)
+}
+
+func newTestSMS(t *testing.T) (*SMS, *fakeGateway) {
+	fake := &fakeGateway{messages: map[string]*smsMessage{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	sms := NewSMS(srv.URL, "key")
+	sms.Sender = "Wallet"
+	return sms, fake
+}
+
+func TestSMS_Send(t *testing.T) {
+	sms, fake := newTestSMS(t)
+	id, err := sms.SendSMS("+992928885522", "Баланс 10.00")
+	if err != nil {
+		t.Fatalf("SendSMS(): error = %v", err)
+	}
+	msg := fake.messages[id]
+	if msg == nil || msg.From != "Wallet" || msg.To != "+992928885522" || msg.Text != "Баланс 10.00" {
+		t.Fatalf("SendSMS(): wrong message sent = %+v", msg)
+	}
+
+	status, err := sms.Status(id)
+	if err != nil || status != StatusQueued {
+		t.Errorf("Status(): status = %v, error = %v", status, err)
+	}
+	fake.mu.Lock()
+	msg.Status = StatusDelivered
+	fake.mu.Unlock()
+	status, err = sms.Status(id)
+	if err != nil || status != StatusDelivered {
+		t.Errorf("Status(): status = %v, error = %v", status, err)
+	}
+	_, err = sms.Status("m100")
+	if err != ErrMessageNotFound {
+		t.Errorf("Status(): must return ErrMessageNotFound, returned = %v", err)
+	}
+}
+
+func TestSMS_Send_errors(t *testing.T) {
+	sms, _ := newTestSMS(t)
+	err := sms.Send("992928885522", "hello")
+	if gwErr, ok := err.(*GatewayError); !ok || gwErr.Code != http.StatusUnprocessableEntity || gwErr.Message != "invalid phone" {
+		t.Errorf("Send(): must return 422 GatewayError, returned = %v", err)
+	}
+	sms.APIKey = "wrong"
+	err = sms.Send("+992928885522", "hello")
+	if gwErr, ok := err.(*GatewayError); !ok || gwErr.Code != http.StatusUnauthorized {
+		t.Errorf("Send(): must return 401 GatewayError, returned = %v", err)
+	}
+}
+
+func TestSMS_Send_limiter(t *testing.T) {
+	sms, fake := newTestSMS(t)
+	sms.Limiter = NewLimiter(10, 200*time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		err := sms.Send("+992928885522", "hello")
+		if err != nil {
+			t.Fatalf("Send(): error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Send(): 6 messages sent in %v, limiter allows 1 per 20ms", elapsed)
+	}
+	if len(fake.messages) != 6 {
+		t.Errorf("Send(): sent %d messages, want 6", len(fake.messages))
+	}
+}
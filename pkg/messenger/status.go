@@ -0,0 +1,25 @@
+package messenger
+
+import "errors"
+
+var (
+	ErrReceiveNotSupported = errors.New("receive is not supported")
+	ErrMessageNotFound     = errors.New("message not found")
+	ErrUnknownRecipient    = errors.New("unknown recipient")
+)
+
+//DeliveryStatus представляет статус доставки исходящего сообщения
+type DeliveryStatus string
+
+//Предопределенные статусы доставки
+const (
+	StatusQueued    DeliveryStatus = "QUEUED"
+	StatusSent      DeliveryStatus = "SENT"
+	StatusDelivered DeliveryStatus = "DELIVERED"
+	StatusFailed    DeliveryStatus = "FAILED"
+)
+
+//StatusReporter сообщает статус доставки сообщения по идентификатору, полученному при отправке
+type StatusReporter interface {
+	Status(id string) (DeliveryStatus, error)
+}
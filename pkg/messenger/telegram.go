@@ -0,0 +1,214 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//DefaultTelegramURL адрес Telegram Bot API
+const DefaultTelegramURL = "https://api.telegram.org"
+
+//APIError представляет ошибку, которую вернул Telegram Bot API
+type APIError struct {
+	Code        int
+	Description string
+	RetryAfter  int
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram: %d %s", e.Code, e.Description)
+}
+
+func (e *APIError) temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+//Telegram реализует Messenger через Telegram Bot API. Адресом получателя служит chat_id
+type Telegram struct {
+	Token string
+	//BaseURL адрес API, по умолчанию DefaultTelegramURL
+	BaseURL string
+	Client  *http.Client
+	//Retries число повторов при сетевых ошибках, 429 и 5xx
+	Retries int
+	//Backoff пауза перед первым повтором, удваивается с каждым повтором
+	Backoff time.Duration
+	//PollTimeout время ожидания long-poll запроса getUpdates
+	PollTimeout time.Duration
+
+	offset int64
+	queue  []Message
+}
+
+func NewTelegram(token string) *Telegram {
+	return &Telegram{
+		Token:       token,
+		BaseURL:     DefaultTelegramURL,
+		Client:      &http.Client{},
+		Retries:     3,
+		Backoff:     500 * time.Millisecond,
+		PollTimeout: 30 * time.Second,
+	}
+}
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	Text string `json:"text"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	From struct {
+		ID int64 `json:"id"`
+	} `json:"from"`
+	Contact *struct {
+		PhoneNumber string `json:"phone_number"`
+		UserID      int64  `json:"user_id"`
+	} `json:"contact"`
+}
+
+func (t *Telegram) Send(to string, message string) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": to,
+		"text":    message,
+	})
+	if err != nil {
+		return err
+	}
+	return t.call(context.Background(), "sendMessage", body, nil)
+}
+
+func (t *Telegram) Receive(ctx context.Context) (Message, error) {
+	for len(t.queue) == 0 {
+		err := t.poll(ctx)
+		if err != nil {
+			return Message{}, err
+		}
+	}
+	msg := t.queue[0]
+	t.queue = t.queue[1:]
+	return msg, nil
+}
+
+//poll запрашивает новые обновления и складывает текстовые сообщения в очередь
+func (t *Telegram) poll(ctx context.Context) error {
+	body, err := json.Marshal(map[string]int64{
+		"offset":  t.offset,
+		"timeout": int64(t.PollTimeout / time.Second),
+	})
+	if err != nil {
+		return err
+	}
+	var updates []telegramUpdate
+	err = t.call(ctx, "getUpdates", body, &updates)
+	if err != nil {
+		return err
+	}
+	for _, update := range updates {
+		if update.UpdateID >= t.offset {
+			t.offset = update.UpdateID + 1
+		}
+		if update.Message == nil {
+			continue
+		}
+		msg := Message{
+			From: strconv.FormatInt(update.Message.Chat.ID, 10),
+			Text: update.Message.Text,
+		}
+		//принимаем только собственный контакт отправителя
+		if contact := update.Message.Contact; contact != nil && contact.UserID == update.Message.From.ID {
+			msg.Phone = contact.PhoneNumber
+			if !strings.HasPrefix(msg.Phone, "+") {
+				msg.Phone = "+" + msg.Phone
+			}
+		}
+		if msg.Text == "" && msg.Phone == "" {
+			continue
+		}
+		t.queue = append(t.queue, msg)
+	}
+	return nil
+}
+
+//call вызывает метод API, повторяя запрос при временных ошибках
+func (t *Telegram) call(ctx context.Context, method string, body []byte, result interface{}) error {
+	backoff := t.Backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = t.do(ctx, method, body, result)
+		if err == nil || attempt >= t.Retries || ctx.Err() != nil {
+			return err
+		}
+		wait := backoff
+		if apiErr, ok := err.(*APIError); ok {
+			if !apiErr.temporary() {
+				return err
+			}
+			wait = time.Duration(apiErr.RetryAfter) * time.Second
+		}
+		if wait < backoff {
+			wait = backoff
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (t *Telegram) do(ctx context.Context, method string, body []byte, result interface{}) error {
+	endpoint := t.BaseURL + "/bot" + url.PathEscape(t.Token) + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var tr telegramResponse
+	err = json.NewDecoder(res.Body).Decode(&tr)
+	if err != nil {
+		return &APIError{Code: res.StatusCode, Description: err.Error()}
+	}
+	if !tr.OK {
+		code := tr.ErrorCode
+		if code == 0 {
+			code = res.StatusCode
+		}
+		return &APIError{Code: code, Description: tr.Description, RetryAfter: tr.Parameters.RetryAfter}
+	}
+	if result != nil {
+		return json.Unmarshal(tr.Result, result)
+	}
+	return nil
+}
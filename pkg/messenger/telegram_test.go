@@ -0,0 +1,174 @@
+package messenger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+//fakeTelegram имитирует Telegram Bot API для одного бота
+type fakeTelegram struct {
+	mu       sync.Mutex
+	failures int
+	sent     []map[string]string
+	updates  []telegramUpdate
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path != "/bottoken/sendMessage" && r.URL.Path != "/bottoken/getUpdates" {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"ok":false,"error_code":404,"description":"Not Found"}`)
+		return
+	}
+	if f.failures > 0 {
+		f.failures--
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, `bad gateway`)
+		return
+	}
+	if r.URL.Path == "/bottoken/sendMessage" {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["chat_id"] == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+			return
+		}
+		f.sent = append(f.sent, req)
+		fmt.Fprint(w, `{"ok":true,"result":{}}`)
+		return
+	}
+	var req struct {
+		Offset int64 `json:"offset"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	var updates []telegramUpdate
+	for _, update := range f.updates {
+		if update.UpdateID >= req.Offset {
+			updates = append(updates, update)
+		}
+	}
+	result, _ := json.Marshal(updates)
+	fmt.Fprintf(w, `{"ok":true,"result":%s}`, result)
+}
+
+func (f *fakeTelegram) addUpdate(id int64, chatID int64, text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	update := telegramUpdate{UpdateID: id}
+	if text != "" {
+		update.Message = &telegramMessage{Text: text}
+		update.Message.Chat.ID = chatID
+	}
+	f.updates = append(f.updates, update)
+}
+
+func (f *fakeTelegram) addContact(id int64, chatID int64, userID int64, phone string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	update := telegramUpdate{UpdateID: id, Message: &telegramMessage{}}
+	update.Message.Chat.ID = chatID
+	update.Message.From.ID = chatID
+	update.Message.Contact = &struct {
+		PhoneNumber string `json:"phone_number"`
+		UserID      int64  `json:"user_id"`
+	}{PhoneNumber: phone, UserID: userID}
+	f.updates = append(f.updates, update)
+}
+
+func newTestTelegram(t *testing.T, fake *fakeTelegram) *Telegram {
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	tg := NewTelegram("token")
+	tg.BaseURL = srv.URL
+	tg.Backoff = time.Millisecond
+	tg.PollTimeout = 0
+	return tg
+}
+
+func TestTelegram_Send_retry(t *testing.T) {
+	fake := &fakeTelegram{failures: 2}
+	tg := newTestTelegram(t, fake)
+	err := tg.Send("42", "hello")
+	if err != nil {
+		t.Errorf("Send(): error = %v", err)
+		return
+	}
+	if len(fake.sent) != 1 || fake.sent[0]["chat_id"] != "42" || fake.sent[0]["text"] != "hello" {
+		t.Errorf("Send(): wrong messages sent = %v", fake.sent)
+	}
+}
+
+func TestTelegram_Send_fail(t *testing.T) {
+	fake := &fakeTelegram{failures: 10}
+	tg := newTestTelegram(t, fake)
+	err := tg.Send("42", "hello")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Code != http.StatusBadGateway {
+		t.Errorf("Send(): must return 502 APIError, returned = %v", err)
+	}
+	if fake.failures != 10-1-tg.Retries {
+		t.Errorf("Send(): made %d attempts, want %d", 10-fake.failures, tg.Retries+1)
+	}
+
+	fake.failures = 0
+	err = tg.Send("", "hello")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Code != http.StatusBadRequest {
+		t.Errorf("Send(): must return 400 APIError, returned = %v", err)
+	}
+}
+
+func TestTelegram_Receive(t *testing.T) {
+	fake := &fakeTelegram{failures: 1}
+	fake.addUpdate(10, 42, "/start")
+	fake.addUpdate(11, 43, "")
+	fake.addUpdate(12, 43, "/balance")
+	tg := newTestTelegram(t, fake)
+
+	for _, want := range []Message{{From: "42", Text: "/start"}, {From: "43", Text: "/balance"}} {
+		msg, err := tg.Receive(context.Background())
+		if err != nil {
+			t.Errorf("Receive(): error = %v", err)
+			return
+		}
+		if msg != want {
+			t.Errorf("Receive(): got %+v, want %+v", msg, want)
+		}
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		fake.addUpdate(13, 44, "/history")
+	}()
+	msg, err := tg.Receive(context.Background())
+	if err != nil || msg.Text != "/history" {
+		t.Errorf("Receive(): got %+v, error = %v", msg, err)
+	}
+}
+
+func TestTelegram_Receive_contact(t *testing.T) {
+	fake := &fakeTelegram{}
+	fake.addContact(1, 42, 7, "992928885500")
+	fake.addContact(2, 42, 42, "992928885522")
+	tg := newTestTelegram(t, fake)
+	msg, err := tg.Receive(context.Background())
+	if err != nil || msg != (Message{From: "42", Phone: "+992928885522"}) {
+		t.Errorf("Receive(): got %+v, error = %v", msg, err)
+	}
+}
+
+func TestTelegram_Receive_cancel(t *testing.T) {
+	tg := newTestTelegram(t, &fakeTelegram{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := tg.Receive(ctx)
+	if err == nil {
+		t.Error("Receive(): must return error, returned nil")
+	}
+}
@@ -0,0 +1,260 @@
+//Package notify отправляет клиентам сообщения о движении денег по событиям wallet.Service
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/messenger"
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/SonnLarissa/wallet/pkg/wallet"
+)
+
+//DefaultLocale язык сообщений по умолчанию
+const DefaultLocale = "ru"
+
+//QueueSize размер очереди отправки
+const QueueSize = 1000
+
+//ErrQueueFull сохраняется в недоставленном сообщении, которое не поместилось в очередь
+var ErrQueueFull = errors.New("notification queue is full")
+
+//DefaultTemplates стандартные шаблоны сообщений по языкам и типам событий
+var DefaultTemplates = map[string]map[wallet.EventType]string{
+	"ru": {
+		wallet.EventDeposit:         `Пополнение {{money .Amount}}. Баланс {{money .Balance}}`,
+		wallet.EventPayment:         `Оплата {{money .Amount}} ({{.Category}}). Баланс {{money .Balance}}`,
+		wallet.EventReject:          `Платеж {{money .Amount}} ({{.Category}}) отменен, деньги возвращены. Баланс {{money .Balance}}`,
+		wallet.EventLowBalance:      `Баланс опустился до {{money .Balance}}`,
+		wallet.EventRefund:          `Возврат {{money .Amount}} ({{.Category}}). Баланс {{money .Balance}}`,
+		wallet.EventDisputeOpened:   `Спор по платежу {{money .Amount}} ({{.Category}}) открыт, сумма временно зачислена. Баланс {{money .Balance}}`,
+		wallet.EventDisputeResolved: `Спор по платежу {{money .Amount}} ({{.Category}}) закрыт. Баланс {{money .Balance}}`,
+		wallet.EventMoneyRequested:  `{{.Counterparty}} запрашивает у вас {{money .Amount}}`,
+		wallet.EventRequestAccepted: `Запрос на {{money .Amount}} к {{.Counterparty}} оплачен. Баланс {{money .Balance}}`,
+		wallet.EventRequestDeclined: `Запрос на {{money .Amount}} к {{.Counterparty}} отклонен`,
+		wallet.EventBudgetAlert:     `Бюджет {{.Category}}: потрачено {{money .Amount}}, это {{.Threshold}}% лимита`,
+	},
+	"en": {
+		wallet.EventDeposit:         `Deposit {{money .Amount}}. Balance {{money .Balance}}`,
+		wallet.EventPayment:         `Payment {{money .Amount}} ({{.Category}}). Balance {{money .Balance}}`,
+		wallet.EventReject:          `Payment {{money .Amount}} ({{.Category}}) was rejected and refunded. Balance {{money .Balance}}`,
+		wallet.EventLowBalance:      `Your balance is low: {{money .Balance}}`,
+		wallet.EventRefund:          `Refund {{money .Amount}} ({{.Category}}). Balance {{money .Balance}}`,
+		wallet.EventDisputeOpened:   `Dispute over payment {{money .Amount}} ({{.Category}}) opened, amount provisionally credited. Balance {{money .Balance}}`,
+		wallet.EventDisputeResolved: `Dispute over payment {{money .Amount}} ({{.Category}}) closed. Balance {{money .Balance}}`,
+		wallet.EventMoneyRequested:  `{{.Counterparty}} requests {{money .Amount}} from you`,
+		wallet.EventRequestAccepted: `{{.Counterparty}} paid your request for {{money .Amount}}. Balance {{money .Balance}}`,
+		wallet.EventRequestDeclined: `{{.Counterparty}} declined your request for {{money .Amount}}`,
+		wallet.EventBudgetAlert:     `Budget {{.Category}}: spent {{money .Amount}}, {{.Threshold}}% of the limit`,
+	},
+}
+
+var funcs = template.FuncMap{
+	"money": func(m types.Money) string {
+		return m.Decimal()
+	},
+}
+
+//Delivery представляет сообщение, поставленное в очередь отправки
+type Delivery struct {
+	To       string
+	Text     string
+	Event    wallet.Event
+	Attempts int
+	Err      error
+}
+
+//Notifier рендерит события в сообщения и асинхронно отправляет их на телефон счета через Messenger.
+//Сообщения, которые не удалось отправить после всех повторов, попадают в список недоставленных
+type Notifier struct {
+	//Retries число повторов отправки
+	Retries int
+	//Backoff пауза перед первым повтором, удваивается с каждым повтором
+	Backoff time.Duration
+
+	messenger messenger.Messenger
+	mu        sync.Mutex
+	//sending защищает queue от закрытия, пока в нее пишут Notify и Redeliver
+	sending   sync.RWMutex
+	templates map[string]map[wallet.EventType]*template.Template
+	locales   map[types.Phone]string
+	dead      []Delivery
+	dropped   int
+	closed    bool
+	queue     chan Delivery
+	wg        sync.WaitGroup
+}
+
+//New создает Notifier со стандартными шаблонами и запускает workers горутин отправки
+func New(m messenger.Messenger, workers int) *Notifier {
+	n := &Notifier{
+		Retries:   3,
+		Backoff:   time.Second,
+		messenger: m,
+		templates: map[string]map[wallet.EventType]*template.Template{},
+		locales:   map[types.Phone]string{},
+		queue:     make(chan Delivery, QueueSize),
+	}
+	for locale, templates := range DefaultTemplates {
+		for eventType, src := range templates {
+			_ = n.SetTemplate(locale, eventType, src)
+		}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		n.wg.Add(1)
+		go n.worker()
+	}
+	return n
+}
+
+//SetTemplate задает шаблон сообщения для языка и типа события. В шаблоне доступны поля wallet.Event и функция money
+func (n *Notifier) SetTemplate(locale string, eventType wallet.EventType, src string) error {
+	tmpl, err := template.New(string(eventType)).Funcs(funcs).Parse(src)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.templates[locale] == nil {
+		n.templates[locale] = map[wallet.EventType]*template.Template{}
+	}
+	n.templates[locale][eventType] = tmpl
+	return nil
+}
+
+//SetLocale задает язык сообщений для телефона
+func (n *Notifier) SetLocale(phone types.Phone, locale string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.locales[phone] = locale
+}
+
+//Notify рендерит событие и ставит сообщение в очередь. Подходит для wallet.Service.Subscribe.
+//События без шаблона и события после Close пропускаются. Notify не ждет отправки: если очередь заполнена,
+//сообщение сразу попадает в недоставленные с ошибкой ErrQueueFull
+func (n *Notifier) Notify(event wallet.Event) {
+	n.sending.RLock()
+	defer n.sending.RUnlock()
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	tmpl := n.template(n.locales[event.Phone], event.Type)
+	n.mu.Unlock()
+	if tmpl == nil {
+		return
+	}
+
+	buf := bytes.Buffer{}
+	err := tmpl.Execute(&buf, event)
+	delivery := Delivery{To: string(event.Phone), Text: buf.String(), Event: event}
+	if err != nil {
+		delivery.Err = err
+		n.mu.Lock()
+		n.dead = append(n.dead, delivery)
+		n.mu.Unlock()
+		return
+	}
+	n.enqueue(delivery)
+}
+
+//enqueue ставит сообщение в очередь без ожидания. Вызывается под sending.RLock
+func (n *Notifier) enqueue(delivery Delivery) {
+	select {
+	case n.queue <- delivery:
+	default:
+		delivery.Err = ErrQueueFull
+		n.mu.Lock()
+		n.dead = append(n.dead, delivery)
+		n.dropped++
+		n.mu.Unlock()
+	}
+}
+
+func (n *Notifier) template(locale string, eventType wallet.EventType) *template.Template {
+	if tmpl := n.templates[locale][eventType]; tmpl != nil {
+		return tmpl
+	}
+	return n.templates[DefaultLocale][eventType]
+}
+
+func (n *Notifier) worker() {
+	defer n.wg.Done()
+	for delivery := range n.queue {
+		n.deliver(delivery)
+	}
+}
+
+func (n *Notifier) deliver(delivery Delivery) {
+	backoff := n.Backoff
+	for {
+		delivery.Attempts++
+		delivery.Err = n.messenger.Send(delivery.To, delivery.Text)
+		if delivery.Err == nil {
+			return
+		}
+		if delivery.Attempts > n.Retries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.dead = append(n.dead, delivery)
+}
+
+//DeadLetters возвращает сообщения, которые не удалось отправить
+func (n *Notifier) DeadLetters() []Delivery {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Delivery(nil), n.dead...)
+}
+
+//Dropped возвращает число сообщений, которые не поместились в очередь
+func (n *Notifier) Dropped() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.dropped
+}
+
+//Redeliver снова ставит недоставленные сообщения в очередь
+func (n *Notifier) Redeliver() {
+	n.sending.RLock()
+	defer n.sending.RUnlock()
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	dead := n.dead
+	n.dead = nil
+	n.mu.Unlock()
+	for _, delivery := range dead {
+		delivery.Attempts = 0
+		delivery.Err = nil
+		n.enqueue(delivery)
+	}
+}
+
+//Close перестает принимать события и ждет отправки сообщений из очереди
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	closed := n.closed
+	n.closed = true
+	n.mu.Unlock()
+	if closed {
+		return
+	}
+	n.sending.Lock()
+	close(n.queue)
+	n.sending.Unlock()
+	n.wg.Wait()
+}
@@ -0,0 +1,182 @@
+package notify
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/messenger"
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/SonnLarissa/wallet/pkg/wallet"
+)
+
+//flaky отказывает в отправке первые failures раз для каждого получателя
+type flaky struct {
+	*messenger.Memory
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+func (f *flaky) Send(to string, message string) error {
+	f.mu.Lock()
+	if f.failures[to] > 0 {
+		f.failures[to]--
+		f.mu.Unlock()
+		return errors.New("gateway unavailable")
+	}
+	f.mu.Unlock()
+	return f.Memory.Send(to, message)
+}
+
+func TestNotifier_events(t *testing.T) {
+	m := messenger.NewMemory()
+	n := New(m, 1)
+	svc := &wallet.Service{}
+	svc.Subscribe(n.Notify)
+	svc.SetLowBalanceThreshold(100_00)
+
+	ru, _ := svc.RegisterAccount("+992928885522")
+	en, _ := svc.RegisterAccount("+992928885523")
+	n.SetLocale(en.Phone, "en")
+	_ = svc.Deposit(ru.ID, 150_00)
+	payment, _ := svc.Pay(ru.ID, 60_00, "food")
+	_ = svc.Reject(payment.ID)
+	_ = svc.Deposit(en.ID, 10_00)
+	n.Close()
+
+	want := []messenger.Outgoing{
+		{To: "+992928885522", Text: "Пополнение 150.00. Баланс 150.00"},
+		{To: "+992928885522", Text: "Оплата 60.00 (food). Баланс 90.00"},
+		{To: "+992928885522", Text: "Баланс опустился до 90.00"},
+		{To: "+992928885522", Text: "Платеж 60.00 (food) отменен, деньги возвращены. Баланс 150.00"},
+		{To: "+992928885523", Text: "Deposit 10.00. Balance 10.00"},
+	}
+	got := m.Sent()
+	if len(got) != len(want) {
+		t.Fatalf("Notify(): sent %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Notify(): message %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestNotifier_requests(t *testing.T) {
+	m := messenger.NewMemory()
+	n := New(m, 1)
+	svc := &wallet.Service{}
+	svc.Subscribe(n.Notify)
+
+	author, _ := svc.RegisterAccount("+992928885522")
+	friend, _ := svc.RegisterAccount("+992928885523")
+	n.SetLocale(friend.Phone, "en")
+	_ = svc.Deposit(friend.ID, 100_00)
+	requests, _ := svc.SplitBill(author.ID, 90_00, []types.Phone{friend.Phone, "+992928885524"}, "dinner")
+	_, _ = svc.AcceptRequest(requests[0].ID, friend.ID)
+	n.Close()
+
+	want := []messenger.Outgoing{
+		{To: "+992928885523", Text: "Deposit 100.00. Balance 100.00"},
+		{To: "+992928885523", Text: "+992928885522 requests 30.00 from you"},
+		{To: "+992928885524", Text: "+992928885522 запрашивает у вас 30.00"},
+		{To: "+992928885522", Text: "Запрос на 30.00 к +992928885523 оплачен. Баланс 30.00"},
+	}
+	got := m.Sent()
+	if len(got) != len(want) {
+		t.Fatalf("Notify(): sent %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Notify(): message %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestNotifier_SetTemplate(t *testing.T) {
+	m := messenger.NewMemory()
+	n := New(m, 2)
+	err := n.SetTemplate("ru", wallet.EventDeposit, `+{{money .Amount}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.SetTemplate("ru", wallet.EventDeposit, `{{money .Amount`)
+	if err == nil {
+		t.Error("SetTemplate(): must return error, returned nil")
+	}
+	n.Notify(wallet.Event{Type: wallet.EventDeposit, Phone: "+992928885522", Amount: 5_00})
+	n.Notify(wallet.Event{Type: "unknown", Phone: "+992928885522"})
+	n.Close()
+	n.Notify(wallet.Event{Type: wallet.EventDeposit, Phone: "+992928885522", Amount: 5_00})
+
+	got := m.Sent()
+	if len(got) != 1 || got[0].Text != "+5.00" {
+		t.Errorf("Notify(): sent %v", got)
+	}
+}
+
+func TestNotifier_retry(t *testing.T) {
+	f := &flaky{Memory: messenger.NewMemory(), failures: map[string]int{"+1": 2, "+2": 10}}
+	n := New(f, 2)
+	n.Retries = 2
+	n.Backoff = time.Millisecond
+	n.Notify(wallet.Event{Type: wallet.EventDeposit, Phone: "+1", Amount: 1})
+	n.Notify(wallet.Event{Type: wallet.EventDeposit, Phone: "+2", Amount: 2})
+
+	deadline := time.Now().Add(time.Second)
+	for len(n.DeadLetters()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	dead := n.DeadLetters()
+	if len(dead) != 1 || dead[0].To != "+2" || dead[0].Attempts != 3 || dead[0].Err == nil {
+		t.Fatalf("DeadLetters(): got %+v", dead)
+	}
+
+	f.mu.Lock()
+	f.failures["+2"] = 0
+	f.mu.Unlock()
+	n.Redeliver()
+	n.Close()
+	if len(n.DeadLetters()) != 0 || len(f.Sent()) != 2 {
+		t.Errorf("Redeliver(): dead = %v, sent = %v", n.DeadLetters(), f.Sent())
+	}
+}
+
+//stuck не отвечает, пока не закрыт release
+type stuck struct {
+	*messenger.Memory
+	release chan struct{}
+}
+
+func (s *stuck) Send(to string, message string) error {
+	<-s.release
+	return s.Memory.Send(to, message)
+}
+
+func TestNotifier_Notify_queueFull(t *testing.T) {
+	m := &stuck{Memory: messenger.NewMemory(), release: make(chan struct{})}
+	n := New(m, 1)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < QueueSize+2; i++ {
+			n.Notify(wallet.Event{Type: wallet.EventDeposit, Phone: "+992928885522", Amount: 1_00})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Notify(): blocked on full queue")
+	}
+
+	dead := n.DeadLetters()
+	if n.Dropped() == 0 || len(dead) != n.Dropped() || dead[0].Err != ErrQueueFull {
+		t.Errorf("Notify(): dropped = %v, dead = %v", n.Dropped(), len(dead))
+	}
+	close(m.release)
+	n.Close()
+	if len(m.Sent())+n.Dropped() != QueueSize+2 {
+		t.Errorf("Notify(): sent = %v, dropped = %v", len(m.Sent()), n.Dropped())
+	}
+}
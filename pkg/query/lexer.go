@@ -0,0 +1,121 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of input"
+	case tokenString:
+		return fmt.Sprintf("string %q", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+//ParseError представляет ошибку разбора выражения с позицией (с 1) в исходной строке
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+func errorf(pos int, format string, args ...interface{}) *ParseError {
+	return &ParseError{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+		case r == '=':
+			tokens = append(tokens, token{kind: tokenOp, text: "=", pos: i})
+			i++
+		case r == '!' || r == '<' || r == '>':
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, token{kind: tokenOp, text: string(runes[i : i+2]), pos: i})
+				i += 2
+				continue
+			}
+			if r == '!' {
+				return nil, errorf(i, "unexpected %q, did you mean \"!=\"", r)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: string(r), pos: i})
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			text := strings.Builder{}
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, errorf(start, "unterminated string")
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					text.WriteRune(runes[i])
+					continue
+				}
+				if runes[i] == r {
+					i++
+					break
+				}
+				text.WriteRune(runes[i])
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text.String(), pos: start})
+		case unicode.IsDigit(r) || r == '-':
+			start := i
+			for i++; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+			}
+			if r == '-' && i == start+1 {
+				return nil, errorf(start, "unexpected %q", r)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i++; i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_'); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+		default:
+			return nil, errorf(i, "unexpected %q", r)
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
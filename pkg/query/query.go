@@ -0,0 +1,286 @@
+//Package query разбирает текстовые выражения для фильтрации платежей, например
+//
+//	account = 3 and amount > 500 and category in ("food", "taxi") and status != FAIL
+//
+//Поддерживаются поля id, account, amount (в минимальных единицах), category, status и created (unix-время),
+//операторы = != > >= < <=, in и not in, логические and, or, not и скобки
+package query
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+//Filter проверяет, подходит ли платеж под выражение
+type Filter func(payment types.Payment) bool
+
+type fieldKind int
+
+const (
+	numberField fieldKind = iota
+	stringField
+)
+
+var fields = map[string]fieldKind{
+	"id":       stringField,
+	"account":  numberField,
+	"amount":   numberField,
+	"category": stringField,
+	"status":   stringField,
+	"created":  numberField,
+}
+
+func numberValue(field string, payment types.Payment) int64 {
+	switch field {
+	case "account":
+		return payment.AccountID
+	case "amount":
+		return int64(payment.Amount)
+	default:
+		return payment.Created
+	}
+}
+
+func stringValue(field string, payment types.Payment) string {
+	switch field {
+	case "id":
+		return payment.ID
+	case "category":
+		return string(payment.Category)
+	default:
+		return string(payment.Status)
+	}
+}
+
+//Compile разбирает выражение и возвращает фильтр для FilterPaymentsByFn.
+//При ошибке разбора возвращается *ParseError
+func Compile(src string) (Filter, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, errorf(0, "empty expression")
+	}
+	filter, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorf(t.pos, "unexpected %v, expected \"and\", \"or\" or end of input", t)
+	}
+	return filter, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokenIdent && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (Filter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(payment types.Payment) bool {
+			return l(payment) || right(payment)
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Filter, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(payment types.Payment) bool {
+			return l(payment) && right(payment)
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (Filter, error) {
+	if p.keyword("not") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(payment types.Payment) bool {
+			return !operand(payment)
+		}, nil
+	}
+	if p.peek().kind == tokenLParen {
+		p.next()
+		filter, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenRParen {
+			return nil, errorf(t.pos, "unexpected %v, expected \")\"", t)
+		}
+		return filter, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (Filter, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return nil, errorf(t.pos, "unexpected %v, expected field name", t)
+	}
+	field := strings.ToLower(t.text)
+	kind, ok := fields[field]
+	if !ok {
+		return nil, errorf(t.pos, "unknown field %q", t.text)
+	}
+
+	negate := p.keyword("not")
+	if negate || p.peek().kind == tokenIdent && strings.EqualFold(p.peek().text, "in") {
+		if !p.keyword("in") {
+			t := p.peek()
+			return nil, errorf(t.pos, "unexpected %v, expected \"in\"", t)
+		}
+		return p.in(field, kind, negate)
+	}
+
+	op := p.next()
+	if op.kind != tokenOp {
+		return nil, errorf(op.pos, "unexpected %v, expected comparison operator", op)
+	}
+	value := p.next()
+	if kind == stringField {
+		if op.text != "=" && op.text != "!=" {
+			return nil, errorf(op.pos, "operator %q is not supported for field %q", op.text, field)
+		}
+		str, err := stringLiteral(value)
+		if err != nil {
+			return nil, err
+		}
+		equal := op.text == "="
+		return func(payment types.Payment) bool {
+			return (stringValue(field, payment) == str) == equal
+		}, nil
+	}
+
+	num, err := numberLiteral(field, value)
+	if err != nil {
+		return nil, err
+	}
+	compare := compareFunc(op.text)
+	return func(payment types.Payment) bool {
+		return compare(numberValue(field, payment), num)
+	}, nil
+}
+
+func (p *parser) in(field string, kind fieldKind, negate bool) (Filter, error) {
+	if t := p.next(); t.kind != tokenLParen {
+		return nil, errorf(t.pos, "unexpected %v, expected \"(\"", t)
+	}
+	strs := map[string]bool{}
+	nums := map[int64]bool{}
+	for {
+		value := p.next()
+		if kind == stringField {
+			str, err := stringLiteral(value)
+			if err != nil {
+				return nil, err
+			}
+			strs[str] = true
+		} else {
+			num, err := numberLiteral(field, value)
+			if err != nil {
+				return nil, err
+			}
+			nums[num] = true
+		}
+		t := p.next()
+		if t.kind == tokenRParen {
+			break
+		}
+		if t.kind != tokenComma {
+			return nil, errorf(t.pos, "unexpected %v, expected \",\" or \")\"", t)
+		}
+	}
+	return func(payment types.Payment) bool {
+		var found bool
+		if kind == stringField {
+			found = strs[stringValue(field, payment)]
+		} else {
+			found = nums[numberValue(field, payment)]
+		}
+		return found != negate
+	}, nil
+}
+
+func stringLiteral(t token) (string, error) {
+	if t.kind != tokenString && t.kind != tokenIdent && t.kind != tokenNumber {
+		return "", errorf(t.pos, "unexpected %v, expected value", t)
+	}
+	return t.text, nil
+}
+
+func numberLiteral(field string, t token) (int64, error) {
+	if t.kind != tokenNumber {
+		return 0, errorf(t.pos, "unexpected %v, field %q expects a number", t, field)
+	}
+	num, err := strconv.ParseInt(t.text, 10, 64)
+	if err != nil {
+		return 0, errorf(t.pos, "number %s is out of range", t.text)
+	}
+	return num, nil
+}
+
+func compareFunc(op string) func(a, b int64) bool {
+	switch op {
+	case "=":
+		return func(a, b int64) bool { return a == b }
+	case "!=":
+		return func(a, b int64) bool { return a != b }
+	case ">":
+		return func(a, b int64) bool { return a > b }
+	case ">=":
+		return func(a, b int64) bool { return a >= b }
+	case "<":
+		return func(a, b int64) bool { return a < b }
+	default:
+		return func(a, b int64) bool { return a <= b }
+	}
+}
@@ -0,0 +1,81 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+var testPayments = []types.Payment{
+	{ID: "a", AccountID: 3, Amount: 600, Category: "food", Status: types.PaymentStatusOk},
+	{ID: "b", AccountID: 3, Amount: 700, Category: "taxi", Status: types.PaymentStatusFail},
+	{ID: "c", AccountID: 3, Amount: 400, Category: "taxi", Status: types.PaymentStatusInProgress},
+	{ID: "d", AccountID: 4, Amount: 900, Category: "auto", Status: types.PaymentStatusOk},
+	{ID: "e", AccountID: 3, Amount: 800, Category: "auto", Status: types.PaymentStatusOk},
+}
+
+func match(t *testing.T, src string) string {
+	filter, err := Compile(src)
+	if err != nil {
+		t.Fatalf("Compile(%q): error = %v", src, err)
+	}
+	ids := ""
+	for _, payment := range testPayments {
+		if filter(payment) {
+			ids += payment.ID
+		}
+	}
+	return ids
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`account = 3 and amount > 500 and category in ("food","taxi") and status != FAIL`, "a"},
+		{`account = 4 and amount > 500 or status = FAIL`, "bd"},
+		{`account = 3 and (amount >= 800 or amount < 500)`, "ce"},
+		{`not account = 3`, "d"},
+		{`category not in ('taxi', auto)`, "a"},
+		{`account in (4, 5)`, "d"},
+		{`id = "b" OR id = 'e'`, "be"},
+		{`amount <= 600 and status = INPROGRESS`, "c"},
+	}
+	for _, tt := range tests {
+		if got := match(t, tt.src); got != tt.want {
+			t.Errorf("Compile(%q) matched %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestCompile_errors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+	}{
+		{``, 1},
+		{`amount >`, 9},
+		{`amount > "5"`, 10},
+		{`balance = 3`, 1},
+		{`category > "food"`, 10},
+		{`account = 3 and`, 16},
+		{`(account = 3`, 13},
+		{`category in ("food" "taxi")`, 21},
+		{`status = "OK`, 10},
+		{`account ! 3`, 9},
+		{`account = 3 account = 4`, 13},
+		{`status not ("OK")`, 12},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Compile(%q): must return *ParseError, returned = %v", tt.src, err)
+			continue
+		}
+		if perr.Pos != tt.pos {
+			t.Errorf("Compile(%q): error = %v, want position %d", tt.src, perr, tt.pos)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package statement
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+//DefaultHTML шаблон выписки в HTML. Блоки header и footer можно переопределить
+const DefaultHTML = `{{define "header"}}<h1>Account statement</h1>{{end}}
+{{- define "footer"}}{{end}}
+{{- define "statement"}}<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Statement {{.Account.Phone}}</title></head>
+<body>
+{{template "header" .}}
+<p>Account {{.Account.ID}} ({{.Account.Phone}}), {{date .From}} &ndash; {{date .To}}</p>
+<table>
+<tr><td>Opening balance</td><td>{{money .Opening}}</td></tr>
+<tr><td>Deposits</td><td>{{money .Deposits}}</td></tr>
+{{- if .Refunds}}
+<tr><td>Refunds</td><td>{{money .Refunds}}</td></tr>
+{{- end}}
+<tr><td>Payments</td><td>{{money .Paid}}</td></tr>
+{{- if .Fees}}
+<tr><td>Fees</td><td>{{money .Fees}}</td></tr>
+{{- end}}
+{{- if .Rewards}}
+<tr><td>Rewards</td><td>{{money .Rewards}}</td></tr>
+{{- end}}
+{{- if .Transfers}}
+<tr><td>Transfers</td><td>{{money .Transfers}}</td></tr>
+{{- end}}
+{{- if .Interest}}
+<tr><td>Overdraft interest</td><td>{{money .Interest}}</td></tr>
+{{- end}}
+{{- if .Saved}}
+<tr><td>Saved to jars</td><td>{{money .Saved}}</td></tr>
+{{- end}}
+<tr><td>Closing balance</td><td>{{money .Closing}}</td></tr>
+</table>
+{{- if .Categories}}
+<h2>Payments by category</h2>
+<table>
+<tr><th>Category</th><th>Count</th><th>Amount</th></tr>
+{{- range .Categories}}
+<tr><td>{{.Category}}</td><td>{{.Count}}</td><td>{{money .Amount}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Rejected}}
+<h2>Rejected payments</h2>
+<table>
+<tr><th>Payment</th><th>Date</th><th>Category</th><th>Amount</th></tr>
+{{- range .Rejected}}
+<tr><td>{{.ID}}</td><td>{{unix .Created}}</td><td>{{.Category}}</td><td>{{money .Amount}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Jars}}
+<h2>Jars</h2>
+<table>
+<tr><th>Jar</th><th>Balance</th><th>Goal</th></tr>
+{{- range .Jars}}
+<tr><td>{{.Name}}</td><td>{{money .Balance}}</td><td>{{if .Goal}}{{money .Goal}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{template "footer" .}}
+</body>
+</html>
+{{end}}`
+
+//DefaultText шаблон выписки в тексте фиксированной ширины. Блоки header и footer можно переопределить
+const DefaultText = `{{define "header"}}ACCOUNT STATEMENT
+{{end}}
+{{- define "footer"}}{{end}}
+{{- define "statement"}}{{template "header" .}}
+{{- left 20 "Account"}}{{right 20 (printf "%d" .Account.ID)}}
+{{left 20 "Phone"}}{{right 20 (printf "%s" .Account.Phone)}}
+{{left 20 "Period"}}{{right 20 (printf "%s-%s" (date .From) (date .To))}}
+{{line 40}}
+{{left 20 "Opening balance"}}{{right 20 (money .Opening)}}
+{{left 20 "Deposits"}}{{right 20 (money .Deposits)}}
+{{- if .Refunds}}
+{{left 20 "Refunds"}}{{right 20 (money .Refunds)}}
+{{- end}}
+{{left 20 "Payments"}}{{right 20 (money .Paid)}}
+{{- if .Fees}}
+{{left 20 "Fees"}}{{right 20 (money .Fees)}}
+{{- end}}
+{{- if .Rewards}}
+{{left 20 "Rewards"}}{{right 20 (money .Rewards)}}
+{{- end}}
+{{- if .Transfers}}
+{{left 20 "Transfers"}}{{right 20 (money .Transfers)}}
+{{- end}}
+{{- if .Interest}}
+{{left 20 "Overdraft interest"}}{{right 20 (money .Interest)}}
+{{- end}}
+{{- if .Saved}}
+{{left 20 "Saved to jars"}}{{right 20 (money .Saved)}}
+{{- end}}
+{{left 20 "Closing balance"}}{{right 20 (money .Closing)}}
+{{- if .Categories}}
+{{line 40}}
+{{left 20 "Category"}}{{right 6 "Count"}}{{right 14 "Amount"}}
+{{- range .Categories}}
+{{left 20 (printf "%s" .Category)}}{{right 6 (printf "%d" .Count)}}{{right 14 (money .Amount)}}
+{{- end}}
+{{- end}}
+{{- if .Rejected}}
+{{line 40}}
+{{left 40 "Rejected payments"}}
+{{- range .Rejected}}
+{{left 12 (unix .Created)}}{{left 14 (printf "%s" .Category)}}{{right 14 (money .Amount)}}
+{{- end}}
+{{- end}}
+{{- if .Jars}}
+{{line 40}}
+{{left 20 "Jar"}}{{right 20 "Balance / Goal"}}
+{{- range .Jars}}
+{{left 20 .Name}}{{right 20 (printf "%s / %s" (money .Balance) (money .Goal))}}
+{{- end}}
+{{- end}}
+{{line 40}}
+{{template "footer" .}}{{end}}`
+
+var funcs = map[string]interface{}{
+	"money": func(m types.Money) string {
+		return m.Decimal()
+	},
+	"date": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02")
+	},
+	"unix": func(sec int64) string {
+		return time.Unix(sec, 0).UTC().Format("2006-01-02")
+	},
+	"left": func(width int, s string) string {
+		return pad(s, width, false)
+	},
+	"right": func(width int, s string) string {
+		return pad(s, width, true)
+	},
+	"line": func(width int) string {
+		return strings.Repeat("-", width)
+	},
+}
+
+func pad(s string, width int, right bool) string {
+	r := []rune(s)
+	if len(r) >= width {
+		return string(r[:width])
+	}
+	fill := strings.Repeat(" ", width-len(r))
+	if right {
+		return fill + s
+	}
+	return s + fill
+}
+
+//Renderer выводит выписку в HTML и текст по шаблонам
+type Renderer struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+//NewRenderer создает Renderer со стандартными шаблонами
+func NewRenderer() *Renderer {
+	return &Renderer{
+		html: htmltemplate.Must(htmltemplate.New("statement.html").Funcs(funcs).Parse(DefaultHTML)),
+		text: texttemplate.Must(texttemplate.New("statement.txt").Funcs(funcs).Parse(DefaultText)),
+	}
+}
+
+//OverrideHTML дополняет HTML шаблон определениями из src: можно заменить как блоки header/footer, так и statement целиком
+func (r *Renderer) OverrideHTML(src string) error {
+	tmpl, err := r.html.Clone()
+	if err != nil {
+		return err
+	}
+	tmpl, err = tmpl.Parse(src)
+	if err != nil {
+		return err
+	}
+	r.html = tmpl
+	return nil
+}
+
+//OverrideText дополняет текстовый шаблон определениями из src
+func (r *Renderer) OverrideText(src string) error {
+	tmpl, err := r.text.Clone()
+	if err != nil {
+		return err
+	}
+	tmpl, err = tmpl.Parse(src)
+	if err != nil {
+		return err
+	}
+	r.text = tmpl
+	return nil
+}
+
+func (r *Renderer) RenderHTML(w io.Writer, summary *Summary) error {
+	return r.html.ExecuteTemplate(w, "statement", summary)
+}
+
+func (r *Renderer) RenderText(w io.Writer, summary *Summary) error {
+	return r.text.ExecuteTemplate(w, "statement", summary)
+}
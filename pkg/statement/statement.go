@@ -0,0 +1,155 @@
+package statement
+
+import (
+	"sort"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/SonnLarissa/wallet/pkg/wallet"
+)
+
+//CategoryTotal представляет сумму платежей одной категории за период
+type CategoryTotal struct {
+	Category types.PaymentCategory
+	Count    int
+	Amount   types.Money
+}
+
+//Summary представляет выписку по счету за период [From, To). Все суммы считаются по журналу движений,
+//поэтому Closing = Opening + Deposits + Refunds - Paid - Fees + Rewards + Transfers - Interest - Saved
+type Summary struct {
+	Account  types.Account
+	From     time.Time
+	To       time.Time
+	Opening  types.Money
+	Deposits types.Money
+	//Refunds возвраты и зачисления по спорам за вычетом списанных обратно
+	Refunds types.Money
+	//Paid списания по платежам за вычетом отмененных за период
+	Paid types.Money
+	Fees types.Money
+	//Rewards кэшбэк и обмен баллов за вычетом удержанного кэшбэка
+	Rewards types.Money
+	//Transfers полученные переводы за вычетом отправленных
+	Transfers types.Money
+	//Interest проценты за овердрафт
+	Interest types.Money
+	//Saved сумма, переведенная в копилки за период за вычетом снятой из них
+	Saved      types.Money
+	Categories []CategoryTotal
+	//Rejected платежи, отмененные за период
+	Rejected []types.Payment
+	Closing  types.Money
+	//Jars копилки счета на момент построения выписки
+	Jars []types.Jar
+}
+
+//Build собирает выписку по счету за период [from, to) из журнала движений.
+//Платежи нужны только для категорий и списка отмененных
+func Build(svc *wallet.Service, accountID int64, from time.Time, to time.Time) (*Summary, error) {
+	account, err := svc.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	postings, err := svc.AccountPostings(accountID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := svc.ExportAccountHistory(accountID)
+	if err != nil {
+		return nil, err
+	}
+	jars, err := svc.AccountJars(accountID)
+	if err != nil {
+		return nil, err
+	}
+	payments := map[string]types.Payment{}
+	for _, payment := range history {
+		payments[payment.ID] = payment
+	}
+
+	summary := &Summary{
+		Account: *account,
+		From:    from,
+		To:      to,
+		Opening: account.Balance,
+		Jars:    jars,
+	}
+	start, end := from.Unix(), to.Unix()
+
+	period := types.Money(0)
+	totals := map[types.PaymentCategory]*CategoryTotal{}
+	category := func(paymentID string) *CategoryTotal {
+		payment := payments[paymentID]
+		total, ok := totals[payment.Category]
+		if !ok {
+			total = &CategoryTotal{Category: payment.Category}
+			totals[payment.Category] = total
+		}
+		return total
+	}
+	rejected := map[string]int64{}
+	for _, posting := range postings {
+		if posting.Kind == types.PostingReject {
+			rejected[posting.PaymentID] = posting.Created
+		}
+		if posting.Created >= start {
+			summary.Opening -= posting.Amount
+		}
+		if posting.Created < start || posting.Created >= end {
+			continue
+		}
+		period += posting.Amount
+		switch posting.Kind {
+		case types.PostingDeposit:
+			summary.Deposits += posting.Amount
+		case types.PostingPayment:
+			summary.Paid -= posting.Amount
+			total := category(posting.PaymentID)
+			total.Count++
+			total.Amount -= posting.Amount
+		case types.PostingReject:
+			if _, err := svc.FindTransferByPaymentID(posting.PaymentID); err == nil {
+				summary.Transfers += posting.Amount
+				break
+			}
+			summary.Paid -= posting.Amount
+			total := category(posting.PaymentID)
+			total.Count--
+			total.Amount -= posting.Amount
+		case types.PostingRefund, types.PostingProvisionalCredit, types.PostingProvisionalReversal:
+			summary.Refunds += posting.Amount
+		case types.PostingFee, types.PostingFeeRefund:
+			summary.Fees -= posting.Amount
+		case types.PostingCashback, types.PostingCashbackClawback, types.PostingRedeem, types.PostingPointsClawback:
+			summary.Rewards += posting.Amount
+		case types.PostingTransferIn, types.PostingTransferOut:
+			summary.Transfers += posting.Amount
+		case types.PostingInterest:
+			summary.Interest -= posting.Amount
+		case types.PostingJarIn, types.PostingJarOut:
+			summary.Saved -= posting.Amount
+		}
+	}
+	summary.Closing = summary.Opening + period
+
+	for _, payment := range history {
+		at, ok := rejected[payment.ID]
+		if !ok && payment.Status == types.PaymentStatusFail {
+			//заблокированный антифродом платеж отклоняется при создании без движения денег
+			at, ok = payment.Created, true
+		}
+		if ok && at >= start && at < end {
+			summary.Rejected = append(summary.Rejected, payment)
+		}
+	}
+	for _, total := range totals {
+		if total.Count != 0 || total.Amount != 0 {
+			summary.Categories = append(summary.Categories, *total)
+		}
+	}
+	sort.Slice(summary.Categories, func(i, j int) bool {
+		return summary.Categories[i].Category < summary.Categories[j].Category
+	})
+	return summary, nil
+}
@@ -0,0 +1,283 @@
+package statement
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/messenger"
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/SonnLarissa/wallet/pkg/wallet"
+)
+
+func newTestSummary(t *testing.T) *Summary {
+	now := time.Date(2021, 2, 20, 12, 0, 0, 0, time.UTC)
+	svc := &wallet.Service{}
+	svc.SetClock(func() time.Time { return now })
+
+	ac, err := svc.RegisterAccount("+992928885522")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = svc.Deposit(ac.ID, 1_000_00)
+	_, _ = svc.Pay(ac.ID, 100_00, "auto")
+
+	now = time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC)
+	_ = svc.Deposit(ac.ID, 500_00)
+	_, _ = svc.Pay(ac.ID, 50_00, "food")
+	_, _ = svc.Pay(ac.ID, 25_00, "food")
+	taxi, _ := svc.Pay(ac.ID, 30_00, "taxi")
+	_ = svc.Reject(taxi.ID)
+
+	now = time.Date(2021, 4, 2, 12, 0, 0, 0, time.UTC)
+	_, _ = svc.Pay(ac.ID, 10_00, "auto")
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary, err := Build(svc, ac.ID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestBuild(t *testing.T) {
+	summary := newTestSummary(t)
+	if summary.Opening != 900_00 {
+		t.Errorf("Build(): opening = %v, want %v", summary.Opening, types.Money(900_00))
+	}
+	if summary.Deposits != 500_00 {
+		t.Errorf("Build(): deposits = %v, want %v", summary.Deposits, types.Money(500_00))
+	}
+	if summary.Closing != 1_325_00 {
+		t.Errorf("Build(): closing = %v, want %v", summary.Closing, types.Money(1_325_00))
+	}
+	if len(summary.Categories) != 1 || summary.Categories[0].Category != "food" || summary.Categories[0].Count != 2 || summary.Categories[0].Amount != 75_00 {
+		t.Errorf("Build(): wrong categories = %v", summary.Categories)
+	}
+	if len(summary.Rejected) != 1 || summary.Rejected[0].Category != "taxi" {
+		t.Errorf("Build(): wrong rejected = %v", summary.Rejected)
+	}
+}
+
+func TestBuild_refunds(t *testing.T) {
+	svc := &wallet.Service{}
+	svc.SetClock(func() time.Time { return time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC) })
+	ac, _ := svc.RegisterAccount("+992928885522")
+	_ = svc.Deposit(ac.ID, 1_000_00)
+	payment, _ := svc.Pay(ac.ID, 100_00, "food")
+	_ = svc.Complete(payment.ID)
+	_, _ = svc.Refund(payment.ID, 30_00, "damaged")
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary, err := Build(svc, ac.ID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Refunds != 30_00 || summary.Paid != 100_00 || summary.Closing != 930_00 {
+		t.Errorf("Build(): refunds = %v, paid = %v, closing = %v", summary.Refunds, summary.Paid, summary.Closing)
+	}
+	buf := &bytes.Buffer{}
+	_ = NewRenderer().RenderText(buf, summary)
+	if line := "Refunds                            30.00"; !strings.Contains(buf.String(), line) {
+		t.Errorf("RenderText(): line %q not found in\n%s", line, buf.String())
+	}
+}
+
+func TestBuild_fees(t *testing.T) {
+	svc := &wallet.Service{}
+	svc.SetClock(func() time.Time { return time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC) })
+	ac, _ := svc.RegisterAccount("+992928885522")
+	_ = svc.Deposit(ac.ID, 1_000_00)
+	_ = svc.SetFeeRules([]types.FeeRule{{Category: "transfer", Percent: 1}})
+	_, _ = svc.Pay(ac.ID, 200_00, "transfer")
+	rejected, _ := svc.Pay(ac.ID, 100_00, "transfer")
+	_ = svc.Reject(rejected.ID)
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary, err := Build(svc, ac.ID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Fees != 2_00 || summary.Closing != 798_00 {
+		t.Errorf("Build(): fees = %v, closing = %v", summary.Fees, summary.Closing)
+	}
+	buf := &bytes.Buffer{}
+	_ = NewRenderer().RenderText(buf, summary)
+	if line := "Fees                                2.00"; !strings.Contains(buf.String(), line) {
+		t.Errorf("RenderText(): line %q not found in\n%s", line, buf.String())
+	}
+}
+
+func TestBuild_jars(t *testing.T) {
+	svc := &wallet.Service{}
+	svc.SetClock(func() time.Time { return time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC) })
+	ac, _ := svc.RegisterAccount("+992928885522")
+	_ = svc.Deposit(ac.ID, 1_000_00)
+	jar, _ := svc.CreateJar(ac.ID, "vacation", 500_00, 0)
+	_ = svc.SetRoundUp(jar.ID, true)
+	_ = svc.MoveToJar(jar.ID, 100_00)
+	_, _ = svc.Pay(ac.ID, 99_40, "food")
+	_ = svc.MoveFromJar(jar.ID, 20_00)
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary, err := Build(svc, ac.ID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Saved != 80_60 || summary.Closing != 820_00 || len(summary.Jars) != 1 || summary.Jars[0].Balance != 80_60 {
+		t.Errorf("Build(): saved = %v, closing = %v, jars = %v", summary.Saved, summary.Closing, summary.Jars)
+	}
+	buf := &bytes.Buffer{}
+	_ = NewRenderer().RenderText(buf, summary)
+	for _, line := range []string{
+		"Saved to jars                      80.60",
+		"vacation                  80.60 / 500.00",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("RenderText(): line %q not found in\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestBuild_interest(t *testing.T) {
+	now := time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC)
+	svc := &wallet.Service{}
+	svc.SetClock(func() time.Time { return now })
+	ac, _ := svc.RegisterAccount("+992928885522")
+	_ = svc.SetCreditLimit(ac.ID, 500_00)
+	_, _ = svc.Pay(ac.ID, 365_00, "auto")
+	svc.SetOverdraftInterest(10)
+	now = now.AddDate(0, 0, 2)
+	svc.AccrueInterest()
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary, err := Build(svc, ac.ID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Interest != 20 || summary.Closing != -365_20 {
+		t.Errorf("Build(): interest = %v, closing = %v", summary.Interest, summary.Closing)
+	}
+	buf := &bytes.Buffer{}
+	_ = NewRenderer().RenderText(buf, summary)
+	if line := "Overdraft interest                  0.20"; !strings.Contains(buf.String(), line) {
+		t.Errorf("RenderText(): line %q not found in\n%s", line, buf.String())
+	}
+}
+
+func TestBuild_ledger(t *testing.T) {
+	now := time.Date(2021, 2, 25, 12, 0, 0, 0, time.UTC)
+	svc := &wallet.Service{}
+	svc.SetClock(func() time.Time { return now })
+	ac, _ := svc.RegisterAccount("+992928885522")
+	_ = svc.Deposit(ac.ID, 1_000_00)
+	_, _ = svc.Pay(ac.ID, 20_00, "food")
+	late, _ := svc.Pay(ac.ID, 40_00, "taxi")
+
+	now = time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC)
+	_ = svc.Reject(late.ID)
+	_ = svc.SetFraudRules([]wallet.FraudRule{{Name: "big", Kind: wallet.FraudAboveAverage, Action: wallet.FraudReview, Multiplier: 5}})
+	review, _ := svc.Pay(ac.ID, 300_00, "auto")
+	_ = svc.SetFraudRules(nil)
+	food, _ := svc.Pay(ac.ID, 100_00, "food")
+	_ = svc.Complete(food.ID)
+	_, _ = svc.Refund(food.ID, 100_00, "")
+	_, _ = svc.AddCampaign(types.Campaign{Kind: types.CampaignCashback, Percent: 10})
+	cashback, _ := svc.Pay(ac.ID, 50_00, "food")
+	_ = svc.Complete(cashback.ID)
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary, err := Build(svc, ac.ID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Status != types.PaymentStatusReview || summary.Paid != 410_00 || summary.Refunds != 100_00 || summary.Rewards != 5_00 {
+		t.Errorf("Build(): paid = %v, refunds = %v, rewards = %v", summary.Paid, summary.Refunds, summary.Rewards)
+	}
+	if len(summary.Rejected) != 1 || summary.Rejected[0].ID != late.ID {
+		t.Errorf("Build(): payment rejected in period must be listed, rejected = %v", summary.Rejected)
+	}
+	reconciled := summary.Opening + summary.Deposits + summary.Refunds - summary.Paid - summary.Fees + summary.Rewards +
+		summary.Transfers - summary.Interest - summary.Saved
+	if summary.Opening != 940_00 || summary.Closing != ac.Balance || reconciled != summary.Closing {
+		t.Errorf("Build(): opening = %v, closing = %v, balance = %v, reconciled = %v", summary.Opening, summary.Closing, ac.Balance, reconciled)
+	}
+
+	february, _ := Build(svc, ac.ID, from.AddDate(0, -1, 0), from)
+	if len(february.Rejected) != 0 || february.Paid != 60_00 {
+		t.Errorf("Build(): february rejected = %v, paid = %v", february.Rejected, february.Paid)
+	}
+}
+
+func TestBuild_transfers(t *testing.T) {
+	svc := &wallet.Service{}
+	svc.SetClock(func() time.Time { return time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC) })
+	svc.SetMessenger(messenger.NewMemory())
+	ac, _ := svc.RegisterAccount("+992928885522")
+	friend, _ := svc.RegisterAccount("+992928885533")
+	_ = svc.Deposit(ac.ID, 1_000_00)
+	_ = svc.SetConfirmationThreshold(ac.ID, 150_00)
+	_, _ = svc.Transfer(ac.ID, friend.ID, 100_00)
+	pending, _ := svc.Transfer(ac.ID, friend.ID, 200_00)
+	_ = svc.Reject(pending.ID)
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary, err := Build(svc, ac.ID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Transfers != -100_00 || summary.Paid != 0 || len(summary.Categories) != 0 || summary.Closing != 900_00 {
+		t.Errorf("Build(): transfers = %v, paid = %v, categories = %v, closing = %v", summary.Transfers, summary.Paid, summary.Categories, summary.Closing)
+	}
+	if len(summary.Rejected) != 1 || summary.Rejected[0].ID != pending.ID {
+		t.Errorf("Build(): rejected = %v", summary.Rejected)
+	}
+}
+
+func TestRenderer_RenderText(t *testing.T) {
+	summary := newTestSummary(t)
+	buf := &bytes.Buffer{}
+	err := NewRenderer().RenderText(buf, summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"Opening balance                   900.00",
+		"Closing balance                  1325.00",
+		"food                     2         75.00",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("RenderText(): line %q not found in\n%s", line, out)
+		}
+	}
+}
+
+func TestRenderer_OverrideHTML(t *testing.T) {
+	summary := newTestSummary(t)
+	r := NewRenderer()
+	err := r.OverrideHTML(`{{define "header"}}<h1>Sonn Bank</h1>{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	err = r.RenderHTML(buf, summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "<h1>Sonn Bank</h1>") || strings.Contains(out, "Account statement") {
+		t.Errorf("RenderHTML(): header not overridden\n%s", out)
+	}
+	if !strings.Contains(out, "<td>taxi</td><td>30.00</td>") {
+		t.Errorf("RenderHTML(): rejected payment not rendered\n%s", out)
+	}
+}
+
+func TestRenderer_OverrideText_error(t *testing.T) {
+	err := NewRenderer().OverrideText(`{{define "header"}}`)
+	if err == nil {
+		t.Error("OverrideText(): must return error, returned nil")
+	}
+}
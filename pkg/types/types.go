@@ -2,11 +2,39 @@ package types
 
 import (
 	"fmt"
+	"strings"
 )
 
 //Money представляет собой денежную сумму в мин единицах
 type Money int64
 
+//Decimal возвращает сумму в виде десятичной строки, например 1234 -> "12.34"
+func (m Money) Decimal() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/100, m%100)
+}
+
+//Split делит сумму на parts частей, которые отличаются не больше чем на одну минимальную единицу.
+//Остаток достается первым частям, сумма частей равна исходной
+func (m Money) Split(parts int) []Money {
+	if parts <= 0 {
+		return nil
+	}
+	result := make([]Money, parts)
+	share, rest := m/Money(parts), m%Money(parts)
+	for i := range result {
+		result[i] = share
+		if Money(i) < rest {
+			result[i]++
+		}
+	}
+	return result
+}
+
 //PaymentCategory представляет собой категорию. в которой был совершен платеж
 type PaymentCategory string
 
@@ -15,35 +43,79 @@ type PaymentStatus string
 
 //Предопределнеые статусы платежей
 const (
-	PaymentStatusOk         PaymentStatus = "OK"
-	PaymentStatusFail       PaymentStatus = "FAIL"
-	PaymentStatusInProgress PaymentStatus = "INPROGRESS"
+	PaymentStatusOk                PaymentStatus = "OK"
+	PaymentStatusFail              PaymentStatus = "FAIL"
+	PaymentStatusInProgress        PaymentStatus = "INPROGRESS"
+	PaymentStatusPending           PaymentStatus = "PENDING"
+	PaymentStatusReview            PaymentStatus = "REVIEW"
+	PaymentStatusPartiallyRefunded PaymentStatus = "PARTIALLY_REFUNDED"
+	PaymentStatusRefunded          PaymentStatus = "REFUNDED"
+	PaymentStatusChargeback        PaymentStatus = "CHARGEBACK"
 )
 
-//Payment  представляет информацию о платеже
+//Payment  представляет информацию о платеже. Refunded - сумма возвратов по платежу,
+//Fee - удержанная комиссия за вычетом возвращенной, восстанавливается из журнала движений
 type Payment struct {
 	ID        string
 	AccountID int64
 	Amount    Money
 	Category  PaymentCategory
 	Status    PaymentStatus
+	Created   int64
+	Reason    string
+	Refunded  Money
+	Fee       Money
 }
 
 func (ac *Payment) ToString() string {
-	return fmt.Sprint(ac.ID, ";", ac.AccountID, ";", ac.Amount, ";", ac.Category, ";", ac.Status)
+	str := fmt.Sprint(ac.ID, ";", ac.AccountID, ";", ac.Amount, ";", ac.Category, ";", ac.Status, ";", ac.Created)
+	if ac.Reason != "" {
+		str += ";" + ac.Reason
+	}
+	return str
 }
 
 type Phone string
 
-//Account предаствялет информацию о счете пользоватлея
+//AccountType представляет собой тип счета, от которого зависят комиссии
+type AccountType string
+
+//Предопределенные типы счетов. Пустой тип считается AccountPersonal
+const (
+	AccountPersonal AccountType = "PERSONAL"
+	AccountBusiness AccountType = "BUSINESS"
+)
+
+//Account предаствялет информацию о счете пользоватлея.
+//Balance - учетный баланс, Held - сумма, зарезервированная активными холдами, Points - баллы лояльности
 type Account struct {
 	ID      int64
 	Phone   Phone
 	Balance Money
+	Held    Money
+	Type    AccountType
+	Points  int64
+	//CreditLimit одобренный овердрафт: баланс может уйти в минус не больше чем на эту сумму
+	CreditLimit Money
+}
+
+//Available возвращает сумму, доступную для новых платежей, с учетом овердрафта
+func (ac *Account) Available() Money {
+	return ac.Balance - ac.Held + ac.CreditLimit
 }
 
 func (ac *Account) ToString() string {
-	return fmt.Sprint(ac.ID, ";", ac.Phone, ";", ac.Balance)
+	str := fmt.Sprint(ac.ID, ";", ac.Phone, ";", ac.Balance)
+	if ac.Type != "" || ac.Points != 0 || ac.CreditLimit != 0 {
+		str += ";" + string(ac.Type)
+	}
+	if ac.Points != 0 || ac.CreditLimit != 0 {
+		str += fmt.Sprint(";", ac.Points)
+	}
+	if ac.CreditLimit != 0 {
+		str += fmt.Sprint(";", ac.CreditLimit)
+	}
+	return str
 }
 
 type Favorite struct {
@@ -58,7 +130,421 @@ func (ac *Favorite) ToString() string {
 	return fmt.Sprint(ac.ID, ";", ac.AccountID, ";", ac.Name, ";", ac.Amount, ";", ac.Category)
 }
 
+//Limit представляет ограничения расходов по счету. Пустая категория означает все категории, нулевое значение - без ограничения
+type Limit struct {
+	AccountID      int64
+	Category       PaymentCategory
+	PerTransaction Money
+	Daily          Money
+	Monthly        Money
+	PerHour        int
+}
+
+//Budget представляет месячный бюджет счета на категорию. Alerted - наибольший порог в процентах,
+//о котором уже сообщено в месяце, начинающемся в Period (unix-секунды)
+type Budget struct {
+	AccountID int64
+	Category  PaymentCategory
+	Limit     Money
+	Period    int64
+	Alerted   int
+}
+
+func (ac *Budget) ToString() string {
+	return fmt.Sprint(ac.AccountID, ";", ac.Category, ";", ac.Limit, ";", ac.Period, ";", ac.Alerted)
+}
+
+//FeeRule представляет правило комиссии. Пустые Category и AccountType подходят для любых значений,
+//MinAmount и MaxAmount задают диапазон суммы платежа, MaxAmount и MaxFee, равные 0, - без ограничения.
+//Комиссия равна Flat плюс Percent процентов от суммы и ограничена снизу MinFee и сверху MaxFee
+type FeeRule struct {
+	Category    PaymentCategory
+	AccountType AccountType
+	MinAmount   Money
+	MaxAmount   Money
+	Flat        Money
+	Percent     float64
+	MinFee      Money
+	MaxFee      Money
+}
+
+//CampaignKind представляет собой вид вознаграждения в кампании лояльности
+type CampaignKind string
+
+//Предопределенные виды вознаграждений: кэшбэк зачисляется на баланс, баллы - на счет баллов
+const (
+	CampaignCashback CampaignKind = "CASHBACK"
+	CampaignPoints   CampaignKind = "POINTS"
+)
+
+//Campaign представляет кампанию лояльности. Пустая категория означает все категории.
+//Вознаграждение равно Percent процентов от платежа, но не больше MaxPerPayment и не больше MaxPerAccount за всю кампанию
+//(0 - без ограничения). Кампания действует для платежей, созданных в [Start, End), End равный 0 - бессрочно
+type Campaign struct {
+	ID            string
+	Category      PaymentCategory
+	Kind          CampaignKind
+	Percent       float64
+	MaxPerPayment Money
+	MaxPerAccount Money
+	Start         int64
+	End           int64
+}
+
+func (ac *Campaign) ToString() string {
+	return fmt.Sprint(ac.ID, ";", ac.Category, ";", ac.Kind, ";", ac.Percent, ";", ac.MaxPerPayment, ";", ac.MaxPerAccount, ";",
+		ac.Start, ";", ac.End)
+}
+
+//Reward представляет вознаграждение за платеж по кампании. Для баллов Amount - число баллов, Clawed - сколько удержано обратно
+type Reward struct {
+	ID         string
+	CampaignID string
+	PaymentID  string
+	AccountID  int64
+	Kind       CampaignKind
+	Amount     Money
+	Clawed     Money
+	Created    int64
+}
+
+func (ac *Reward) ToString() string {
+	return fmt.Sprint(ac.ID, ";", ac.CampaignID, ";", ac.PaymentID, ";", ac.AccountID, ";", ac.Kind, ";", ac.Amount, ";",
+		ac.Clawed, ";", ac.Created)
+}
+
+//VoucherKind представляет собой вид ваучера
+type VoucherKind string
+
+//Предопределенные виды ваучеров: CREDIT зачисляется на счет, DISCOUNT уменьшает сумму платежа
+const (
+	VoucherCredit   VoucherKind = "CREDIT"
+	VoucherDiscount VoucherKind = "DISCOUNT"
+)
+
+//Voucher представляет промокод. Скидка равна Value или Percent процентов от платежа.
+//MaxUses равный 0 - без ограничения числа использований, Expires равный 0 - бессрочно, пустой Categories - все категории
+type Voucher struct {
+	Code       string
+	Kind       VoucherKind
+	Value      Money
+	Percent    float64
+	MaxUses    int
+	Uses       int
+	Expires    int64
+	Categories []PaymentCategory
+	Created    int64
+}
+
+func (ac *Voucher) ToString() string {
+	categories := make([]string, len(ac.Categories))
+	for i, category := range ac.Categories {
+		categories[i] = string(category)
+	}
+	return fmt.Sprint(ac.Code, ";", ac.Kind, ";", ac.Value, ";", ac.Percent, ";", ac.MaxUses, ";", ac.Uses, ";", ac.Expires, ";",
+		strings.Join(categories, ","), ";", ac.Created)
+}
+
+//VoucherRedemption представляет использование ваучера счетом. Для скидки PaymentID - оплаченный платеж, Amount - размер скидки
+type VoucherRedemption struct {
+	Code      string
+	AccountID int64
+	PaymentID string
+	Amount    Money
+	Created   int64
+}
+
+func (ac *VoucherRedemption) ToString() string {
+	return fmt.Sprint(ac.Code, ";", ac.AccountID, ";", ac.PaymentID, ";", ac.Amount, ";", ac.Created)
+}
+
+//RequestStatus представляет собой статус запроса денег
+type RequestStatus string
+
+//Предопределенные статусы запросов денег
+const (
+	RequestPending  RequestStatus = "PENDING"
+	RequestAccepted RequestStatus = "ACCEPTED"
+	RequestDeclined RequestStatus = "DECLINED"
+	RequestExpired  RequestStatus = "EXPIRED"
+)
+
+//PaymentRequest представляет запрос денег от счета AccountID к владельцу телефона Phone.
+//Запросы одного разделенного счета имеют общий GroupID. Время хранится в unix-секундах
+type PaymentRequest struct {
+	ID        string
+	GroupID   string
+	AccountID int64
+	Phone     Phone
+	Amount    Money
+	Note      string
+	Status    RequestStatus
+	Created   int64
+	Expires   int64
+	Resolved  int64
+}
+
+func (ac *PaymentRequest) ToString() string {
+	return fmt.Sprint(ac.ID, ";", ac.GroupID, ";", ac.AccountID, ";", ac.Phone, ";", ac.Amount, ";", ac.Note, ";",
+		ac.Status, ";", ac.Created, ";", ac.Expires, ";", ac.Resolved)
+}
+
+//Transfer связывает платеж-перевод со счетом получателя ToID и запросом денег RequestID, если перевод по запросу
+type Transfer struct {
+	PaymentID string
+	ToID      int64
+	RequestID string
+}
+
+func (ac *Transfer) ToString() string {
+	return fmt.Sprint(ac.PaymentID, ";", ac.ToID, ";", ac.RequestID)
+}
+
+//Jar представляет копилку внутри счета. Деньги в копилке не входят в баланс счета.
+//Goal и Deadline равные 0 - без цели и срока, RoundUp - копилка получает округление платежей
+type Jar struct {
+	ID        string
+	AccountID int64
+	Name      string
+	Goal      Money
+	Deadline  int64
+	Balance   Money
+	RoundUp   bool
+	Created   int64
+}
+
+func (ac *Jar) ToString() string {
+	return fmt.Sprint(ac.ID, ";", ac.AccountID, ";", ac.Name, ";", ac.Goal, ";", ac.Deadline, ";", ac.Balance, ";",
+		ac.RoundUp, ";", ac.Created)
+}
+
+//RoundUp представляет округление платежа PaymentID, переведенное в копилку JarID
+type RoundUp struct {
+	PaymentID string
+	JarID     string
+	Amount    Money
+}
+
+func (ac *RoundUp) ToString() string {
+	return fmt.Sprint(ac.PaymentID, ";", ac.JarID, ";", ac.Amount)
+}
+
+//PlanStatus представляет собой статус плана рассрочки
+type PlanStatus string
+
+//Предопределенные статусы планов рассрочки. OVERDUE - есть неоплаченный платеж с наступившим сроком
+const (
+	PlanActive  PlanStatus = "ACTIVE"
+	PlanOverdue PlanStatus = "OVERDUE"
+	PlanPaid    PlanStatus = "PAID"
+)
+
+//InstallmentPlan представляет покупку в рассрочку на Parts ежемесячных платежей.
+//LateFee - штраф, который добавляется к просроченному платежу
+type InstallmentPlan struct {
+	ID        string
+	AccountID int64
+	Category  PaymentCategory
+	Total     Money
+	Parts     int
+	LateFee   Money
+	Status    PlanStatus
+	Created   int64
+}
+
+func (ac *InstallmentPlan) ToString() string {
+	return fmt.Sprint(ac.ID, ";", ac.AccountID, ";", ac.Category, ";", ac.Total, ";", ac.Parts, ";", ac.LateFee, ";",
+		ac.Status, ";", ac.Created)
+}
+
+//Installment представляет платеж по плану рассрочки. Number начинается с 1, PaymentID заполняется при создании платежа,
+//Paid - когда платеж прошел. LateFee - начисленный за просрочку штраф, LastRun, LastError и Failures - последняя
+//неудачная попытка оплаты и число неудачных попыток
+type Installment struct {
+	PlanID    string
+	Number    int
+	Amount    Money
+	Due       int64
+	LateFee   Money
+	PaymentID string
+	Paid      int64
+	LastRun   int64
+	LastError string
+	Failures  int
+}
+
+func (ac *Installment) ToString() string {
+	return fmt.Sprint(ac.PlanID, ";", ac.Number, ";", ac.Amount, ";", ac.Due, ";", ac.LateFee, ";", ac.PaymentID, ";", ac.Paid, ";",
+		ac.LastRun, ";", ac.LastError, ";", ac.Failures)
+}
+
+//ScheduleKind представляет периодичность платежа по расписанию
+type ScheduleKind string
+
+//Предопределенные периодичности
+const (
+	ScheduleOnce    ScheduleKind = "ONCE"
+	ScheduleDaily   ScheduleKind = "DAILY"
+	ScheduleWeekly  ScheduleKind = "WEEKLY"
+	ScheduleMonthly ScheduleKind = "MONTHLY"
+	ScheduleCron    ScheduleKind = "CRON"
+)
+
+//Schedule представляет расписание платежа из избранного. Время хранится в unix-секундах, Next равен 0, когда платежей больше не будет
+type Schedule struct {
+	ID            string
+	FavoriteID    string
+	Kind          ScheduleKind
+	Spec          string
+	Start         int64
+	Next          int64
+	LastRun       int64
+	LastPaymentID string
+	LastError     string
+	Failures      int
+}
+
+func (ac *Schedule) ToString() string {
+	return fmt.Sprint(ac.ID, ";", ac.FavoriteID, ";", ac.Kind, ";", ac.Spec, ";", ac.Start, ";", ac.Next, ";",
+		ac.LastRun, ";", ac.LastPaymentID, ";", ac.LastError, ";", ac.Failures)
+}
+
+//Refund представляет возврат части или всей суммы платежа
+type Refund struct {
+	ID        string
+	PaymentID string
+	AccountID int64
+	Amount    Money
+	Reason    string
+	Created   int64
+}
+
+func (ac *Refund) ToString() string {
+	return fmt.Sprint(ac.ID, ";", ac.PaymentID, ";", ac.AccountID, ";", ac.Amount, ";", ac.Reason, ";", ac.Created)
+}
+
+//DisputeStatus представляет собой статус спора по платежу
+type DisputeStatus string
+
+//Предопределенные статусы споров. OPEN - ждет ответа продавца, RESPONDED - ждет решения поддержки,
+//WON - спор выигран клиентом, LOST - проигран
+const (
+	DisputeOpen      DisputeStatus = "OPEN"
+	DisputeResponded DisputeStatus = "RESPONDED"
+	DisputeWon       DisputeStatus = "WON"
+	DisputeLost      DisputeStatus = "LOST"
+)
+
+//Dispute представляет спор клиента по платежу. Время хранится в unix-секундах
+type Dispute struct {
+	ID        string
+	PaymentID string
+	AccountID int64
+	Amount    Money
+	Reason    string
+	Evidence  string
+	Response  string
+	Status    DisputeStatus
+	Created   int64
+	RespondBy int64
+	ResolveBy int64
+	Resolved  int64
+}
+
+func (ac *Dispute) ToString() string {
+	return fmt.Sprint(ac.ID, ";", ac.PaymentID, ";", ac.AccountID, ";", ac.Amount, ";", ac.Reason, ";", ac.Evidence, ";",
+		ac.Response, ";", ac.Status, ";", ac.Created, ";", ac.RespondBy, ";", ac.ResolveBy, ";", ac.Resolved)
+}
+
+//HoldStatus представляет собой статус холда
+type HoldStatus string
+
+//Предопределенные статусы холдов
+const (
+	HoldActive   HoldStatus = "ACTIVE"
+	HoldCaptured HoldStatus = "CAPTURED"
+	HoldVoided   HoldStatus = "VOIDED"
+	HoldExpired  HoldStatus = "EXPIRED"
+)
+
+//Hold представляет резервирование средств до списания. Captured - списанная сумма, PaymentID - платеж, созданный при списании,
+//Fee - комиссия, зарезервированная вместе с суммой
+type Hold struct {
+	ID        string
+	AccountID int64
+	Amount    Money
+	Category  PaymentCategory
+	Status    HoldStatus
+	Created   int64
+	Expires   int64
+	Captured  Money
+	PaymentID string
+	Fee       Money
+}
+
+func (ac *Hold) ToString() string {
+	return fmt.Sprint(ac.ID, ";", ac.AccountID, ";", ac.Amount, ";", ac.Category, ";", ac.Status, ";", ac.Created, ";",
+		ac.Expires, ";", ac.Captured, ";", ac.PaymentID, ";", ac.Fee)
+}
+
+//PostingKind представляет собой вид движения по счету
+type PostingKind string
+
+//Предопределенные виды движений
+const (
+	PostingDeposit PostingKind = "DEPOSIT"
+	PostingPayment PostingKind = "PAYMENT"
+	PostingReject  PostingKind = "REJECT"
+	PostingRefund  PostingKind = "REFUND"
+	//PostingProvisionalCredit - временное зачисление на время спора, PostingProvisionalReversal - его списание при проигрыше спора
+	PostingProvisionalCredit   PostingKind = "PROVISIONAL_CREDIT"
+	PostingProvisionalReversal PostingKind = "PROVISIONAL_REVERSAL"
+	PostingFee                 PostingKind = "FEE"
+	PostingFeeRefund           PostingKind = "FEE_REFUND"
+	PostingCashback            PostingKind = "CASHBACK"
+	PostingCashbackClawback    PostingKind = "CASHBACK_CLAWBACK"
+	PostingRedeem              PostingKind = "REDEEM"
+	//PostingPointsClawback - удержание со счета баллов, которые уже обменяны на деньги
+	PostingPointsClawback PostingKind = "POINTS_CLAWBACK"
+	//PostingTransferOut и PostingTransferIn - списание и зачисление при переводе между счетами
+	PostingTransferOut PostingKind = "TRANSFER_OUT"
+	PostingTransferIn  PostingKind = "TRANSFER_IN"
+	//PostingJarIn - перевод в копилку, PostingJarOut - из копилки на счет
+	PostingJarIn  PostingKind = "JAR_IN"
+	PostingJarOut PostingKind = "JAR_OUT"
+	//PostingInterest - проценты за овердрафт
+	PostingInterest PostingKind = "INTEREST"
+)
+
+//Posting представляет движение денег по счету: положительная сумма зачисляется, отрицательная списывается
+type Posting struct {
+	ID        string
+	AccountID int64
+	Amount    Money
+	Kind      PostingKind
+	PaymentID string
+	Created   int64
+}
+
+func (ac *Posting) ToString() string {
+	return fmt.Sprint(ac.ID, ";", ac.AccountID, ";", ac.Amount, ";", ac.Kind, ";", ac.PaymentID, ";", ac.Created)
+}
+
+//Progress представляет прогресс длительной операции
 type Progress struct {
-	Part   int
+	//Part число записей в обработанном куске
+	Part int
+	//Result сумма по обработанному куску
 	Result Money
+	//Done и Parts число обработанных кусков и всего кусков
+	Done  int
+	Parts int
+	//Total нарастающий итог по всем обработанным кускам
+	Total Money
+	//Percent процент выполнения
+	Percent int
+	//Payments найденные в куске платежи
+	Payments []Payment
+	//Err ошибка, на которой операция прервалась
+	Err error
 }
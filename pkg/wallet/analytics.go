@@ -0,0 +1,158 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+//KeyFunc возвращает ключ группы, к которой относится платеж
+type KeyFunc func(payment types.Payment) string
+
+//Aggregate представляет статистику по группе платежей
+type Aggregate struct {
+	Key    string
+	Count  int
+	Sum    types.Money
+	Avg    types.Money
+	Median types.Money
+	P95    types.Money
+}
+
+//ByCategory группирует платежи по категории
+func ByCategory(payment types.Payment) string {
+	return string(payment.Category)
+}
+
+//ByAccount группирует платежи по счету
+func ByAccount(payment types.Payment) string {
+	return fmt.Sprint(payment.AccountID)
+}
+
+//ByStatus группирует платежи по статусу
+func ByStatus(payment types.Payment) string {
+	return string(payment.Status)
+}
+
+//ByTime группирует платежи по интервалам времени длиной bucket, ключ - начало интервала в UTC
+func ByTime(bucket time.Duration) KeyFunc {
+	return func(payment types.Payment) string {
+		return time.Unix(payment.Created, 0).UTC().Truncate(bucket).Format(time.RFC3339)
+	}
+}
+
+//Spending отбирает принятые платежи: в статусах OK и INPROGRESS, в том числе частично возвращенные.
+//Платежи, ожидающие подтверждения или проверки, отклоненные и полностью возвращенные не учитываются
+func Spending(payment types.Payment) bool {
+	switch payment.Status {
+	case types.PaymentStatusOk, types.PaymentStatusInProgress, types.PaymentStatusPartiallyRefunded:
+		return true
+	}
+	return false
+}
+
+//Spent возвращает сумму платежа за вычетом возвратов
+func Spent(payment types.Payment) types.Money {
+	return payment.Amount - payment.Refunded
+}
+
+//GroupPayments параллельно группирует платежи, прошедшие filter, по ключу key и считает статистику по каждой группе.
+//Если filter равен nil, учитываются все платежи. Группы отсортированы по ключу
+func (s *Service) GroupPayments(key KeyFunc, filter func(payment types.Payment) bool, goroutines int) []Aggregate {
+	return s.group(key, filter, func(payment types.Payment) types.Money {
+		return payment.Amount
+	}, goroutines)
+}
+
+//group группирует платежи и считает статистику по значению value
+func (s *Service) group(key KeyFunc, filter func(payment types.Payment) bool, value func(payment types.Payment) types.Money, goroutines int) []Aggregate {
+	mapFn := func(payments []*types.Payment) interface{} {
+		groups := map[string][]types.Money{}
+		for _, payment := range payments {
+			if payment == nil {
+				continue
+			}
+			if filter != nil && !filter(*payment) {
+				continue
+			}
+			k := key(*payment)
+			groups[k] = append(groups[k], value(*payment))
+		}
+		return groups
+	}
+	reduceFn := func(acc interface{}, part interface{}) interface{} {
+		groups := acc.(map[string][]types.Money)
+		for k, amounts := range part.(map[string][]types.Money) {
+			groups[k] = append(groups[k], amounts...)
+		}
+		return groups
+	}
+	res, _ := s.MapReduce(context.Background(), ScanOptions{Workers: goroutines}, mapFn, reduceFn, map[string][]types.Money{})
+
+	var aggregates []Aggregate
+	for k, amounts := range res.(map[string][]types.Money) {
+		aggregates = append(aggregates, aggregate(k, amounts))
+	}
+	sort.Slice(aggregates, func(i, j int) bool {
+		return aggregates[i].Key < aggregates[j].Key
+	})
+	return aggregates
+}
+
+//TopAccounts возвращает n счетов с наибольшими расходами: суммой принятых платежей за вычетом возвратов
+func (s *Service) TopAccounts(n int, goroutines int) []Aggregate {
+	return top(s.group(ByAccount, Spending, Spent, goroutines), n)
+}
+
+//TopCategories возвращает n категорий с наибольшими расходами: суммой принятых платежей за вычетом возвратов
+func (s *Service) TopCategories(n int, goroutines int) []Aggregate {
+	return top(s.group(ByCategory, Spending, Spent, goroutines), n)
+}
+
+//top возвращает n групп с наибольшей суммой, отрицательное n считается равным 0
+func top(aggregates []Aggregate, n int) []Aggregate {
+	if n < 0 {
+		n = 0
+	}
+	sort.SliceStable(aggregates, func(i, j int) bool {
+		return aggregates[i].Sum > aggregates[j].Sum
+	})
+	if n < len(aggregates) {
+		aggregates = aggregates[:n]
+	}
+	return aggregates
+}
+
+func aggregate(key string, amounts []types.Money) Aggregate {
+	sort.Slice(amounts, func(i, j int) bool {
+		return amounts[i] < amounts[j]
+	})
+	res := Aggregate{
+		Key:   key,
+		Count: len(amounts),
+	}
+	for _, amount := range amounts {
+		res.Sum += amount
+	}
+	res.Avg = res.Sum / types.Money(res.Count)
+	middle := len(amounts) / 2
+	if len(amounts)%2 == 0 {
+		res.Median = (amounts[middle-1] + amounts[middle]) / 2
+	} else {
+		res.Median = amounts[middle]
+	}
+	res.P95 = percentile(amounts, 95)
+	return res
+}
+
+//percentile возвращает p-й процентиль отсортированных сумм методом ближайшего ранга
+func percentile(sorted []types.Money, p int) types.Money {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,104 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func TestService_GroupPayments_category(t *testing.T) {
+	s := newTestService()
+	account, err := s.addAccountWithBalance("+992928885522", 1_000_000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 1; i <= 100; i++ {
+		_, _ = s.Pay(account.ID, types.Money(i), "food")
+	}
+	_, _ = s.Pay(account.ID, 10, "taxi")
+	_, _ = s.Pay(account.ID, 30, "taxi")
+
+	for _, goroutines := range []int{1, 3, 8} {
+		got := s.GroupPayments(ByCategory, nil, goroutines)
+		want := []Aggregate{
+			{Key: "food", Count: 100, Sum: 5050, Avg: 50, Median: 50, P95: 95},
+			{Key: "taxi", Count: 2, Sum: 40, Avg: 20, Median: 20, P95: 30},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GroupPayments(%d): got %+v, want %+v", goroutines, got, want)
+		}
+	}
+}
+
+func TestService_GroupPayments_time(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	account, err := s.addAccountWithBalance("+992928885522", 1_000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = s.Pay(account.ID, 10, "food")
+	now = now.Add(30 * time.Minute)
+	_, _ = s.Pay(account.ID, 20, "food")
+	now = now.Add(24 * time.Hour)
+	payment, _ := s.Pay(account.ID, 40, "food")
+	_ = s.Reject(payment.ID)
+
+	got := s.GroupPayments(ByTime(24*time.Hour), Spending, 2)
+	if len(got) != 1 || got[0].Key != "2021-03-01T00:00:00Z" || got[0].Sum != 30 {
+		t.Errorf("GroupPayments(): wrong daily groups = %+v", got)
+	}
+	got = s.GroupPayments(ByStatus, nil, 2)
+	if len(got) != 2 || got[0].Key != string(types.PaymentStatusFail) || got[0].Count != 1 {
+		t.Errorf("GroupPayments(): wrong status groups = %+v", got)
+	}
+}
+
+func TestService_TopAccounts(t *testing.T) {
+	s := newTestService()
+	for i, amount := range []types.Money{300, 100, 200} {
+		account, err := s.addAccountWithBalance(types.Phone("+99292888552"+string(rune('0'+i))), 1_000)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, _ = s.Pay(account.ID, amount, "auto")
+	}
+	got := s.TopAccounts(2, 2)
+	if len(got) != 2 || got[0].Key != "1" || got[1].Key != "3" {
+		t.Errorf("TopAccounts(): got %+v", got)
+	}
+	got = s.TopCategories(5, 2)
+	if len(got) != 1 || got[0].Sum != 600 {
+		t.Errorf("TopCategories(): got %+v", got)
+	}
+	if got := s.TopCategories(-1, 2); len(got) != 0 {
+		t.Errorf("TopCategories(-1): got %+v", got)
+	}
+}
+
+func TestService_TopCategories_spending(t *testing.T) {
+	s := newTestService()
+	account, err := s.addAccountWithBalance("+992928885522", 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.SetFraudRules([]FraudRule{{Name: "big", Kind: FraudNewAccount, Action: FraudReview, Amount: 500_00, Window: Duration(time.Hour)}})
+	food, _ := s.Pay(account.ID, 100_00, "food")
+	_ = s.Complete(food.ID)
+	_, _ = s.Refund(food.ID, 40_00, "")
+	taxi, _ := s.Pay(account.ID, 30_00, "taxi")
+	_ = s.Complete(taxi.ID)
+	_, _ = s.Refund(taxi.ID, 30_00, "")
+	_, _ = s.Pay(account.ID, 500_00, "auto")
+
+	got := s.TopCategories(5, 2)
+	if len(got) != 1 || got[0].Key != "food" || got[0].Sum != 60_00 {
+		t.Errorf("TopCategories(): got %+v", got)
+	}
+}
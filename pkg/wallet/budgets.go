@@ -0,0 +1,175 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+var (
+	ErrBudgetNotFound = errors.New("budget not found")
+	ErrInvalidBudget  = errors.New("invalid budget")
+)
+
+//BudgetThresholds пороги расходов в процентах от бюджета, при переходе через которые отправляется EventBudgetAlert
+var BudgetThresholds = []int{50, 80, 100}
+
+//BudgetStatus представляет расходы по бюджету за текущий календарный месяц. Remaining отрицателен при перерасходе
+type BudgetStatus struct {
+	Category  types.PaymentCategory
+	Limit     types.Money
+	Spent     types.Money
+	Remaining types.Money
+	Percent   int
+}
+
+//SetBudget задает месячный бюджет счета на категорию, заменяя прежний
+func (s *Service) SetBudget(accountID int64, category types.PaymentCategory, limit types.Money) error {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if category == "" || limit <= 0 || strings.ContainsAny(string(category), ";\r\n") {
+		return ErrInvalidBudget
+	}
+	budget := s.findBudget(accountID, category)
+	if budget == nil {
+		budget = &types.Budget{AccountID: accountID, Category: category}
+		s.budgets = append(s.budgets, budget)
+	}
+	budget.Limit = limit
+	//пороги, пройденные до изменения бюджета, пересчитываются по новому лимиту
+	budget.Period = s.monthStart()
+	budget.Alerted = s.budgetThreshold(budget)
+	return nil
+}
+
+func (s *Service) RemoveBudget(accountID int64, category types.PaymentCategory) error {
+	for i, budget := range s.budgets {
+		if budget.AccountID == accountID && budget.Category == category {
+			s.budgets = append(s.budgets[:i], s.budgets[i+1:]...)
+			return nil
+		}
+	}
+	return ErrBudgetNotFound
+}
+
+//Budgets возвращает состояние бюджетов счета за текущий месяц в порядке добавления
+func (s *Service) Budgets(accountID int64) ([]BudgetStatus, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []BudgetStatus
+	for _, budget := range s.budgets {
+		if budget.AccountID == accountID {
+			statuses = append(statuses, s.budgetStatus(budget))
+		}
+	}
+	return statuses, nil
+}
+
+func (s *Service) findBudget(accountID int64, category types.PaymentCategory) *types.Budget {
+	for _, budget := range s.budgets {
+		if budget.AccountID == accountID && budget.Category == category {
+			return budget
+		}
+	}
+	return nil
+}
+
+func (s *Service) monthStart() int64 {
+	now := s.now()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Unix()
+}
+
+//budgetStatus считает прошедшие платежи за текущий календарный месяц за вычетом возвратов
+func (s *Service) budgetStatus(budget *types.Budget) BudgetStatus {
+	month := s.monthStart()
+	status := BudgetStatus{Category: budget.Category, Limit: budget.Limit}
+	for _, payment := range s.payments {
+		if payment.AccountID != budget.AccountID || payment.Category != budget.Category || payment.Created < month {
+			continue
+		}
+		if Spending(*payment) {
+			status.Spent += Spent(*payment)
+		}
+	}
+	status.Remaining = status.Limit - status.Spent
+	status.Percent = int(status.Spent * 100 / status.Limit)
+	return status
+}
+
+//budgetThreshold возвращает наибольший пройденный порог бюджета или 0
+func (s *Service) budgetThreshold(budget *types.Budget) int {
+	percent := s.budgetStatus(budget).Percent
+	threshold := 0
+	for _, current := range BudgetThresholds {
+		if percent >= current && current > threshold {
+			threshold = current
+		}
+	}
+	return threshold
+}
+
+//checkBudget отправляет EventBudgetAlert, если платеж перевел расходы по бюджету через новый порог.
+//За один платеж отправляется одно событие о наибольшем пройденном пороге
+func (s *Service) checkBudget(account *types.Account, payment *types.Payment) {
+	budget := s.findBudget(account.ID, payment.Category)
+	if budget == nil {
+		return
+	}
+	if month := s.monthStart(); budget.Period != month {
+		budget.Period = month
+		budget.Alerted = 0
+	}
+	threshold := s.budgetThreshold(budget)
+	if threshold <= budget.Alerted {
+		return
+	}
+	budget.Alerted = threshold
+	s.emitEvent(Event{
+		Type:      EventBudgetAlert,
+		AccountID: account.ID,
+		Phone:     account.Phone,
+		Amount:    s.budgetStatus(budget).Spent,
+		Balance:   account.Balance,
+		PaymentID: payment.ID,
+		Category:  payment.Category,
+		Threshold: threshold,
+		Created:   s.now().Unix(),
+	})
+}
+
+func (s *Service) budgetLines() []string {
+	var lines []string
+	for _, budget := range s.budgets {
+		lines = append(lines, budget.ToString())
+	}
+	return lines
+}
+
+func (s *Service) importBudget(budgetStr []string) {
+	if len(budgetStr) < 5 {
+		return
+	}
+	AccountID, _ := strconv.ParseInt(budgetStr[0], 10, 64)
+	Limit, _ := strconv.ParseInt(budgetStr[2], 10, 64)
+	Period, _ := strconv.ParseInt(budgetStr[3], 10, 64)
+	Alerted, _ := strconv.Atoi(budgetStr[4])
+	if Limit <= 0 {
+		return
+	}
+	category := types.PaymentCategory(budgetStr[1])
+	budget := s.findBudget(AccountID, category)
+	if budget == nil {
+		budget = &types.Budget{AccountID: AccountID, Category: category}
+		s.budgets = append(s.budgets, budget)
+	}
+	budget.Limit = types.Money(Limit)
+	budget.Period = Period
+	budget.Alerted = Alerted
+}
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func TestService_Budgets(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2021, 3, 10, 10, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	account, err := s.addAccountWithBalance("+992928885522", 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = s.Pay(account.ID, 30_00, "food")
+	if err := s.SetBudget(account.ID, "food", 0); err != ErrInvalidBudget {
+		t.Errorf("SetBudget(): must return ErrInvalidBudget, returned = %v", err)
+	}
+	err = s.SetBudget(account.ID, "food", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alerts []Event
+	s.Subscribe(func(event Event) {
+		if event.Type == EventBudgetAlert {
+			alerts = append(alerts, event)
+		}
+	})
+
+	_, _ = s.Pay(account.ID, 10_00, "food")
+	if len(alerts) != 0 {
+		t.Errorf("Pay(): alerts below 50%% = %v", alerts)
+	}
+	rejected, _ := s.Pay(account.ID, 20_00, "food")
+	_ = s.Reject(rejected.ID)
+	_, _ = s.Pay(account.ID, 15_00, "food")
+	_, _ = s.Pay(account.ID, 50_00, "food")
+	_, _ = s.Pay(account.ID, 5_00, "food")
+	_, _ = s.Pay(account.ID, 5_00, "auto")
+	if len(alerts) != 2 || alerts[0].Threshold != 50 || alerts[0].Amount != 60_00 || alerts[1].Threshold != 100 || alerts[1].Amount != 105_00 {
+		t.Errorf("Pay(): alerts = %v", alerts)
+	}
+
+	budgets, _ := s.Budgets(account.ID)
+	want := []BudgetStatus{{Category: "food", Limit: 100_00, Spent: 110_00, Remaining: -10_00, Percent: 110}}
+	if !reflect.DeepEqual(budgets, want) {
+		t.Errorf("Budgets(): %v, want %v", budgets, want)
+	}
+
+	now = now.AddDate(0, 1, 0)
+	_, _ = s.Pay(account.ID, 60_00, "food")
+	if len(alerts) != 3 || alerts[2].Threshold != 50 {
+		t.Errorf("Pay(): alerts must restart in new month, alerts = %v", alerts)
+	}
+	if err := s.RemoveBudget(account.ID, "food"); err != nil {
+		t.Errorf("RemoveBudget(): %v", err)
+	}
+	if err := s.RemoveBudget(account.ID, "food"); err != ErrBudgetNotFound {
+		t.Errorf("RemoveBudget(): must return ErrBudgetNotFound, returned = %v", err)
+	}
+}
+
+func TestService_Budgets_refunds(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	_ = s.SetBudget(account.ID, "tv", 1_000_00)
+	tv, _ := s.Pay(account.ID, 800_00, "tv")
+	refunded, _ := s.Pay(account.ID, 100_00, "tv")
+	_ = s.Complete(tv.ID)
+	_ = s.Complete(refunded.ID)
+	_, _ = s.Refund(tv.ID, 1, "")
+	_, _ = s.Refund(refunded.ID, 100_00, "")
+
+	budgets, _ := s.Budgets(account.ID)
+	want := []BudgetStatus{{Category: "tv", Limit: 1_000_00, Spent: 799_99, Remaining: 200_01, Percent: 79}}
+	if !reflect.DeepEqual(budgets, want) {
+		t.Errorf("Budgets(): partially refunded payment must count net of refund, got %v, want %v", budgets, want)
+	}
+}
+
+func TestService_Import_budgets(t *testing.T) {
+	s := newTestService()
+	account, err := s.addAccountWithBalance("+992928885522", 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.SetBudget(account.ID, "food", 100_00)
+	_ = s.SetBudget(account.ID, types.PaymentCategory("auto"), 50_00)
+	_, _ = s.Pay(account.ID, 90_00, "food")
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	for i := 0; i < 2; i++ {
+		err = imported.Import(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(imported.budgets, s.budgets) {
+		t.Errorf("Import(): budgets = %v, want %v", imported.budgets, s.budgets)
+	}
+}
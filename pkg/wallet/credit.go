@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+var (
+	ErrInvalidCreditLimit  = errors.New("invalid credit limit")
+	ErrCreditLimitExceeded = errors.New("credit limit exceeded")
+)
+
+//SetCreditLimit одобряет овердрафт для счета. 0 отключает овердрафт, уже возникший долг остается.
+//Лимит проверяется только при тратах: проценты, удержание кэшбэка и списание зачисления по спору
+//проводятся всегда и могут увести долг за лимит, после чего счет не может тратить, пока долг не вернется в лимит
+func (s *Service) SetCreditLimit(accountID int64, limit types.Money) error {
+	if limit < 0 {
+		return ErrInvalidCreditLimit
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	account.CreditLimit = limit
+	return nil
+}
+
+//SetOverdraftInterest задает годовую ставку в процентах на отрицательный баланс.
+//Проценты начисляются через AccrueInterest за каждый полный день, начиная с текущего
+func (s *Service) SetOverdraftInterest(annualPercent float64) {
+	s.interestRate = annualPercent
+	s.interestDay = dayStart(s.now()).Unix()
+}
+
+//AccrueInterest начисляет проценты за дни, прошедшие с прошлого начисления, на отрицательные балансы
+//и возвращает созданные движения. Проценты за каждый день считаются от баланса на конец этого дня по журналу
+//с учетом процентов за предыдущие дни, движение датируется последней секундой дня
+func (s *Service) AccrueInterest() []types.Posting {
+	now := s.now()
+	today := dayStart(now).Unix()
+	if s.interestDay == 0 || s.interestRate <= 0 {
+		s.interestDay = today
+		return nil
+	}
+	start := time.Unix(s.interestDay, 0).In(now.Location())
+	//балансы на начало первого дня, дальше движения добавляются по дням в порядке времени
+	balances := map[int64]types.Money{}
+	for _, account := range s.accounts {
+		balances[account.ID] = account.Balance
+	}
+	var later []*types.Posting
+	for _, posting := range s.postings {
+		if posting.Created >= start.Unix() {
+			balances[posting.AccountID] -= posting.Amount
+			later = append(later, posting)
+		}
+	}
+	sort.SliceStable(later, func(i, j int) bool {
+		return later[i].Created < later[j].Created
+	})
+
+	var postings []types.Posting
+	next := 0
+	for day := start; day.Unix() < today; day = day.AddDate(0, 0, 1) {
+		end := day.AddDate(0, 0, 1)
+		for ; next < len(later) && later[next].Created < end.Unix(); next++ {
+			balances[later[next].AccountID] += later[next].Amount
+		}
+		for _, account := range s.accounts {
+			balance := balances[account.ID]
+			if balance >= 0 {
+				continue
+			}
+			interest := types.Money(math.Round(float64(-balance) * s.interestRate / 100 / 365))
+			if interest <= 0 {
+				continue
+			}
+			balances[account.ID] -= interest
+			s.postAt(account, -interest, types.PostingInterest, "", end.Add(-time.Second))
+			postings = append(postings, *s.postings[len(s.postings)-1])
+		}
+	}
+	s.interestDay = today
+	return postings
+}
+
+//checkFunds проверяет, что на счете с учетом овердрафта хватает денег на сумму.
+//Счет, долг которого уже превысил кредитный лимит, не может тратить совсем
+func (s *Service) checkFunds(account *types.Account, amount types.Money) error {
+	available := account.Available()
+	if available >= amount {
+		return nil
+	}
+	if account.CreditLimit > 0 && available < 0 {
+		return ErrCreditLimitExceeded
+	}
+	return ErrNotEnoughBalance
+}
+
+func (s *Service) interestLines() []string {
+	if s.interestRate == 0 && s.interestDay == 0 {
+		return nil
+	}
+	return []string{strconv.FormatFloat(s.interestRate, 'f', -1, 64) + ";" + strconv.FormatInt(s.interestDay, 10)}
+}
+
+func (s *Service) importInterest(interestStr []string) {
+	if len(interestStr) < 2 {
+		return
+	}
+	Rate, err := strconv.ParseFloat(interestStr[0], 64)
+	if err != nil {
+		return
+	}
+	Day, _ := strconv.ParseInt(interestStr[1], 10, 64)
+	s.interestRate = Rate
+	s.interestDay = Day
+}
+
+//ownFunds возвращает собственные деньги счета, доступные без овердрафта
+func ownFunds(account *types.Account) types.Money {
+	return account.Available() - account.CreditLimit
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,213 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func TestService_Pay_overdraft(t *testing.T) {
+	s := newTestService()
+	account, err := s.addAccountWithBalance("+992928885522", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Pay(account.ID, 150_00, "auto"); err != ErrNotEnoughBalance {
+		t.Errorf("Pay(): must return ErrNotEnoughBalance without credit limit, returned = %v", err)
+	}
+	if err := s.SetCreditLimit(account.ID, -1); err != ErrInvalidCreditLimit {
+		t.Errorf("SetCreditLimit(): must return ErrInvalidCreditLimit, returned = %v", err)
+	}
+	_ = s.SetCreditLimit(account.ID, 100_00)
+
+	_, err = s.Pay(account.ID, 150_00, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != -50_00 || account.Available() != 50_00 {
+		t.Errorf("Pay(): balance = %v, available = %v", account.Balance, account.Available())
+	}
+	if _, err := s.Pay(account.ID, 60_00, "auto"); err != ErrNotEnoughBalance {
+		t.Errorf("Pay(): must return ErrNotEnoughBalance above credit limit, returned = %v", err)
+	}
+
+	_ = s.SetCreditLimit(account.ID, 40_00)
+	if _, err := s.Pay(account.ID, 1_00, "auto"); err != ErrCreditLimitExceeded {
+		t.Errorf("Pay(): must return ErrCreditLimitExceeded, returned = %v", err)
+	}
+	other, _ := s.RegisterAccount("+992928885533")
+	if _, err := s.Transfer(account.ID, other.ID, 1_00); err != ErrCreditLimitExceeded {
+		t.Errorf("Transfer(): must return ErrCreditLimitExceeded, returned = %v", err)
+	}
+}
+
+func TestService_MoveToJar_creditLimit(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 100_00)
+	_ = s.SetCreditLimit(account.ID, 500_00)
+	jar, _ := s.CreateJar(account.ID, "bike", 0, 0)
+	if err := s.MoveToJar(jar.ID, 150_00); err != ErrNotEnoughBalance {
+		t.Errorf("MoveToJar(): overdraft must not be moved to jar, returned = %v", err)
+	}
+	if err := s.MoveToJar(jar.ID, 100_00); err != nil {
+		t.Errorf("MoveToJar(): own funds, error = %v", err)
+	}
+	_, _ = s.Pay(account.ID, 50_00, "auto")
+	if err := s.MoveToJar(jar.ID, 1); err != ErrNotEnoughBalance {
+		t.Errorf("MoveToJar(): in overdraft must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	if account.Balance != -50_00 || jar.Balance != 100_00 {
+		t.Errorf("MoveToJar(): balance = %v, jar = %v", account.Balance, jar.Balance)
+	}
+}
+
+func TestService_AccrueInterest(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2021, 3, 10, 18, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	debtor, err := s.addAccountWithBalance("+992928885522", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saver, err := s.addAccountWithBalance("+992928885533", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.SetCreditLimit(debtor.ID, 500_00)
+	_, _ = s.Pay(debtor.ID, 200_00, "auto")
+	s.SetOverdraftInterest(36.5)
+
+	now = now.Add(5 * time.Hour)
+	if postings := s.AccrueInterest(); len(postings) != 0 {
+		t.Errorf("AccrueInterest(): the same day must not accrue, postings = %v", postings)
+	}
+	now = now.AddDate(0, 0, 3)
+	postings := s.AccrueInterest()
+	if len(postings) != 3 || postings[0].Amount != -10 || postings[0].Kind != types.PostingInterest {
+		t.Errorf("AccrueInterest(): postings = %v", postings)
+	}
+	if debtor.Balance != -100_30 || saver.Balance != 100_00 {
+		t.Errorf("AccrueInterest(): debtor = %v, saver = %v", debtor.Balance, saver.Balance)
+	}
+	if postings := s.AccrueInterest(); len(postings) != 0 {
+		t.Errorf("AccrueInterest(): repeated run must not accrue, postings = %v", postings)
+	}
+}
+
+func TestService_AccrueInterest_dailyBalance(t *testing.T) {
+	s, debtor, now := newClockedTestService(t, time.Date(2021, 3, 10, 18, 0, 0, 0, time.UTC), 100_00)
+	_ = s.SetCreditLimit(debtor.ID, 500_00)
+	_, _ = s.Pay(debtor.ID, 300_00, "auto")
+	s.SetOverdraftInterest(36.5)
+	*now = time.Date(2021, 3, 11, 12, 0, 0, 0, time.UTC)
+	_ = s.Deposit(debtor.ID, 150_00)
+
+	*now = time.Date(2021, 3, 13, 9, 0, 0, 0, time.UTC)
+	postings := s.AccrueInterest()
+	if len(postings) != 3 || postings[0].Amount != -20 || postings[1].Amount != -5 || postings[2].Amount != -5 {
+		t.Fatalf("AccrueInterest(): each day must use its own balance, postings = %v", postings)
+	}
+	for i, posting := range postings {
+		if want := time.Date(2021, 3, 10+i, 23, 59, 59, 0, time.UTC).Unix(); posting.Created != want {
+			t.Errorf("AccrueInterest(): posting %d created = %v, want %v", i, posting.Created, want)
+		}
+	}
+	if debtor.Balance != -50_30 {
+		t.Errorf("AccrueInterest(): balance = %v", debtor.Balance)
+	}
+}
+
+func TestService_AccrueInterest_creditLimit(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 100_00)
+	_ = s.SetCreditLimit(account.ID, 100_00)
+	_, _ = s.Pay(account.ID, 200_00, "auto")
+	s.SetOverdraftInterest(36.5)
+	*now = now.AddDate(0, 0, 1)
+	_ = s.AccrueInterest()
+
+	//проценты не ограничены кредитным лимитом, но тратить за лимитом нельзя
+	if account.Balance != -100_10 {
+		t.Errorf("AccrueInterest(): balance = %v", account.Balance)
+	}
+	if _, err := s.Pay(account.ID, 1, "auto"); err != ErrCreditLimitExceeded {
+		t.Errorf("Pay(): must return ErrCreditLimitExceeded, returned = %v", err)
+	}
+	_ = s.Deposit(account.ID, 1_00)
+	if _, err := s.Pay(account.ID, 90, "auto"); err != nil {
+		t.Errorf("Pay(): debt back within limit, error = %v", err)
+	}
+}
+
+func TestService_ResolveDispute_creditLimit(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 300_00)
+	payment := s.addDisputable(t, account)
+	dispute, _ := s.OpenDispute(payment.ID, "not delivered", "")
+	_ = s.SetCreditLimit(account.ID, 100_00)
+	_, _ = s.Pay(account.ID, 350_00, "auto")
+
+	//списание зачисления по спору не ограничено кредитным лимитом
+	_ = s.ResolveDispute(dispute.ID, types.DisputeLost)
+	if account.Balance != -350_00 {
+		t.Errorf("ResolveDispute(): balance = %v", account.Balance)
+	}
+	if _, err := s.Pay(account.ID, 1, "auto"); err != ErrCreditLimitExceeded {
+		t.Errorf("Pay(): must return ErrCreditLimitExceeded, returned = %v", err)
+	}
+}
+
+func TestService_Import_creditLimit(t *testing.T) {
+	s := newTestService()
+	account, err := s.addAccountWithBalance("+992928885522", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.SetCreditLimit(account.ID, 300_00)
+	_, _ = s.Pay(account.ID, 250_00, "auto")
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := imported.FindAccountByID(account.ID)
+	if !reflect.DeepEqual(got, account) {
+		t.Errorf("Import(): account = %+v, want %+v", got, account)
+	}
+}
+
+func TestService_Import_interest(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 100_00)
+	_ = s.SetCreditLimit(account.ID, 500_00)
+	_, _ = s.Pay(account.ID, 465_00, "auto")
+	s.SetOverdraftInterest(10)
+	*now = now.AddDate(0, 0, 1)
+	_ = s.AccrueInterest()
+
+	dir := t.TempDir()
+	err := s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	imported.SetClock(func() time.Time { return *now })
+	for i := 0; i < 2; i++ {
+		err = imported.Import(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	*now = now.AddDate(0, 0, 2)
+	if postings := imported.AccrueInterest(); len(postings) != 2 || postings[0].Amount != -10 {
+		t.Errorf("AccrueInterest(): imported rate and day must be used, postings = %v", postings)
+	}
+	got, _ := imported.FindAccountByID(account.ID)
+	if got.Balance != -365_30 {
+		t.Errorf("AccrueInterest(): balance = %v", got.Balance)
+	}
+}
@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCronSpec = errors.New("invalid cron spec")
+
+//cronSpec разобранное выражение "минута час день-месяца месяц день-недели".
+//Поддерживаются *, списки через запятую, диапазоны a-b и шаги */n, a-b/n
+type cronSpec struct {
+	minute, hour, dom, month, dow [61]bool
+	anyDom, anyDow                bool
+}
+
+func parseCron(spec string) (*cronSpec, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, ErrInvalidCronSpec
+	}
+	c := &cronSpec{
+		anyDom: fields[2] == "*",
+		anyDow: fields[4] == "*",
+	}
+	ranges := []struct {
+		dst      *[61]bool
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	}
+	for i, r := range ranges {
+		err := parseCronField(fields[i], r.min, r.max, r.dst)
+		if err != nil {
+			return nil, err
+		}
+	}
+	//воскресенье можно указать как 0 или 7
+	if c.dow[7] {
+		c.dow[0] = true
+	}
+	return c, nil
+}
+
+func parseCronField(field string, min int, max int, dst *[61]bool) error {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return ErrInvalidCronSpec
+			}
+			step = n
+			part = part[:i]
+		}
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return ErrInvalidCronSpec
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return ErrInvalidCronSpec
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return ErrInvalidCronSpec
+		}
+		for v := from; v <= to; v += step {
+			dst[v] = true
+		}
+	}
+	return nil
+}
+
+func (c *cronSpec) matchDay(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	//как в cron: если ограничены оба поля, достаточно совпадения одного из них
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	}
+	return dom || dow
+}
+
+//next возвращает первое подходящее время строго после t с точностью до минуты или нулевое время, если его нет в ближайшие 5 лет
+func (c *cronSpec) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
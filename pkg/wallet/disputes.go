@@ -0,0 +1,243 @@
+package wallet
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDisputeNotFound      = errors.New("dispute not found")
+	ErrDisputeExists        = errors.New("payment already disputed")
+	ErrDisputeClosed        = errors.New("dispute is closed")
+	ErrPaymentNotDisputable = errors.New("payment can not be disputed")
+	ErrPaymentDisputed      = errors.New("payment is disputed")
+	ErrInvalidResolution    = errors.New("invalid dispute resolution")
+)
+
+//Сроки по умолчанию: ответ продавца и решение поддержки, считая от открытия спора
+const (
+	DefaultDisputeRespond = 10 * 24 * time.Hour
+	DefaultDisputeResolve = 45 * 24 * time.Hour
+)
+
+//SetDisputeDeadlines задает сроки ответа продавца и решения поддержки
+func (s *Service) SetDisputeDeadlines(respond time.Duration, resolve time.Duration) {
+	s.disputeRespond = respond
+	s.disputeResolve = resolve
+}
+
+//OpenDispute открывает спор по платежу на невозвращенную сумму и временно зачисляет ее на счет клиента
+func (s *Service) OpenDispute(paymentID string, reason string, evidence string) (*types.Dispute, error) {
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	switch payment.Status {
+	case types.PaymentStatusOk, types.PaymentStatusInProgress, types.PaymentStatusPartiallyRefunded:
+	default:
+		return nil, ErrPaymentNotDisputable
+	}
+	if s.findTransfer(payment.ID) != nil {
+		return nil, ErrPaymentNotDisputable
+	}
+	if s.openDispute(payment.ID) != nil {
+		return nil, ErrDisputeExists
+	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	respond, resolve := s.disputeRespond, s.disputeResolve
+	if respond <= 0 {
+		respond = DefaultDisputeRespond
+	}
+	if resolve <= 0 {
+		resolve = DefaultDisputeResolve
+	}
+	now := s.now()
+	dispute := &types.Dispute{
+		ID:        uuid.New().String(),
+		PaymentID: payment.ID,
+		AccountID: account.ID,
+		Amount:    payment.Amount - payment.Refunded,
+		Reason:    dumpText(reason),
+		Evidence:  dumpText(evidence),
+		Status:    types.DisputeOpen,
+		Created:   now.Unix(),
+		RespondBy: now.Add(respond).Unix(),
+		ResolveBy: now.Add(resolve).Unix(),
+	}
+	s.disputes = append(s.disputes, dispute)
+	s.post(account, dispute.Amount, types.PostingProvisionalCredit, payment.ID)
+	s.emit(EventDisputeOpened, account, dispute.Amount, payment)
+	return dispute, nil
+}
+
+//RespondDispute сохраняет ответ продавца и передает спор на решение поддержке
+func (s *Service) RespondDispute(disputeID string, response string) error {
+	dispute, err := s.activeDispute(disputeID)
+	if err != nil {
+		return err
+	}
+	if dispute.Status != types.DisputeOpen {
+		return ErrDisputeClosed
+	}
+	dispute.Response = dumpText(response)
+	dispute.Status = types.DisputeResponded
+	return nil
+}
+
+//ResolveDispute закрывает спор. При DisputeWon временное зачисление остается у клиента и платеж получает статус CHARGEBACK,
+//при DisputeLost зачисление списывается обратно, даже если баланс станет отрицательным
+func (s *Service) ResolveDispute(disputeID string, status types.DisputeStatus) error {
+	if status != types.DisputeWon && status != types.DisputeLost {
+		return ErrInvalidResolution
+	}
+	dispute, err := s.activeDispute(disputeID)
+	if err != nil {
+		return err
+	}
+	return s.resolve(dispute, status)
+}
+
+//ProcessDisputeDeadlines решает в пользу клиента споры, по которым продавец не ответил или поддержка не приняла решение в срок
+func (s *Service) ProcessDisputeDeadlines() []types.Dispute {
+	now := s.now().Unix()
+	var resolved []types.Dispute
+	for _, dispute := range s.disputes {
+		expired := dispute.Status == types.DisputeOpen && now >= dispute.RespondBy ||
+			dispute.Status == types.DisputeResponded && now >= dispute.ResolveBy
+		if !expired {
+			continue
+		}
+		err := s.resolve(dispute, types.DisputeWon)
+		if err == nil {
+			resolved = append(resolved, *dispute)
+		}
+	}
+	return resolved
+}
+
+//DisputeQueue возвращает открытые споры для поддержки, ближайший срок первым
+func (s *Service) DisputeQueue() []types.Dispute {
+	s.ProcessDisputeDeadlines()
+	var disputes []types.Dispute
+	for _, dispute := range s.disputes {
+		if dispute.Status == types.DisputeOpen || dispute.Status == types.DisputeResponded {
+			disputes = append(disputes, *dispute)
+		}
+	}
+	sort.SliceStable(disputes, func(i, j int) bool {
+		return disputeDeadline(disputes[i]) < disputeDeadline(disputes[j])
+	})
+	return disputes
+}
+
+func (s *Service) FindDisputeByID(disputeID string) (*types.Dispute, error) {
+	for _, dispute := range s.disputes {
+		if dispute.ID == disputeID {
+			return dispute, nil
+		}
+	}
+	return nil, ErrDisputeNotFound
+}
+
+func disputeDeadline(dispute types.Dispute) int64 {
+	if dispute.Status == types.DisputeOpen {
+		return dispute.RespondBy
+	}
+	return dispute.ResolveBy
+}
+
+func (s *Service) activeDispute(disputeID string) (*types.Dispute, error) {
+	dispute, err := s.FindDisputeByID(disputeID)
+	if err != nil {
+		return nil, err
+	}
+	s.ProcessDisputeDeadlines()
+	if dispute.Status != types.DisputeOpen && dispute.Status != types.DisputeResponded {
+		return nil, ErrDisputeClosed
+	}
+	return dispute, nil
+}
+
+//openDispute возвращает незакрытый спор по платежу или nil
+func (s *Service) openDispute(paymentID string) *types.Dispute {
+	for _, dispute := range s.disputes {
+		if dispute.PaymentID == paymentID && (dispute.Status == types.DisputeOpen || dispute.Status == types.DisputeResponded) {
+			return dispute
+		}
+	}
+	return nil
+}
+
+func (s *Service) resolve(dispute *types.Dispute, status types.DisputeStatus) error {
+	payment, err := s.FindPaymentByID(dispute.PaymentID)
+	if err != nil {
+		return err
+	}
+	account, err := s.FindAccountByID(dispute.AccountID)
+	if err != nil {
+		return err
+	}
+	dispute.Status = status
+	dispute.Resolved = s.now().Unix()
+	if status == types.DisputeWon {
+		payment.Refunded += dispute.Amount
+		payment.Status = types.PaymentStatusChargeback
+		s.clawback(account, payment, false)
+	} else {
+		s.post(account, -dispute.Amount, types.PostingProvisionalReversal, payment.ID)
+	}
+	s.emit(EventDisputeResolved, account, dispute.Amount, payment)
+	return nil
+}
+
+func (s *Service) disputeLines() []string {
+	var lines []string
+	for _, dispute := range s.disputes {
+		lines = append(lines, dispute.ToString())
+	}
+	return lines
+}
+
+//importDispute восстанавливает спор и сумму, возвращенную по выигранному спору
+func (s *Service) importDispute(disputeStr []string) {
+	if len(disputeStr) < 12 {
+		return
+	}
+	AccountID, _ := strconv.ParseInt(disputeStr[2], 10, 64)
+	Amount, _ := strconv.ParseInt(disputeStr[3], 10, 64)
+	Created, _ := strconv.ParseInt(disputeStr[8], 10, 64)
+	RespondBy, _ := strconv.ParseInt(disputeStr[9], 10, 64)
+	ResolveBy, _ := strconv.ParseInt(disputeStr[10], 10, 64)
+	Resolved, _ := strconv.ParseInt(disputeStr[11], 10, 64)
+	dispute, err := s.FindDisputeByID(disputeStr[0])
+	if err != nil {
+		dispute = &types.Dispute{ID: disputeStr[0]}
+		s.disputes = append(s.disputes, dispute)
+	} else if payment, err := s.FindPaymentByID(dispute.PaymentID); err == nil && dispute.Status == types.DisputeWon {
+		payment.Refunded -= dispute.Amount
+	}
+	dispute.PaymentID = disputeStr[1]
+	dispute.AccountID = AccountID
+	dispute.Amount = types.Money(Amount)
+	dispute.Reason = disputeStr[4]
+	dispute.Evidence = disputeStr[5]
+	dispute.Response = disputeStr[6]
+	dispute.Status = types.DisputeStatus(disputeStr[7])
+	dispute.Created = Created
+	dispute.RespondBy = RespondBy
+	dispute.ResolveBy = ResolveBy
+	dispute.Resolved = Resolved
+	//выигранный спор возвращает клиенту сумму так же, как возврат
+	if payment, err := s.FindPaymentByID(dispute.PaymentID); err == nil && dispute.Status == types.DisputeWon {
+		payment.Refunded += dispute.Amount
+	}
+}
@@ -0,0 +1,171 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+//addDisputable задает сроки спора в сутки на ответ и трое суток на решение и оплачивает со счета 300.00
+func (s *testService) addDisputable(t *testing.T, account *types.Account) *types.Payment {
+	s.SetDisputeDeadlines(24*time.Hour, 72*time.Hour)
+	payment, err := s.Pay(account.ID, 300_00, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+func TestService_OpenDispute(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 1_000_00)
+	payment := s.addDisputable(t, account)
+	_ = s.Complete(payment.ID)
+	_, _ = s.Refund(payment.ID, 100_00, "")
+	dispute, err := s.OpenDispute(payment.ID, "not delivered", "tracking;\nlost")
+	if err != nil {
+		t.Fatalf("OpenDispute(): error = %v", err)
+	}
+	want := types.Dispute{
+		ID:        dispute.ID,
+		PaymentID: payment.ID,
+		AccountID: account.ID,
+		Amount:    200_00,
+		Reason:    "not delivered",
+		Evidence:  "tracking, lost",
+		Status:    types.DisputeOpen,
+		Created:   now.Unix(),
+		RespondBy: now.Add(24 * time.Hour).Unix(),
+		ResolveBy: now.Add(72 * time.Hour).Unix(),
+	}
+	if *dispute != want {
+		t.Errorf("OpenDispute(): got %+v, want %+v", *dispute, want)
+	}
+	if account.Balance != 1_000_00 {
+		t.Errorf("OpenDispute(): balance = %v", account.Balance)
+	}
+	if _, err := s.OpenDispute(payment.ID, "", ""); err != ErrDisputeExists {
+		t.Errorf("OpenDispute(): must return ErrDisputeExists, returned = %v", err)
+	}
+	if _, err := s.Refund(payment.ID, 10_00, ""); err != ErrPaymentDisputed {
+		t.Errorf("Refund(): must return ErrPaymentDisputed, returned = %v", err)
+	}
+	if err := s.Reject(payment.ID); err != ErrPaymentDisputed {
+		t.Errorf("Reject(): must return ErrPaymentDisputed, returned = %v", err)
+	}
+	rejected, _ := s.Pay(account.ID, 10_00, "shop")
+	_ = s.Reject(rejected.ID)
+	if _, err := s.OpenDispute(rejected.ID, "", ""); err != ErrPaymentNotDisputable {
+		t.Errorf("OpenDispute(): must return ErrPaymentNotDisputable, returned = %v", err)
+	}
+}
+
+func TestService_ResolveDispute(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 1_000_00)
+	payment := s.addDisputable(t, account)
+	var events []Event
+	s.Subscribe(func(event Event) { events = append(events, event) })
+	dispute, _ := s.OpenDispute(payment.ID, "not delivered", "")
+	if err := s.RespondDispute(dispute.ID, "delivered to door"); err != nil || dispute.Status != types.DisputeResponded {
+		t.Fatalf("RespondDispute(): dispute = %v, error = %v", dispute, err)
+	}
+	if err := s.RespondDispute(dispute.ID, "again"); err != ErrDisputeClosed {
+		t.Errorf("RespondDispute(): must return ErrDisputeClosed, returned = %v", err)
+	}
+	if err := s.ResolveDispute(dispute.ID, types.DisputeOpen); err != ErrInvalidResolution {
+		t.Errorf("ResolveDispute(): must return ErrInvalidResolution, returned = %v", err)
+	}
+	if err := s.ResolveDispute(dispute.ID, types.DisputeLost); err != nil {
+		t.Fatalf("ResolveDispute(): error = %v", err)
+	}
+	if dispute.Status != types.DisputeLost || account.Balance != 700_00 || payment.Status != types.PaymentStatusInProgress {
+		t.Errorf("ResolveDispute(): dispute = %v, payment = %v, balance = %v", dispute, payment, account.Balance)
+	}
+	if err := s.ResolveDispute(dispute.ID, types.DisputeWon); err != ErrDisputeClosed {
+		t.Errorf("ResolveDispute(): must return ErrDisputeClosed, returned = %v", err)
+	}
+
+	dispute, _ = s.OpenDispute(payment.ID, "not delivered", "photo")
+	_ = s.ResolveDispute(dispute.ID, types.DisputeWon)
+	if account.Balance != 1_000_00 || payment.Status != types.PaymentStatusChargeback || payment.Refunded != 300_00 {
+		t.Errorf("ResolveDispute(): payment = %v, balance = %v", payment, account.Balance)
+	}
+	postings, _ := s.AccountPostings(account.ID)
+	kinds := []types.PostingKind{}
+	for _, posting := range postings[2:] {
+		kinds = append(kinds, posting.Kind)
+	}
+	wantKinds := []types.PostingKind{types.PostingProvisionalCredit, types.PostingProvisionalReversal, types.PostingProvisionalCredit}
+	if !reflect.DeepEqual(kinds, wantKinds) {
+		t.Errorf("AccountPostings(): kinds = %v, want %v", kinds, wantKinds)
+	}
+	if len(events) != 4 || events[1].Type != EventDisputeResolved || events[1].Balance != 700_00 {
+		t.Errorf("events = %v", events)
+	}
+}
+
+func TestService_ProcessDisputeDeadlines(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 1_000_00)
+	payment := s.addDisputable(t, account)
+	other, _ := s.Pay(account.ID, 100_00, "shop")
+	silent, _ := s.OpenDispute(payment.ID, "not delivered", "")
+	*now = now.Add(time.Hour)
+	answered, _ := s.OpenDispute(other.ID, "broken", "")
+	_ = s.RespondDispute(answered.ID, "works")
+
+	queue := s.DisputeQueue()
+	if len(queue) != 2 || queue[0].ID != silent.ID || queue[1].ID != answered.ID {
+		t.Errorf("DisputeQueue(): got %v", queue)
+	}
+
+	*now = now.Add(24 * time.Hour)
+	if err := s.RespondDispute(silent.ID, "late"); err != ErrDisputeClosed {
+		t.Errorf("RespondDispute(): must return ErrDisputeClosed, returned = %v", err)
+	}
+	if silent.Status != types.DisputeWon || payment.Status != types.PaymentStatusChargeback {
+		t.Errorf("RespondDispute(): dispute = %v, payment = %v", silent, payment)
+	}
+	if queue := s.DisputeQueue(); len(queue) != 1 || queue[0].ID != answered.ID {
+		t.Errorf("DisputeQueue(): got %v", queue)
+	}
+
+	*now = now.Add(48 * time.Hour)
+	resolved := s.ProcessDisputeDeadlines()
+	if len(resolved) != 1 || resolved[0].ID != answered.ID || resolved[0].Status != types.DisputeWon {
+		t.Errorf("ProcessDisputeDeadlines(): got %v", resolved)
+	}
+	if account.Balance != 1_000_00 {
+		t.Errorf("balance = %v", account.Balance)
+	}
+}
+
+func TestService_Import_disputes(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 1_000_00)
+	payment := s.addDisputable(t, account)
+	_ = s.Complete(payment.ID)
+	_, _ = s.Refund(payment.ID, 100_00, "")
+	dispute, _ := s.OpenDispute(payment.ID, "not delivered", "")
+	_ = s.ResolveDispute(dispute.ID, types.DisputeWon)
+
+	dir := t.TempDir()
+	err := s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	for i := 0; i < 2; i++ {
+		err = imported.Import(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, _ := imported.FindDisputeByID(dispute.ID)
+	if !reflect.DeepEqual(got, dispute) {
+		t.Errorf("Import(): dispute = %v, want %v", got, dispute)
+	}
+	gotPayment, _ := imported.FindPaymentByID(payment.ID)
+	if !reflect.DeepEqual(gotPayment, payment) {
+		t.Errorf("Import(): payment = %v, want %v", gotPayment, payment)
+	}
+}
@@ -0,0 +1,83 @@
+package wallet
+
+import (
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+//EventType представляет тип события сервиса
+type EventType string
+
+//Предопределенные типы событий
+const (
+	EventDeposit         EventType = "deposit"
+	EventPayment         EventType = "payment"
+	EventReject          EventType = "reject"
+	EventLowBalance      EventType = "low_balance"
+	EventRefund          EventType = "refund"
+	EventDisputeOpened   EventType = "dispute_opened"
+	EventDisputeResolved EventType = "dispute_resolved"
+	//EventMoneyRequested отправляется получателю запроса денег, EventRequestAccepted и EventRequestDeclined - автору запроса
+	EventMoneyRequested  EventType = "money_requested"
+	EventRequestAccepted EventType = "request_accepted"
+	EventRequestDeclined EventType = "request_declined"
+	//EventBudgetAlert отправляется, когда расходы по бюджету проходят порог из BudgetThresholds
+	EventBudgetAlert EventType = "budget_alert"
+)
+
+//Event представляет событие по счету, на которое можно подписаться через Subscribe
+type Event struct {
+	Type      EventType
+	AccountID int64
+	Phone     types.Phone
+	Amount    types.Money
+	Balance   types.Money
+	PaymentID string
+	Category  types.PaymentCategory
+	//Counterparty телефон второй стороны запроса денег
+	Counterparty types.Phone
+	//Threshold пройденный порог бюджета в процентах
+	Threshold int
+	Created   int64
+}
+
+//Subscribe добавляет обработчик событий. Обработчики вызываются синхронно в порядке подписки
+func (s *Service) Subscribe(handler func(event Event)) {
+	s.handlers = append(s.handlers, handler)
+}
+
+//SetLowBalanceThreshold задает порог, при переходе через который после платежа отправляется EventLowBalance. 0 отключает событие
+func (s *Service) SetLowBalanceThreshold(threshold types.Money) {
+	s.lowBalance = threshold
+}
+
+func (s *Service) emit(eventType EventType, account *types.Account, amount types.Money, payment *types.Payment) {
+	if len(s.handlers) == 0 {
+		return
+	}
+	event := Event{
+		Type:      eventType,
+		AccountID: account.ID,
+		Phone:     account.Phone,
+		Amount:    amount,
+		Balance:   account.Balance,
+		Created:   s.now().Unix(),
+	}
+	if payment != nil {
+		event.PaymentID = payment.ID
+		event.Category = payment.Category
+	}
+	s.emitEvent(event)
+}
+
+func (s *Service) emitEvent(event Event) {
+	for _, handler := range s.handlers {
+		handler(event)
+	}
+}
+
+//emitLowBalance отправляет EventLowBalance, если баланс опустился ниже порога
+func (s *Service) emitLowBalance(account *types.Account, before types.Money, payment *types.Payment) {
+	if s.lowBalance > 0 && before >= s.lowBalance && account.Balance < s.lowBalance {
+		s.emit(EventLowBalance, account, account.Balance, payment)
+	}
+}
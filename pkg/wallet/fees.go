@@ -0,0 +1,91 @@
+package wallet
+
+import (
+	"errors"
+	"math"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+var ErrInvalidFeeRule = errors.New("invalid fee rule")
+
+//SetFeeRules заменяет правила комиссий. Для платежа применяется первое подходящее правило, без правил комиссия не берется
+func (s *Service) SetFeeRules(rules []types.FeeRule) error {
+	for _, rule := range rules {
+		if rule.Flat < 0 || rule.Percent < 0 || rule.MinFee < 0 || rule.MaxFee < 0 || rule.MinAmount < 0 ||
+			rule.MaxAmount < 0 || rule.MaxAmount > 0 && rule.MaxAmount < rule.MinAmount ||
+			rule.MaxFee > 0 && rule.MaxFee < rule.MinFee {
+			return ErrInvalidFeeRule
+		}
+	}
+	s.feeRules = append([]types.FeeRule(nil), rules...)
+	return nil
+}
+
+//SetAccountType задает тип счета для правил комиссий
+func (s *Service) SetAccountType(accountID int64, accountType types.AccountType) error {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	account.Type = accountType
+	return nil
+}
+
+//CalculateFee возвращает комиссию, которая будет удержана за платеж
+func (s *Service) CalculateFee(accountID int64, amount types.Money, category types.PaymentCategory) (types.Money, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return 0, err
+	}
+	return s.fee(account, amount, category), nil
+}
+
+func (s *Service) fee(account *types.Account, amount types.Money, category types.PaymentCategory) types.Money {
+	accountType := account.Type
+	if accountType == "" {
+		accountType = types.AccountPersonal
+	}
+	for _, rule := range s.feeRules {
+		if rule.Category != "" && rule.Category != category {
+			continue
+		}
+		if rule.AccountType != "" && rule.AccountType != accountType {
+			continue
+		}
+		if amount < rule.MinAmount || rule.MaxAmount > 0 && amount > rule.MaxAmount {
+			continue
+		}
+		fee := rule.Flat + types.Money(math.Round(float64(amount)*rule.Percent/100))
+		if fee < rule.MinFee {
+			fee = rule.MinFee
+		}
+		if rule.MaxFee > 0 && fee > rule.MaxFee {
+			fee = rule.MaxFee
+		}
+		return fee
+	}
+	return 0
+}
+
+//refundFee возвращает комиссию пропорционально невозвращенной части платежа
+func (s *Service) refundFee(account *types.Account, payment *types.Payment) {
+	if payment.Fee <= 0 || payment.Amount <= 0 {
+		return
+	}
+	amount := payment.Fee * (payment.Amount - payment.Refunded) / payment.Amount
+	if amount <= 0 {
+		return
+	}
+	payment.Fee -= amount
+	s.post(account, amount, types.PostingFeeRefund, payment.ID)
+}
+
+//GroupFees группирует платежи с комиссией по ключу key и считает статистику по удержанным комиссиям
+func (s *Service) GroupFees(key KeyFunc, goroutines int) []Aggregate {
+	return s.group(key, func(payment types.Payment) bool {
+		return payment.Fee != 0
+	}, func(payment types.Payment) types.Money {
+		return payment.Fee
+	}, goroutines)
+}
@@ -0,0 +1,138 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+var testFeeRules = []types.FeeRule{
+	{Category: "transfer", AccountType: types.AccountBusiness, Percent: 0.5},
+	{Category: "transfer", MaxAmount: 1_000_00, Flat: 1_00},
+	{Category: "transfer", MinAmount: 1_000_01, Percent: 1, MinFee: 5_00, MaxFee: 50_00},
+	{Category: "atm", Flat: 2_00, Percent: 1.5, MinFee: 3_00},
+}
+
+func TestService_CalculateFee(t *testing.T) {
+	s := newTestService()
+	account, _ := s.RegisterAccount("+992928885522")
+	_ = s.SetFeeRules(testFeeRules)
+	tests := []struct {
+		amount   types.Money
+		category types.PaymentCategory
+		want     types.Money
+	}{
+		{500_00, "transfer", 1_00},
+		{1_000_00, "transfer", 1_00},
+		{2_000_00, "transfer", 20_00},
+		{1_000_10, "transfer", 10_00},
+		{100_000_00, "transfer", 50_00},
+		{10_00, "atm", 3_00},
+		{1_000_00, "atm", 17_00},
+		{1_000_00, "food", 0},
+	}
+	for _, tt := range tests {
+		if got, _ := s.CalculateFee(account.ID, tt.amount, tt.category); got != tt.want {
+			t.Errorf("CalculateFee(%v, %v): got %v, want %v", tt.amount, tt.category, got, tt.want)
+		}
+	}
+
+	_ = s.SetAccountType(account.ID, types.AccountBusiness)
+	if got, _ := s.CalculateFee(account.ID, 100_000_00, "transfer"); got != 500_00 {
+		t.Errorf("CalculateFee(): business got %v, want %v", got, types.Money(500_00))
+	}
+	if _, err := s.CalculateFee(100, 1, "atm"); err != ErrAccountNotFound {
+		t.Errorf("CalculateFee(): must return ErrAccountNotFound, returned = %v", err)
+	}
+	if err := s.SetFeeRules([]types.FeeRule{{MinFee: 10, MaxFee: 5}}); err != ErrInvalidFeeRule {
+		t.Errorf("SetFeeRules(): must return ErrInvalidFeeRule, returned = %v", err)
+	}
+}
+
+func TestService_Pay_fee(t *testing.T) {
+	s := newTestService()
+	account, _ := s.addAccountWithBalance("+992928885522", 1_000_00)
+	_ = s.SetFeeRules(testFeeRules)
+	if _, err := s.Pay(account.ID, 1_000_00, "transfer"); err != ErrNotEnoughBalance {
+		t.Errorf("Pay(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	payment, err := s.Pay(account.ID, 500_00, "transfer")
+	if err != nil {
+		t.Fatalf("Pay(): error = %v", err)
+	}
+	if payment.Fee != 1_00 || payment.Amount != 500_00 || account.Balance != 499_00 {
+		t.Errorf("Pay(): payment = %v, balance = %v", payment, account.Balance)
+	}
+	postings, _ := s.AccountPostings(account.ID)
+	last := postings[len(postings)-1]
+	if last.Kind != types.PostingFee || last.Amount != -1_00 || last.PaymentID != payment.ID {
+		t.Errorf("AccountPostings(): last = %v", last)
+	}
+
+	hold, _ := s.Authorize(account.ID, 100_00, "atm")
+	if hold.Fee != 3_50 || account.Available() != 395_50 {
+		t.Errorf("Authorize(): hold = %v, available = %v", hold, account.Available())
+	}
+	withdrawal, _ := s.Capture(hold.ID, 50_00)
+	if withdrawal.Fee != 3_00 || account.Balance != 446_00 || account.Held != 0 {
+		t.Errorf("Capture(): payment = %v, account = %+v", withdrawal, account)
+	}
+}
+
+func TestService_Reject_fee(t *testing.T) {
+	s := newTestService()
+	account, _ := s.addAccountWithBalance("+992928885522", 1_000_00)
+	_ = s.SetFeeRules(testFeeRules)
+	payment, _ := s.Pay(account.ID, 200_00, "atm")
+	if payment.Fee != 5_00 {
+		t.Fatalf("Pay(): fee = %v", payment.Fee)
+	}
+	_ = s.Complete(payment.ID)
+	_, _ = s.Refund(payment.ID, 120_00, "")
+	err := s.Reject(payment.ID)
+	if err != nil {
+		t.Fatalf("Reject(): error = %v", err)
+	}
+	//возвращается комиссия с невозвращенных 80.00 из 200.00
+	if payment.Fee != 3_00 || account.Balance != 1_000_00-3_00 {
+		t.Errorf("Reject(): payment = %v, balance = %v", payment, account.Balance)
+	}
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	for i := 0; i < 2; i++ {
+		err = imported.Import(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, _ := imported.FindPaymentByID(payment.ID)
+	if !reflect.DeepEqual(got, payment) {
+		t.Errorf("Import(): payment = %v, want %v", got, payment)
+	}
+}
+
+func TestService_GroupFees(t *testing.T) {
+	s := newTestService()
+	account, _ := s.addAccountWithBalance("+992928885522", 10_000_00)
+	_ = s.SetFeeRules(testFeeRules)
+	_, _ = s.Pay(account.ID, 100_00, "transfer")
+	_, _ = s.Pay(account.ID, 300_00, "transfer")
+	_, _ = s.Pay(account.ID, 2_000_00, "transfer")
+	_, _ = s.Pay(account.ID, 100_00, "atm")
+	_, _ = s.Pay(account.ID, 100_00, "food")
+
+	got := s.GroupFees(ByCategory, 2)
+	want := []Aggregate{
+		{Key: "atm", Count: 1, Sum: 3_50, Avg: 3_50, Median: 3_50, P95: 3_50},
+		{Key: "transfer", Count: 3, Sum: 22_00, Avg: 7_33, Median: 1_00, P95: 20_00},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupFees(): got %+v, want %+v", got, want)
+	}
+}
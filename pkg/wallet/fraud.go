@@ -0,0 +1,273 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPaymentBlocked     = errors.New("payment blocked by fraud rule")
+	ErrPaymentNotInReview = errors.New("payment is not in review")
+	ErrInvalidFraudRule   = errors.New("invalid fraud rule")
+)
+
+//FraudAction представляет решение антифрод-проверки
+type FraudAction string
+
+//Предопределенные решения. При срабатывании нескольких правил побеждает самое строгое
+const (
+	FraudAllow  FraudAction = "allow"
+	FraudReview FraudAction = "review"
+	FraudBlock  FraudAction = "block"
+)
+
+//FraudRuleKind представляет тип правила
+type FraudRuleKind string
+
+//Предопределенные типы правил
+const (
+	//FraudAboveAverage - сумма больше Multiplier средних платежей счета, если их не меньше MinPayments
+	FraudAboveAverage FraudRuleKind = "above_average"
+	//FraudRepeats - за Window уже было не меньше Count одинаковых платежей (повторов)
+	FraudRepeats FraudRuleKind = "repeats"
+	//FraudNewAccount - платеж не меньше Amount в течение Window после первого пополнения счета
+	FraudNewAccount FraudRuleKind = "new_account"
+)
+
+//Duration - time.Duration, который в JSON записывается строкой, например "10m"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	err := json.Unmarshal(data, &str)
+	if err != nil {
+		return err
+	}
+	value, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = Duration(value)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+//FraudRule описывает одно правило антифрода. Используются только поля, нужные для Kind
+type FraudRule struct {
+	Name        string        `json:"name"`
+	Kind        FraudRuleKind `json:"kind"`
+	Action      FraudAction   `json:"action"`
+	Multiplier  float64       `json:"multiplier,omitempty"`
+	MinPayments int           `json:"min_payments,omitempty"`
+	Count       int           `json:"count,omitempty"`
+	Window      Duration      `json:"window,omitempty"`
+	Amount      types.Money   `json:"amount,omitempty"`
+}
+
+//FraudResult - итог проверки платежа: решение и имена сработавших правил
+type FraudResult struct {
+	Action FraudAction
+	Rules  []string
+}
+
+//Reason возвращает причину для сохранения в платеже
+func (r FraudResult) Reason() string {
+	return strings.Join(r.Rules, ",")
+}
+
+//SetFraudRules заменяет набор правил. Пустой набор отключает проверку
+func (s *Service) SetFraudRules(rules []FraudRule) error {
+	for _, rule := range rules {
+		err := validateFraudRule(rule)
+		if err != nil {
+			return err
+		}
+	}
+	s.fraudRules = append([]FraudRule(nil), rules...)
+	return nil
+}
+
+//LoadFraudRules читает правила из JSON файла со списком FraudRule
+func (s *Service) LoadFraudRules(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var rules []FraudRule
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return err
+	}
+	return s.SetFraudRules(rules)
+}
+
+func validateFraudRule(rule FraudRule) error {
+	if rule.Name == "" || strings.ContainsAny(rule.Name, ";,\n") {
+		return ErrInvalidFraudRule
+	}
+	switch rule.Action {
+	case FraudAllow, FraudReview, FraudBlock:
+	default:
+		return ErrInvalidFraudRule
+	}
+	switch rule.Kind {
+	case FraudAboveAverage:
+		if rule.Multiplier <= 0 {
+			return ErrInvalidFraudRule
+		}
+	case FraudRepeats:
+		if rule.Count <= 0 || rule.Window <= 0 {
+			return ErrInvalidFraudRule
+		}
+	case FraudNewAccount:
+		if rule.Window <= 0 {
+			return ErrInvalidFraudRule
+		}
+	default:
+		return ErrInvalidFraudRule
+	}
+	return nil
+}
+
+//CheckFraud оценивает будущий платеж по текущим правилам, не изменяя состояние сервиса
+func (s *Service) CheckFraud(accountID int64, amount types.Money, category types.PaymentCategory) (FraudResult, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return FraudResult{}, err
+	}
+	return s.checkFraud(accountID, amount, category), nil
+}
+
+func (s *Service) checkFraud(accountID int64, amount types.Money, category types.PaymentCategory) FraudResult {
+	result := FraudResult{Action: FraudAllow}
+	for _, rule := range s.fraudRules {
+		if !s.fraudRuleMatches(rule, accountID, amount, category) {
+			continue
+		}
+		result.Rules = append(result.Rules, rule.Name)
+		if fraudSeverity(rule.Action) > fraudSeverity(result.Action) {
+			result.Action = rule.Action
+		}
+	}
+	return result
+}
+
+func fraudSeverity(action FraudAction) int {
+	switch action {
+	case FraudBlock:
+		return 2
+	case FraudReview:
+		return 1
+	}
+	return 0
+}
+
+func (s *Service) fraudRuleMatches(rule FraudRule, accountID int64, amount types.Money, category types.PaymentCategory) bool {
+	now := s.now()
+	since := now.Add(-time.Duration(rule.Window)).Unix()
+	switch rule.Kind {
+	case FraudAboveAverage:
+		count, total := 0, types.Money(0)
+		for _, payment := range s.payments {
+			if payment.AccountID == accountID && payment.Status != types.PaymentStatusFail {
+				count++
+				total += payment.Amount
+			}
+		}
+		if count == 0 || count < rule.MinPayments {
+			return false
+		}
+		return float64(amount) > rule.Multiplier*float64(total)/float64(count)
+	case FraudRepeats:
+		count := 0
+		for _, payment := range s.payments {
+			if payment.AccountID == accountID && payment.Amount == amount && payment.Category == category && payment.Created > since {
+				count++
+			}
+		}
+		return count >= rule.Count
+	case FraudNewAccount:
+		if amount < rule.Amount {
+			return false
+		}
+		for _, posting := range s.postings {
+			if posting.AccountID == accountID && posting.Kind == types.PostingDeposit {
+				return posting.Created > since
+			}
+		}
+	}
+	return false
+}
+
+//block сохраняет отклоненный антифродом платеж со статусом FAIL без списания средств
+func (s *Service) block(account *types.Account, amount types.Money, category types.PaymentCategory, reason string) *types.Payment {
+	payment := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: account.ID,
+		Amount:    amount,
+		Category:  category,
+		Status:    types.PaymentStatusFail,
+		Created:   s.now().Unix(),
+		Reason:    reason,
+	}
+	s.payments = append(s.payments, payment)
+	return payment
+}
+
+//ReviewQueue возвращает платежи, ожидающие ручной проверки, в порядке создания
+func (s *Service) ReviewQueue() []types.Payment {
+	var payments []types.Payment
+	for _, payment := range s.payments {
+		if payment.Status == types.PaymentStatusReview {
+			payments = append(payments, *payment)
+		}
+	}
+	return payments
+}
+
+//ApproveReview пропускает платеж из очереди проверки
+func (s *Service) ApproveReview(paymentID string) error {
+	payment, err := s.reviewPayment(paymentID)
+	if err != nil {
+		return err
+	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return err
+	}
+	payment.Status = types.PaymentStatusInProgress
+	s.accept(account, payment)
+	return nil
+}
+
+//DeclineReview отклоняет платеж из очереди проверки и возвращает средства на счет
+func (s *Service) DeclineReview(paymentID string, reason string) error {
+	payment, err := s.reviewPayment(paymentID)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		payment.Reason = dumpText(reason)
+	}
+	return s.Reject(paymentID)
+}
+
+func (s *Service) reviewPayment(paymentID string) (*types.Payment, error) {
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != types.PaymentStatusReview {
+		return nil, ErrPaymentNotInReview
+	}
+	return payment, nil
+}
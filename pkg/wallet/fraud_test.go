@@ -0,0 +1,177 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func TestService_Pay_fraudBlock(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 10_000_00)
+	err := s.SetFraudRules([]FraudRule{
+		{Name: "new-account", Kind: FraudNewAccount, Action: FraudBlock, Window: Duration(24 * time.Hour), Amount: 5_000_00},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := s.Pay(account.ID, 6_000_00, "transfer")
+	if err != ErrPaymentBlocked {
+		t.Fatalf("Pay(): must return ErrPaymentBlocked, returned = %v", err)
+	}
+	if payment.Status != types.PaymentStatusFail || payment.Reason != "new-account" || account.Balance != 10_000_00 {
+		t.Errorf("Pay(): payment = %v, balance = %v", payment, account.Balance)
+	}
+	//отклонение заблокированного платежа не возвращает деньги повторно
+	_ = s.Reject(payment.ID)
+	if account.Balance != 10_000_00 {
+		t.Errorf("Reject(): balance = %v", account.Balance)
+	}
+	if _, err := s.Pay(account.ID, 1_000_00, "transfer"); err != nil {
+		t.Errorf("Pay(): small amount, error = %v", err)
+	}
+
+	*now = now.Add(25 * time.Hour)
+	if _, err := s.Pay(account.ID, 6_000_00, "transfer"); err != nil {
+		t.Errorf("Pay(): after window, error = %v", err)
+	}
+}
+
+func TestService_Pay_fraudRepeats(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 10_000_00)
+	_ = s.SetFraudRules([]FraudRule{
+		{Name: "repeats", Kind: FraudRepeats, Action: FraudBlock, Count: 3, Window: Duration(10 * time.Minute)},
+	})
+	payment, _ := s.Pay(account.ID, 10_00, "game")
+	for i := 0; i < 2; i++ {
+		*now = now.Add(time.Minute)
+		if _, err := s.Repeat(payment.ID); err != nil {
+			t.Fatalf("Repeat(): error = %v", err)
+		}
+	}
+	if _, err := s.Repeat(payment.ID); err != ErrPaymentBlocked {
+		t.Errorf("Repeat(): must return ErrPaymentBlocked, returned = %v", err)
+	}
+	if _, err := s.Pay(account.ID, 10_00, "food"); err != nil {
+		t.Errorf("Pay(): other category, error = %v", err)
+	}
+	*now = now.Add(15 * time.Minute)
+	if _, err := s.Repeat(payment.ID); err != nil {
+		t.Errorf("Repeat(): after window, error = %v", err)
+	}
+}
+
+func TestService_Pay_fraudReview(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	var events []Event
+	s.Subscribe(func(event Event) { events = append(events, event) })
+	_ = s.SetFraudRules([]FraudRule{
+		{Name: "above-average", Kind: FraudAboveAverage, Action: FraudReview, Multiplier: 5, MinPayments: 2},
+		{Name: "repeats", Kind: FraudRepeats, Action: FraudAllow, Count: 1, Window: Duration(time.Hour)},
+	})
+	_, _ = s.Pay(account.ID, 10_00, "food")
+	if _, err := s.Pay(account.ID, 100_00, "food"); err != nil {
+		t.Fatalf("Pay(): min payments not reached, error = %v", err)
+	}
+
+	first, err := s.Pay(account.ID, 1_000_00, "food")
+	if err != nil || first.Status != types.PaymentStatusReview || first.Reason != "above-average" {
+		t.Fatalf("Pay(): payment = %v, error = %v", first, err)
+	}
+	second, _ := s.Pay(account.ID, 2_000_00, "food")
+	if second.Status != types.PaymentStatusReview {
+		t.Fatalf("Pay(): payment = %v", second)
+	}
+	if len(events) != 2 {
+		t.Errorf("Pay(): review payments must not emit events, got %v", events)
+	}
+	result, _ := s.CheckFraud(account.ID, 100_00, "food")
+	if !reflect.DeepEqual(result, FraudResult{Action: FraudAllow, Rules: []string{"repeats"}}) {
+		t.Errorf("CheckFraud(): got %v", result)
+	}
+
+	queue := s.ReviewQueue()
+	if len(queue) != 2 || queue[0].ID != first.ID || queue[1].ID != second.ID {
+		t.Errorf("ReviewQueue(): got %v", queue)
+	}
+	if err := s.ApproveReview(first.ID); err != nil || first.Status != types.PaymentStatusInProgress {
+		t.Errorf("ApproveReview(): payment = %v, error = %v", first, err)
+	}
+	if err := s.DeclineReview(second.ID, "card; stolen"); err != nil || second.Status != types.PaymentStatusFail || second.Reason != "card, stolen" {
+		t.Errorf("DeclineReview(): payment = %v, error = %v", second, err)
+	}
+	if err := s.ApproveReview(second.ID); err != ErrPaymentNotInReview {
+		t.Errorf("ApproveReview(): must return ErrPaymentNotInReview, returned = %v", err)
+	}
+	if account.Balance != 10_000_00-10_00-100_00-1_000_00 || len(s.ReviewQueue()) != 0 {
+		t.Errorf("balance = %v, queue = %v", account.Balance, s.ReviewQueue())
+	}
+	if len(events) != 4 || events[2].Type != EventPayment || events[3].Type != EventReject {
+		t.Errorf("events = %v", events)
+	}
+}
+
+func TestService_LoadFraudRules(t *testing.T) {
+	s := newTestService()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	data := `[
+		{"name": "big", "kind": "above_average", "action": "review", "multiplier": 10, "min_payments": 5},
+		{"name": "fast", "kind": "repeats", "action": "block", "count": 5, "window": "10m"}
+	]`
+	_ = ioutil.WriteFile(path, []byte(data), 0644)
+	err := s.LoadFraudRules(path)
+	if err != nil {
+		t.Fatalf("LoadFraudRules(): error = %v", err)
+	}
+	want := []FraudRule{
+		{Name: "big", Kind: FraudAboveAverage, Action: FraudReview, Multiplier: 10, MinPayments: 5},
+		{Name: "fast", Kind: FraudRepeats, Action: FraudBlock, Count: 5, Window: Duration(10 * time.Minute)},
+	}
+	if !reflect.DeepEqual(s.fraudRules, want) {
+		t.Errorf("LoadFraudRules(): got %v", s.fraudRules)
+	}
+
+	for _, data := range []string{
+		`[{"name": "x", "kind": "repeats", "action": "block", "count": 5}]`,
+		`[{"name": "x", "kind": "unknown", "action": "block"}]`,
+		`[{"name": "x;y", "kind": "above_average", "action": "block", "multiplier": 2}]`,
+	} {
+		_ = ioutil.WriteFile(path, []byte(data), 0644)
+		if err := s.LoadFraudRules(path); err != ErrInvalidFraudRule {
+			t.Errorf("LoadFraudRules(%s): must return ErrInvalidFraudRule, returned = %v", data, err)
+		}
+	}
+	_ = ioutil.WriteFile(path, []byte(`[{"name": "x", "kind": "repeats", "action": "block", "count": 5, "window": "soon"}]`), 0644)
+	if err := s.LoadFraudRules(path); err == nil {
+		t.Error("LoadFraudRules(): must return error for invalid window")
+	}
+	if len(s.fraudRules) != 2 {
+		t.Errorf("LoadFraudRules(): rules must not change on error, got %v", s.fraudRules)
+	}
+}
+
+func TestService_Import_paymentReason(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	_ = s.SetFraudRules([]FraudRule{{Name: "all", Kind: FraudAboveAverage, Action: FraudBlock, Multiplier: 0.5}})
+	_, _ = s.Pay(account.ID, 10_00, "food")
+	blocked, _ := s.Pay(account.ID, 10_00, "food")
+
+	dir := t.TempDir()
+	err := s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := imported.FindPaymentByID(blocked.ID)
+	if err != nil || !reflect.DeepEqual(payment, blocked) {
+		t.Errorf("Import(): got %v, want %v", payment, blocked)
+	}
+}
@@ -0,0 +1,151 @@
+package wallet
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+//SortField задает поле, по которому упорядочивается история. При равенстве поля платежи упорядочиваются по ID
+type SortField string
+
+const (
+	SortByTime   SortField = "time"
+	SortByAmount SortField = "amount"
+	SortByID     SortField = "id"
+)
+
+//HistoryQuery описывает запрос страницы истории платежей
+type HistoryQuery struct {
+	//AccountID счет, 0 - все счета
+	AccountID int64
+	//Filter дополнительный фильтр, может быть nil
+	Filter func(payment types.Payment) bool
+	//Sort поле сортировки, по умолчанию время
+	Sort SortField
+	Desc bool
+	//Limit размер страницы, 0 - без ограничения
+	Limit int
+	//Offset число пропускаемых платежей после курсора
+	Offset int
+	//Cursor значение NextCursor предыдущей страницы
+	Cursor string
+}
+
+//HistoryPage представляет страницу истории
+type HistoryPage struct {
+	Payments []types.Payment
+	//Total число всех платежей, подходящих под запрос
+	Total int
+	//NextCursor курсор следующей страницы, пустой на последней странице
+	NextCursor string
+}
+
+//QueryHistory возвращает страницу истории платежей в стабильном порядке
+func (s *Service) QueryHistory(q HistoryQuery, goroutines int) (*HistoryPage, error) {
+	if q.Sort == "" {
+		q.Sort = SortByTime
+	}
+	if q.Sort != SortByTime && q.Sort != SortByAmount && q.Sort != SortByID {
+		return nil, fmt.Errorf("unknown sort field %q", q.Sort)
+	}
+	if q.AccountID != 0 {
+		_, err := s.FindAccountByID(q.AccountID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filter := func(payment types.Payment) bool {
+		if q.AccountID != 0 && payment.AccountID != q.AccountID {
+			return false
+		}
+		return q.Filter == nil || q.Filter(payment)
+	}
+	res, err := s.MapReduce(context.Background(), ScanOptions{Workers: goroutines}, filterChunk(filter), appendPayments, []types.Payment(nil))
+	if err != nil {
+		return nil, err
+	}
+	payments := res.([]types.Payment)
+	sort.Slice(payments, func(i, j int) bool {
+		return q.less(payments[i], payments[j])
+	})
+
+	page := &HistoryPage{Total: len(payments)}
+	if q.Cursor != "" {
+		after, err := q.decodeCursor()
+		if err != nil {
+			return nil, err
+		}
+		payments = payments[sort.Search(len(payments), func(i int) bool {
+			return q.less(after, payments[i])
+		}):]
+	}
+	if q.Offset > 0 {
+		if q.Offset > len(payments) {
+			q.Offset = len(payments)
+		}
+		payments = payments[q.Offset:]
+	}
+	if q.Limit > 0 && q.Limit < len(payments) {
+		payments = payments[:q.Limit]
+		page.NextCursor = q.encodeCursor(payments[len(payments)-1])
+	}
+	page.Payments = payments
+	if page.Payments == nil {
+		page.Payments = []types.Payment{}
+	}
+	return page, nil
+}
+
+func (q HistoryQuery) key(payment types.Payment) int64 {
+	switch q.Sort {
+	case SortByAmount:
+		return int64(payment.Amount)
+	case SortByTime:
+		return payment.Created
+	}
+	return 0
+}
+
+func (q HistoryQuery) less(a, b types.Payment) bool {
+	ka, kb := q.key(a), q.key(b)
+	if q.Desc {
+		ka, kb = kb, ka
+		a, b = b, a
+	}
+	if ka != kb {
+		return ka < kb
+	}
+	return a.ID < b.ID
+}
+
+//encodeCursor кодирует позицию платежа вместе с порядком сортировки, чтобы курсор нельзя было применить к другому порядку
+func (q HistoryQuery) encodeCursor(payment types.Payment) string {
+	raw := fmt.Sprint(q.Sort, ";", q.Desc, ";", q.key(payment), ";", payment.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func (q HistoryQuery) decodeCursor() (types.Payment, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return types.Payment{}, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ";", 4)
+	if len(parts) != 4 || parts[0] != string(q.Sort) || parts[1] != fmt.Sprint(q.Desc) {
+		return types.Payment{}, ErrInvalidCursor
+	}
+	key, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return types.Payment{}, ErrInvalidCursor
+	}
+	return types.Payment{ID: parts[3], Amount: types.Money(key), Created: key}, nil
+}
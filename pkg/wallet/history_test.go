@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+//addHistory добавляет счету 95 платежей с повторяющимися временем и суммами и столько же платежей другому счету
+func (s *testService) addHistory(t *testing.T, account *types.Account, now *time.Time) {
+	other, err := s.addAccountWithBalance("+992928885523", 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 95; i++ {
+		if i%10 != 0 {
+			*now = now.Add(time.Minute)
+		}
+		_, _ = s.Pay(account.ID, types.Money(i%7+1), "food")
+		_, _ = s.Pay(other.ID, 1, "food")
+	}
+}
+
+func TestService_QueryHistory_cursor(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 1_000_000)
+	s.addHistory(t, account, now)
+	for _, sort := range []SortField{SortByTime, SortByAmount, SortByID} {
+		for _, desc := range []bool{false, true} {
+			q := HistoryQuery{AccountID: account.ID, Sort: sort, Desc: desc}
+			all, err := s.QueryHistory(q, 4)
+			if err != nil {
+				t.Fatalf("QueryHistory(): error = %v", err)
+			}
+			if len(all.Payments) != 95 || all.NextCursor != "" {
+				t.Fatalf("QueryHistory(): got %d payments, cursor %q", len(all.Payments), all.NextCursor)
+			}
+			for i := 1; i < len(all.Payments); i++ {
+				if q.less(all.Payments[i], all.Payments[i-1]) {
+					t.Fatalf("QueryHistory(%s, %v): payments not sorted at %d", sort, desc, i)
+				}
+			}
+
+			var pages []types.Payment
+			q.Limit = 10
+			for {
+				page, err := s.QueryHistory(q, 3)
+				if err != nil {
+					t.Fatalf("QueryHistory(): error = %v", err)
+				}
+				pages = append(pages, page.Payments...)
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+			if !reflect.DeepEqual(pages, all.Payments) {
+				t.Errorf("QueryHistory(%s, %v): paged result differs from full result", sort, desc)
+			}
+		}
+	}
+}
+
+func TestService_QueryHistory_offset(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 1_000_000)
+	s.addHistory(t, account, now)
+	all, _ := s.QueryHistory(HistoryQuery{AccountID: account.ID, Sort: SortByAmount}, 2)
+	page, err := s.QueryHistory(HistoryQuery{AccountID: account.ID, Sort: SortByAmount, Offset: 90, Limit: 10}, 2)
+	if err != nil {
+		t.Errorf("QueryHistory(): error = %v", err)
+		return
+	}
+	if page.Total != 95 || page.NextCursor != "" || !reflect.DeepEqual(page.Payments, all.Payments[90:]) {
+		t.Errorf("QueryHistory(): wrong page total = %d, cursor = %q, payments = %d", page.Total, page.NextCursor, len(page.Payments))
+	}
+}
+
+func TestService_QueryHistory_errors(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 1_000_000)
+	s.addHistory(t, account, now)
+	page, _ := s.QueryHistory(HistoryQuery{AccountID: account.ID, Limit: 5}, 2)
+
+	_, err := s.QueryHistory(HistoryQuery{AccountID: account.ID, Limit: 5, Desc: true, Cursor: page.NextCursor}, 2)
+	if err != ErrInvalidCursor {
+		t.Errorf("QueryHistory(): must return ErrInvalidCursor, returned = %v", err)
+	}
+	_, err = s.QueryHistory(HistoryQuery{AccountID: account.ID, Cursor: "???"}, 2)
+	if err != ErrInvalidCursor {
+		t.Errorf("QueryHistory(): must return ErrInvalidCursor, returned = %v", err)
+	}
+	_, err = s.QueryHistory(HistoryQuery{AccountID: 100}, 2)
+	if err != ErrAccountNotFound {
+		t.Errorf("QueryHistory(): must return ErrAccountNotFound, returned = %v", err)
+	}
+}
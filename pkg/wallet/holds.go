@@ -0,0 +1,232 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrHoldNotFound       = errors.New("hold not found")
+	ErrHoldNotActive      = errors.New("hold is not active")
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds hold")
+)
+
+//DefaultHoldTTL - время жизни холда по умолчанию
+const DefaultHoldTTL = 7 * 24 * time.Hour
+
+//SetHoldTTL задает время, через которое неиспользованный холд снимается автоматически
+func (s *Service) SetHoldTTL(ttl time.Duration) {
+	s.holdTTL = ttl
+}
+
+//Authorize резервирует сумму на счете. Лимиты проверяются при резервировании, антифрод и подтверждение кодом - при списании
+func (s *Service) Authorize(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Hold, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	s.ExpireHolds()
+	err = s.checkLimits(account.ID, amount, category)
+	if err != nil {
+		return nil, err
+	}
+	fee := s.fee(account, amount, category)
+	err = s.checkFunds(account, amount+fee)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := s.holdTTL
+	if ttl <= 0 {
+		ttl = DefaultHoldTTL
+	}
+	now := s.now()
+	hold := &types.Hold{
+		ID:        uuid.New().String(),
+		AccountID: account.ID,
+		Amount:    amount,
+		Category:  category,
+		Status:    types.HoldActive,
+		Created:   now.Unix(),
+		Expires:   now.Add(ttl).Unix(),
+		Fee:       fee,
+	}
+	account.Held += amount + fee
+	s.holds = append(s.holds, hold)
+	return hold, nil
+}
+
+//Capture списывает по холду сумму не больше зарезервированной вместе с комиссией и создает платеж.
+//Остаток холда освобождается, повторное списание по тому же холду невозможно
+func (s *Service) Capture(holdID string, amount types.Money) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	hold, err := s.activeHold(holdID)
+	if err != nil {
+		return nil, err
+	}
+	if amount > hold.Amount {
+		return nil, ErrCaptureExceedsHold
+	}
+	account, err := s.FindAccountByID(hold.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	account.Held -= hold.Amount + hold.Fee
+	fee := s.fee(account, amount, hold.Category)
+	err = s.checkFunds(account, amount+fee)
+	if err != nil {
+		account.Held += hold.Amount + hold.Fee
+		return nil, err
+	}
+	var payment *types.Payment
+	before := account.Balance
+	fraud := s.checkFraud(account.ID, amount, hold.Category)
+	switch {
+	case fraud.Action == FraudBlock:
+		hold.Status = types.HoldVoided
+		payment = s.block(account, amount, hold.Category, fraud.Reason())
+		hold.PaymentID = payment.ID
+		return payment, ErrPaymentBlocked
+	case fraud.Action == FraudReview:
+		payment = s.debit(account, amount, fee, hold.Category, types.PaymentStatusReview, types.PostingPayment)
+		payment.Reason = fraud.Reason()
+	case s.requiresConfirmation(account.ID, amount):
+		payment, err = s.payPending(account, amount, fee, hold.Category, types.PostingPayment)
+		if err != nil {
+			account.Held += hold.Amount + hold.Fee
+			return nil, err
+		}
+	default:
+		payment = s.debit(account, amount, fee, hold.Category, types.PaymentStatusInProgress, types.PostingPayment)
+		s.accept(account, payment)
+	}
+	s.emitLowBalance(account, before, payment)
+	hold.Status = types.HoldCaptured
+	hold.Captured = amount
+	hold.PaymentID = payment.ID
+	return payment, nil
+}
+
+//Void снимает холд без списания
+func (s *Service) Void(holdID string) error {
+	hold, err := s.activeHold(holdID)
+	if err != nil {
+		return err
+	}
+	s.release(hold, types.HoldVoided)
+	return nil
+}
+
+//ExpireHolds снимает просроченные холды и возвращает их
+func (s *Service) ExpireHolds() []types.Hold {
+	now := s.now().Unix()
+	var expired []types.Hold
+	for _, hold := range s.holds {
+		if hold.Status == types.HoldActive && hold.Expires <= now {
+			s.release(hold, types.HoldExpired)
+			expired = append(expired, *hold)
+		}
+	}
+	return expired
+}
+
+func (s *Service) FindHoldByID(holdID string) (*types.Hold, error) {
+	for _, hold := range s.holds {
+		if hold.ID == holdID {
+			return hold, nil
+		}
+	}
+	return nil, ErrHoldNotFound
+}
+
+//AccountHolds возвращает активные холды счета
+func (s *Service) AccountHolds(accountID int64) ([]types.Hold, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	s.ExpireHolds()
+	var holds []types.Hold
+	for _, hold := range s.holds {
+		if hold.AccountID == accountID && hold.Status == types.HoldActive {
+			holds = append(holds, *hold)
+		}
+	}
+	return holds, nil
+}
+
+func (s *Service) activeHold(holdID string) (*types.Hold, error) {
+	hold, err := s.FindHoldByID(holdID)
+	if err != nil {
+		return nil, err
+	}
+	s.ExpireHolds()
+	if hold.Status != types.HoldActive {
+		return nil, ErrHoldNotActive
+	}
+	return hold, nil
+}
+
+func (s *Service) release(hold *types.Hold, status types.HoldStatus) {
+	hold.Status = status
+	account, err := s.FindAccountByID(hold.AccountID)
+	if err == nil {
+		account.Held -= hold.Amount + hold.Fee
+	}
+}
+
+func (s *Service) holdLines() []string {
+	var lines []string
+	for _, hold := range s.holds {
+		lines = append(lines, hold.ToString())
+	}
+	return lines
+}
+
+//importHold восстанавливает холд и пересчитывает зарезервированную сумму счета
+func (s *Service) importHold(holdStr []string) {
+	if len(holdStr) < 9 {
+		return
+	}
+	AccountID, _ := strconv.ParseInt(holdStr[1], 10, 64)
+	Amount, _ := strconv.ParseInt(holdStr[2], 10, 64)
+	Created, _ := strconv.ParseInt(holdStr[5], 10, 64)
+	Expires, _ := strconv.ParseInt(holdStr[6], 10, 64)
+	Captured, _ := strconv.ParseInt(holdStr[7], 10, 64)
+	var Fee int64
+	if len(holdStr) > 9 {
+		Fee, _ = strconv.ParseInt(holdStr[9], 10, 64)
+	}
+	hold, err := s.FindHoldByID(holdStr[0])
+	if err != nil {
+		hold = &types.Hold{ID: holdStr[0]}
+		s.holds = append(s.holds, hold)
+	} else if hold.Status == types.HoldActive {
+		s.release(hold, hold.Status)
+	}
+	hold.AccountID = AccountID
+	hold.Amount = types.Money(Amount)
+	hold.Category = types.PaymentCategory(holdStr[3])
+	hold.Status = types.HoldStatus(holdStr[4])
+	hold.Created = Created
+	hold.Expires = Expires
+	hold.Captured = types.Money(Captured)
+	hold.PaymentID = holdStr[8]
+	hold.Fee = types.Money(Fee)
+	if hold.Status == types.HoldActive {
+		account, err := s.FindAccountByID(hold.AccountID)
+		if err == nil {
+			account.Held += hold.Amount + hold.Fee
+		}
+	}
+}
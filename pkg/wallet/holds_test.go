@@ -0,0 +1,152 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func TestService_Authorize(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 1_000_00)
+	hold, err := s.Authorize(account.ID, 700_00, "hotel")
+	if err != nil {
+		t.Fatalf("Authorize(): error = %v", err)
+	}
+	if account.Balance != 1_000_00 || account.Held != 700_00 || account.Available() != 300_00 {
+		t.Errorf("Authorize(): account = %+v", account)
+	}
+	if _, err := s.Authorize(account.ID, 400_00, "hotel"); err != ErrNotEnoughBalance {
+		t.Errorf("Authorize(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	if _, err := s.Pay(account.ID, 400_00, "food"); err != ErrNotEnoughBalance {
+		t.Errorf("Pay(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	if _, err := s.Authorize(account.ID, 0, "hotel"); err != ErrAmountMustBePositive {
+		t.Errorf("Authorize(): must return ErrAmountMustBePositive, returned = %v", err)
+	}
+	if _, err := s.Authorize(100, 10_00, "hotel"); err != ErrAccountNotFound {
+		t.Errorf("Authorize(): must return ErrAccountNotFound, returned = %v", err)
+	}
+	holds, _ := s.AccountHolds(account.ID)
+	if len(holds) != 1 || holds[0] != *hold {
+		t.Errorf("AccountHolds(): got %v", holds)
+	}
+}
+
+func TestService_Capture(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 1_000_00)
+	hold, _ := s.Authorize(account.ID, 700_00, "hotel")
+	if _, err := s.Capture(hold.ID, 800_00); err != ErrCaptureExceedsHold {
+		t.Errorf("Capture(): must return ErrCaptureExceedsHold, returned = %v", err)
+	}
+	payment, err := s.Capture(hold.ID, 500_00)
+	if err != nil {
+		t.Fatalf("Capture(): error = %v", err)
+	}
+	if payment.Amount != 500_00 || payment.Status != types.PaymentStatusInProgress || payment.Category != "hotel" {
+		t.Errorf("Capture(): payment = %v", payment)
+	}
+	if hold.Status != types.HoldCaptured || hold.Captured != 500_00 || hold.PaymentID != payment.ID {
+		t.Errorf("Capture(): hold = %v", hold)
+	}
+	if account.Balance != 500_00 || account.Held != 0 {
+		t.Errorf("Capture(): account = %+v", account)
+	}
+	if _, err := s.Capture(hold.ID, 100_00); err != ErrHoldNotActive {
+		t.Errorf("Capture(): must return ErrHoldNotActive, returned = %v", err)
+	}
+	if _, err := s.Capture("unknown", 100_00); err != ErrHoldNotFound {
+		t.Errorf("Capture(): must return ErrHoldNotFound, returned = %v", err)
+	}
+}
+
+func TestService_Void(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 1_000_00)
+	hold, _ := s.Authorize(account.ID, 700_00, "hotel")
+	if err := s.Void(hold.ID); err != nil {
+		t.Fatalf("Void(): error = %v", err)
+	}
+	if hold.Status != types.HoldVoided || account.Balance != 1_000_00 || account.Held != 0 {
+		t.Errorf("Void(): hold = %v, account = %+v", hold, account)
+	}
+	if err := s.Void(hold.ID); err != ErrHoldNotActive {
+		t.Errorf("Void(): must return ErrHoldNotActive, returned = %v", err)
+	}
+}
+
+func TestService_ExpireHolds(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 1_000_00)
+	s.SetHoldTTL(time.Hour)
+	first, _ := s.Authorize(account.ID, 300_00, "hotel")
+	*now = now.Add(30 * time.Minute)
+	second, _ := s.Authorize(account.ID, 300_00, "hotel")
+
+	*now = now.Add(30 * time.Minute)
+	expired := s.ExpireHolds()
+	if len(expired) != 1 || expired[0].ID != first.ID || first.Status != types.HoldExpired {
+		t.Errorf("ExpireHolds(): got %v", expired)
+	}
+	if account.Held != 300_00 {
+		t.Errorf("ExpireHolds(): held = %v", account.Held)
+	}
+	if _, err := s.Capture(first.ID, 100_00); err != ErrHoldNotActive {
+		t.Errorf("Capture(): must return ErrHoldNotActive, returned = %v", err)
+	}
+
+	//просроченные холды снимаются и без явного вызова ExpireHolds
+	*now = now.Add(30 * time.Minute)
+	if _, err := s.Capture(second.ID, 100_00); err != ErrHoldNotActive {
+		t.Errorf("Capture(): must return ErrHoldNotActive, returned = %v", err)
+	}
+	if second.Status != types.HoldExpired || account.Held != 0 {
+		t.Errorf("Capture(): hold = %v, held = %v", second, account.Held)
+	}
+}
+
+func TestService_Authorize_limits(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 1_000_00)
+	_ = s.SetLimit(types.Limit{AccountID: account.ID, Daily: 500_00})
+	hold, _ := s.Authorize(account.ID, 300_00, "hotel")
+	if _, err := s.Pay(account.ID, 300_00, "food"); err != ErrLimitDaily {
+		t.Errorf("Pay(): must return ErrLimitDaily, returned = %v", err)
+	}
+	_ = s.Void(hold.ID)
+	if _, err := s.Pay(account.ID, 300_00, "food"); err != nil {
+		t.Errorf("Pay(): error = %v", err)
+	}
+}
+
+func TestService_Import_holds(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 1_000_00)
+	active, _ := s.Authorize(account.ID, 200_00, "hotel")
+	captured, _ := s.Authorize(account.ID, 300_00, "hotel")
+	_, _ = s.Capture(captured.ID, 100_00)
+
+	dir := t.TempDir()
+	err := s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//повторный импорт не удваивает резерв
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := imported.FindAccountByID(account.ID)
+	if !reflect.DeepEqual(got, account) {
+		t.Errorf("Import(): account = %+v, want %+v", got, account)
+	}
+	for _, want := range []*types.Hold{active, captured} {
+		hold, err := imported.FindHoldByID(want.ID)
+		if err != nil || !reflect.DeepEqual(hold, want) {
+			t.Errorf("Import(): hold = %v, want %v", hold, want)
+		}
+	}
+}
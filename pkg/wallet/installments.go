@@ -0,0 +1,327 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPlanNotFound = errors.New("installment plan not found")
+	ErrPlanPaid     = errors.New("installment plan is paid")
+	ErrInvalidPlan  = errors.New("invalid installment plan")
+)
+
+//DefaultInstallmentGrace - время после срока платежа, после которого начисляется штраф за просрочку
+const DefaultInstallmentGrace = 3 * 24 * time.Hour
+
+//InstallmentRetryInterval - время, через которое повторяется неудачная попытка оплатить платеж по рассрочке
+const InstallmentRetryInterval = 24 * time.Hour
+
+//InstallmentRun представляет результат попытки оплатить платеж по плану рассрочки
+type InstallmentRun struct {
+	PlanID    string
+	Number    int
+	PaymentID string
+	At        time.Time
+	Err       error
+}
+
+//SetInstallmentLateFee задает штраф для новых планов рассрочки и время после срока, через которое он начисляется
+func (s *Service) SetInstallmentLateFee(fee types.Money, grace time.Duration) {
+	s.lateFee = fee
+	s.lateGrace = grace
+}
+
+//CreateInstallmentPlan оформляет покупку в рассрочку: сумма делится на parts ежемесячных платежей,
+//первый оплачивается сразу. Если первый платеж не создан, план не создается. Платеж, ожидающий подтверждения
+//или проверки, считается оплаченным, когда проходит, а если его отклонят, оплачивается через RunDueInstallments
+func (s *Service) CreateInstallmentPlan(accountID int64, total types.Money, category types.PaymentCategory, parts int) (*types.InstallmentPlan, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if parts < 2 || total < types.Money(parts) {
+		return nil, ErrInvalidPlan
+	}
+	now := s.now()
+	plan := &types.InstallmentPlan{
+		ID:        uuid.New().String(),
+		AccountID: account.ID,
+		Category:  category,
+		Total:     total,
+		Parts:     parts,
+		LateFee:   s.lateFee,
+		Status:    types.PlanActive,
+		Created:   now.Unix(),
+	}
+	var installments []*types.Installment
+	for i, amount := range total.Split(parts) {
+		installments = append(installments, &types.Installment{
+			PlanID: plan.ID,
+			Number: i + 1,
+			Amount: amount,
+			Due:    addMonths(now, i).Unix(),
+		})
+	}
+
+	payment, err := s.Pay(account.ID, installments[0].Amount, category)
+	if err != nil {
+		return nil, err
+	}
+	s.plans = append(s.plans, plan)
+	s.installments = append(s.installments, installments...)
+	s.payInstallments(payment, installments[:1])
+	return plan, nil
+}
+
+//RunDueInstallments пытается оплатить все платежи по рассрочке с наступившим сроком.
+//Неудачная попытка сохраняется в платеже и повторяется не раньше чем через InstallmentRetryInterval,
+//после льготного времени к платежу добавляется штраф
+func (s *Service) RunDueInstallments() []InstallmentRun {
+	now := s.now()
+	grace := s.lateGrace
+	if grace <= 0 {
+		grace = DefaultInstallmentGrace
+	}
+	var runs []InstallmentRun
+	for _, plan := range s.plans {
+		if plan.Status == types.PlanPaid {
+			continue
+		}
+		for _, installment := range s.planInstallments(plan.ID) {
+			if installment.PaymentID != "" || installment.Due > now.Unix() {
+				continue
+			}
+			if installment.LastRun != 0 && now.Unix() < installment.LastRun+int64(InstallmentRetryInterval/time.Second) {
+				continue
+			}
+			if installment.LateFee == 0 && now.Unix() >= installment.Due+int64(grace/time.Second) {
+				installment.LateFee = plan.LateFee
+			}
+			run := InstallmentRun{PlanID: plan.ID, Number: installment.Number, At: now}
+			payment, err := s.Pay(plan.AccountID, installment.Amount+installment.LateFee, plan.Category)
+			if err != nil {
+				run.Err = err
+				installment.LastRun = now.Unix()
+				installment.LastError = err.Error()
+				installment.Failures++
+			} else {
+				run.PaymentID = payment.ID
+				installment.LastError = ""
+				s.payInstallments(payment, []*types.Installment{installment})
+			}
+			runs = append(runs, run)
+		}
+		s.updatePlan(plan)
+	}
+	return runs
+}
+
+//PayOffInstallmentPlan досрочно оплачивает остаток плана одним платежом вместе с начисленными штрафами.
+//Платежи, ожидающие подтверждения или проверки, в остаток не входят
+func (s *Service) PayOffInstallmentPlan(planID string) (*types.Payment, error) {
+	plan, err := s.FindInstallmentPlanByID(planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status == types.PlanPaid {
+		return nil, ErrPlanPaid
+	}
+	var unpaid []*types.Installment
+	rest := types.Money(0)
+	for _, installment := range s.planInstallments(plan.ID) {
+		if installment.PaymentID == "" {
+			unpaid = append(unpaid, installment)
+			rest += installment.Amount + installment.LateFee
+		}
+	}
+	if len(unpaid) == 0 {
+		return nil, ErrPlanPaid
+	}
+	payment, err := s.Pay(plan.AccountID, rest, plan.Category)
+	if err != nil {
+		return payment, err
+	}
+	s.payInstallments(payment, unpaid)
+	return payment, nil
+}
+
+func (s *Service) FindInstallmentPlanByID(planID string) (*types.InstallmentPlan, error) {
+	for _, plan := range s.plans {
+		if plan.ID == planID {
+			return plan, nil
+		}
+	}
+	return nil, ErrPlanNotFound
+}
+
+//AccountInstallmentPlans возвращает планы рассрочки счета
+func (s *Service) AccountInstallmentPlans(accountID int64) ([]types.InstallmentPlan, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	var plans []types.InstallmentPlan
+	for _, plan := range s.plans {
+		if plan.AccountID == accountID {
+			plans = append(plans, *plan)
+		}
+	}
+	return plans, nil
+}
+
+//PlanInstallments возвращает платежи плана рассрочки по порядку
+func (s *Service) PlanInstallments(planID string) ([]types.Installment, error) {
+	_, err := s.FindInstallmentPlanByID(planID)
+	if err != nil {
+		return nil, err
+	}
+	var installments []types.Installment
+	for _, installment := range s.planInstallments(planID) {
+		installments = append(installments, *installment)
+	}
+	return installments, nil
+}
+
+func (s *Service) planInstallments(planID string) []*types.Installment {
+	var installments []*types.Installment
+	for _, installment := range s.installments {
+		if installment.PlanID == planID {
+			installments = append(installments, installment)
+		}
+	}
+	return installments
+}
+
+//payInstallments связывает платежи рассрочки с созданным платежом. Прошедший платеж оплачивает их сразу,
+//ожидающий подтверждения или проверки - в settleInstallments
+func (s *Service) payInstallments(payment *types.Payment, installments []*types.Installment) {
+	for _, installment := range installments {
+		installment.PaymentID = payment.ID
+	}
+	if payment.Status == types.PaymentStatusInProgress || payment.Status == types.PaymentStatusOk {
+		s.settleInstallments(payment)
+	}
+}
+
+//settleInstallments отмечает оплаченными платежи рассрочки, оплаченные прошедшим платежом
+func (s *Service) settleInstallments(payment *types.Payment) {
+	s.updateInstallments(payment, func(installment *types.Installment) {
+		installment.Paid = s.now().Unix()
+	})
+}
+
+//releaseInstallments снимает отметку об оплате с платежей рассрочки, если их платеж отклонен
+func (s *Service) releaseInstallments(payment *types.Payment) {
+	s.updateInstallments(payment, func(installment *types.Installment) {
+		installment.PaymentID = ""
+		installment.Paid = 0
+	})
+}
+
+func (s *Service) updateInstallments(payment *types.Payment, update func(installment *types.Installment)) {
+	plans := map[string]bool{}
+	for _, installment := range s.installments {
+		if installment.PaymentID == payment.ID {
+			update(installment)
+			plans[installment.PlanID] = true
+		}
+	}
+	for _, plan := range s.plans {
+		if plans[plan.ID] {
+			s.updatePlan(plan)
+		}
+	}
+}
+
+//updatePlan пересчитывает статус плана по его платежам
+func (s *Service) updatePlan(plan *types.InstallmentPlan) {
+	now := s.now().Unix()
+	plan.Status = types.PlanPaid
+	for _, installment := range s.planInstallments(plan.ID) {
+		if installment.Paid != 0 {
+			continue
+		}
+		if installment.Due <= now {
+			plan.Status = types.PlanOverdue
+			return
+		}
+		plan.Status = types.PlanActive
+	}
+}
+
+func (s *Service) planLines() []string {
+	var lines []string
+	for _, plan := range s.plans {
+		lines = append(lines, plan.ToString())
+	}
+	return lines
+}
+
+func (s *Service) installmentLines() []string {
+	var lines []string
+	for _, installment := range s.installments {
+		lines = append(lines, installment.ToString())
+	}
+	return lines
+}
+
+func (s *Service) importPlan(planStr []string) {
+	if len(planStr) < 8 {
+		return
+	}
+	AccountID, _ := strconv.ParseInt(planStr[1], 10, 64)
+	Total, _ := strconv.ParseInt(planStr[3], 10, 64)
+	Parts, _ := strconv.Atoi(planStr[4])
+	LateFee, _ := strconv.ParseInt(planStr[5], 10, 64)
+	Created, _ := strconv.ParseInt(planStr[7], 10, 64)
+	plan, err := s.FindInstallmentPlanByID(planStr[0])
+	if err != nil {
+		plan = &types.InstallmentPlan{ID: planStr[0]}
+		s.plans = append(s.plans, plan)
+	}
+	plan.AccountID = AccountID
+	plan.Category = types.PaymentCategory(planStr[2])
+	plan.Total = types.Money(Total)
+	plan.Parts = Parts
+	plan.LateFee = types.Money(LateFee)
+	plan.Status = types.PlanStatus(planStr[6])
+	plan.Created = Created
+}
+
+func (s *Service) importInstallment(installmentStr []string) {
+	if len(installmentStr) < 7 {
+		return
+	}
+	Number, _ := strconv.Atoi(installmentStr[1])
+	Amount, _ := strconv.ParseInt(installmentStr[2], 10, 64)
+	Due, _ := strconv.ParseInt(installmentStr[3], 10, 64)
+	LateFee, _ := strconv.ParseInt(installmentStr[4], 10, 64)
+	Paid, _ := strconv.ParseInt(installmentStr[6], 10, 64)
+	var installment *types.Installment
+	for _, current := range s.planInstallments(installmentStr[0]) {
+		if current.Number == Number {
+			installment = current
+		}
+	}
+	if installment == nil {
+		installment = &types.Installment{PlanID: installmentStr[0], Number: Number}
+		s.installments = append(s.installments, installment)
+	}
+	installment.Amount = types.Money(Amount)
+	installment.Due = Due
+	installment.LateFee = types.Money(LateFee)
+	installment.PaymentID = installmentStr[5]
+	installment.Paid = Paid
+	if len(installmentStr) >= 10 {
+		LastRun, _ := strconv.ParseInt(installmentStr[7], 10, 64)
+		Failures, _ := strconv.Atoi(installmentStr[9])
+		installment.LastRun = LastRun
+		installment.LastError = installmentStr[8]
+		installment.Failures = Failures
+	}
+}
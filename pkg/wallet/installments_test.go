@@ -0,0 +1,185 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func TestService_CreateInstallmentPlan(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2021, 1, 31, 10, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	account, err := s.addAccountWithBalance("+992928885522", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateInstallmentPlan(account.ID, 300_00, "tv", 1); err != ErrInvalidPlan {
+		t.Errorf("CreateInstallmentPlan(): must return ErrInvalidPlan, returned = %v", err)
+	}
+	if _, err := s.CreateInstallmentPlan(account.ID, 600_00, "tv", 3); err != ErrNotEnoughBalance {
+		t.Errorf("CreateInstallmentPlan(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	if plans, _ := s.AccountInstallmentPlans(account.ID); len(plans) != 0 {
+		t.Errorf("CreateInstallmentPlan(): failed plan must not be stored, plans = %v", plans)
+	}
+
+	plan, err := s.CreateInstallmentPlan(account.ID, 100_00, "tv", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	installments, _ := s.PlanInstallments(plan.ID)
+	if len(installments) != 3 || installments[0].Amount != 33_34 || installments[0].PaymentID == "" ||
+		installments[1].Amount != 33_33 || installments[1].PaymentID != "" {
+		t.Errorf("CreateInstallmentPlan(): installments = %v", installments)
+	}
+	if time.Unix(installments[1].Due, 0).UTC().Day() != 28 || time.Unix(installments[2].Due, 0).UTC().Day() != 31 {
+		t.Errorf("CreateInstallmentPlan(): due dates = %v, %v", installments[1].Due, installments[2].Due)
+	}
+	if account.Balance != 66_66 || plan.Status != types.PlanActive {
+		t.Errorf("CreateInstallmentPlan(): balance = %v, status = %v", account.Balance, plan.Status)
+	}
+}
+
+func TestService_RunDueInstallments(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2021, 3, 10, 10, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	s.SetInstallmentLateFee(5_00, 48*time.Hour)
+	account, err := s.addAccountWithBalance("+992928885522", 200_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := s.CreateInstallmentPlan(account.ID, 300_00, "phone", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runs := s.RunDueInstallments(); len(runs) != 0 {
+		t.Errorf("RunDueInstallments(): nothing is due, runs = %v", runs)
+	}
+	now = now.AddDate(0, 1, 0)
+	payment, _ := s.Pay(account.ID, 50_00, "food")
+	runs := s.RunDueInstallments()
+	if len(runs) != 1 || runs[0].Err != ErrNotEnoughBalance || plan.Status != types.PlanOverdue {
+		t.Errorf("RunDueInstallments(): runs = %v, status = %v", runs, plan.Status)
+	}
+	now = now.Add(time.Hour)
+	if runs := s.RunDueInstallments(); len(runs) != 0 {
+		t.Errorf("RunDueInstallments(): failed installment must wait for retry, runs = %v", runs)
+	}
+
+	_ = s.Reject(payment.ID)
+	_ = s.Deposit(account.ID, 10_00)
+	now = now.Add(48 * time.Hour)
+	runs = s.RunDueInstallments()
+	if len(runs) != 1 || runs[0].Err != nil || plan.Status != types.PlanActive {
+		t.Errorf("RunDueInstallments(): runs = %v, status = %v", runs, plan.Status)
+	}
+	installments, _ := s.PlanInstallments(plan.ID)
+	if installments[1].LateFee != 5_00 || account.Balance != 5_00 || installments[1].Failures != 1 || installments[1].LastError != "" {
+		t.Errorf("RunDueInstallments(): installment = %v, balance = %v", installments[1], account.Balance)
+	}
+}
+
+func TestService_RunDueInstallments_confirmation(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 10_000_00)
+	m := s.requireConfirmation(t, account, 1_000_00)
+	plan, err := s.CreateInstallmentPlan(account.ID, 3_000_00, "tv", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	installments, _ := s.PlanInstallments(plan.ID)
+	if installments[0].PaymentID == "" || installments[0].Paid != 0 {
+		t.Errorf("CreateInstallmentPlan(): pending payment must not mark installment paid, installment = %v", installments[0])
+	}
+	_ = s.ConfirmPayment(installments[0].PaymentID, lastCode(m))
+	installments, _ = s.PlanInstallments(plan.ID)
+	if installments[0].Paid == 0 || plan.Status != types.PlanActive {
+		t.Errorf("ConfirmPayment(): installment = %v, status = %v", installments[0], plan.Status)
+	}
+
+	*now = now.AddDate(0, 1, 0)
+	runs := s.RunDueInstallments()
+	if len(runs) != 1 || runs[0].Err != nil || plan.Status != types.PlanOverdue {
+		t.Fatalf("RunDueInstallments(): runs = %v, status = %v", runs, plan.Status)
+	}
+	if runs := s.RunDueInstallments(); len(runs) != 0 {
+		t.Errorf("RunDueInstallments(): pending installment must not be paid twice, runs = %v", runs)
+	}
+	*now = now.Add(DefaultOTPTTL)
+	s.RejectExpiredPayments()
+	installments, _ = s.PlanInstallments(plan.ID)
+	if installments[1].PaymentID != "" || installments[1].Paid != 0 || account.Balance != 8_500_00 {
+		t.Errorf("RejectExpiredPayments(): installment = %v, balance = %v", installments[1], account.Balance)
+	}
+
+	runs = s.RunDueInstallments()
+	if len(runs) != 1 || runs[0].Err != nil {
+		t.Fatalf("RunDueInstallments(): runs = %v", runs)
+	}
+	_ = s.ConfirmPayment(runs[0].PaymentID, lastCode(m))
+	if plan.Status != types.PlanPaid || account.Balance != 7_000_00 {
+		t.Errorf("ConfirmPayment(): status = %v, balance = %v", plan.Status, account.Balance)
+	}
+}
+
+func TestService_PayOffInstallmentPlan(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2021, 3, 10, 10, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	account, err := s.addAccountWithBalance("+992928885522", 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, _ := s.CreateInstallmentPlan(account.ID, 600_00, "laptop", 6)
+
+	payment, err := s.PayOffInstallmentPlan(plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Amount != 500_00 || account.Balance != 400_00 || plan.Status != types.PlanPaid {
+		t.Errorf("PayOffInstallmentPlan(): payment = %v, balance = %v, status = %v", payment, account.Balance, plan.Status)
+	}
+	if _, err := s.PayOffInstallmentPlan(plan.ID); err != ErrPlanPaid {
+		t.Errorf("PayOffInstallmentPlan(): must return ErrPlanPaid, returned = %v", err)
+	}
+	now = now.AddDate(1, 0, 0)
+	if runs := s.RunDueInstallments(); len(runs) != 0 {
+		t.Errorf("RunDueInstallments(): paid plan must not run, runs = %v", runs)
+	}
+}
+
+func TestService_Import_installments(t *testing.T) {
+	s := newTestService()
+	account, err := s.addAccountWithBalance("+992928885522", 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetInstallmentLateFee(3_00, 0)
+	plan, _ := s.CreateInstallmentPlan(account.ID, 300_00, "tv", 3)
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	for i := 0; i < 2; i++ {
+		err = imported.Import(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	gotPlan, _ := imported.FindInstallmentPlanByID(plan.ID)
+	if !reflect.DeepEqual(gotPlan, plan) {
+		t.Errorf("Import(): plan = %+v, want %+v", gotPlan, plan)
+	}
+	want, _ := s.PlanInstallments(plan.ID)
+	got, _ := imported.PlanInstallments(plan.ID)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Import(): installments = %v, want %v", got, want)
+	}
+}
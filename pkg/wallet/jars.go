@@ -0,0 +1,307 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrJarNotFound    = errors.New("jar not found")
+	ErrInvalidJar     = errors.New("invalid jar")
+	ErrNotEnoughInJar = errors.New("not enough money in jar")
+)
+
+//roundUnit - целая единица, до которой округляются платежи для копилки
+const roundUnit types.Money = 100
+
+//JarGoal представляет продвижение копилки к цели. Monthly - сколько откладывать в месяц, чтобы успеть к сроку,
+//для просроченной цели - весь остаток, без срока - 0
+type JarGoal struct {
+	Jar       types.Jar
+	Remaining types.Money
+	Percent   int
+	Reached   bool
+	Overdue   bool
+	Monthly   types.Money
+}
+
+//CreateJar создает копилку на счете. goal и deadline равные 0 - без цели и срока
+func (s *Service) CreateJar(accountID int64, name string, goal types.Money, deadline int64) (*types.Jar, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || goal < 0 || deadline < 0 {
+		return nil, ErrInvalidJar
+	}
+	jar := &types.Jar{
+		ID:        uuid.New().String(),
+		AccountID: account.ID,
+		Name:      dumpText(name),
+		Goal:      goal,
+		Deadline:  deadline,
+		Created:   s.now().Unix(),
+	}
+	s.jars = append(s.jars, jar)
+	return jar, nil
+}
+
+func (s *Service) FindJarByID(jarID string) (*types.Jar, error) {
+	for _, jar := range s.jars {
+		if jar.ID == jarID {
+			return jar, nil
+		}
+	}
+	return nil, ErrJarNotFound
+}
+
+//AccountJars возвращает копилки счета
+func (s *Service) AccountJars(accountID int64) ([]types.Jar, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	var jars []types.Jar
+	for _, jar := range s.jars {
+		if jar.AccountID == accountID {
+			jars = append(jars, *jar)
+		}
+	}
+	return jars, nil
+}
+
+//JarGoals возвращает продвижение к цели копилок счета, у которых задана цель
+func (s *Service) JarGoals(accountID int64) ([]JarGoal, error) {
+	jars, err := s.AccountJars(accountID)
+	if err != nil {
+		return nil, err
+	}
+	var goals []JarGoal
+	for _, jar := range jars {
+		if jar.Goal > 0 {
+			goals = append(goals, s.jarGoal(jar))
+		}
+	}
+	return goals, nil
+}
+
+func (s *Service) jarGoal(jar types.Jar) JarGoal {
+	goal := JarGoal{Jar: jar, Remaining: jar.Goal - jar.Balance, Reached: jar.Balance >= jar.Goal}
+	if goal.Reached {
+		goal.Remaining = 0
+	}
+	goal.Percent = int(jar.Balance * 100 / jar.Goal)
+	if goal.Reached || jar.Deadline == 0 {
+		return goal
+	}
+	now := s.now()
+	if now.Unix() > jar.Deadline {
+		goal.Overdue = true
+		goal.Monthly = goal.Remaining
+		return goal
+	}
+	deadline := time.Unix(jar.Deadline, 0).In(now.Location())
+	months := types.Money((deadline.Year()-now.Year())*12 + int(deadline.Month()) - int(now.Month()))
+	if months < 1 {
+		months = 1
+	}
+	goal.Monthly = (goal.Remaining + months - 1) / months
+	return goal
+}
+
+//MoveToJar переводит в копилку собственные деньги счета. Овердрафт в копилку не переводится
+func (s *Service) MoveToJar(jarID string, amount types.Money) error {
+	if amount <= 0 {
+		return ErrAmountMustBePositive
+	}
+	jar, account, err := s.jarAccount(jarID)
+	if err != nil {
+		return err
+	}
+	if ownFunds(account) < amount {
+		return ErrNotEnoughBalance
+	}
+	s.moveToJar(jar, account, amount)
+	return nil
+}
+
+//MoveFromJar возвращает деньги из копилки на счет
+func (s *Service) MoveFromJar(jarID string, amount types.Money) error {
+	if amount <= 0 {
+		return ErrAmountMustBePositive
+	}
+	jar, account, err := s.jarAccount(jarID)
+	if err != nil {
+		return err
+	}
+	if jar.Balance < amount {
+		return ErrNotEnoughInJar
+	}
+	jar.Balance -= amount
+	s.post(account, amount, types.PostingJarOut, jar.ID)
+	return nil
+}
+
+//SetRoundUp включает или выключает округление платежей в копилку. На счете округление получает только одна копилка
+func (s *Service) SetRoundUp(jarID string, enabled bool) error {
+	jar, _, err := s.jarAccount(jarID)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		for _, current := range s.jars {
+			if current.AccountID == jar.AccountID {
+				current.RoundUp = false
+			}
+		}
+	}
+	jar.RoundUp = enabled
+	return nil
+}
+
+//CloseJar возвращает остаток копилки на счет и удаляет ее
+func (s *Service) CloseJar(jarID string) error {
+	jar, account, err := s.jarAccount(jarID)
+	if err != nil {
+		return err
+	}
+	if jar.Balance > 0 {
+		s.post(account, jar.Balance, types.PostingJarOut, jar.ID)
+		jar.Balance = 0
+	}
+	for i, current := range s.jars {
+		if current == jar {
+			s.jars = append(s.jars[:i], s.jars[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Service) jarAccount(jarID string) (*types.Jar, *types.Account, error) {
+	jar, err := s.FindJarByID(jarID)
+	if err != nil {
+		return nil, nil, err
+	}
+	account, err := s.FindAccountByID(jar.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return jar, account, nil
+}
+
+func (s *Service) moveToJar(jar *types.Jar, account *types.Account, amount types.Money) {
+	jar.Balance += amount
+	s.post(account, -amount, types.PostingJarIn, jar.ID)
+}
+
+//roundUp переводит в копилку с округлением разницу между прошедшим платежом и следующей целой единицей,
+//если хватает собственных денег счета без овердрафта
+func (s *Service) roundUp(payment *types.Payment) {
+	diff := (roundUnit - payment.Amount%roundUnit) % roundUnit
+	if diff == 0 {
+		return
+	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil || ownFunds(account) < diff {
+		return
+	}
+	for _, jar := range s.jars {
+		if jar.AccountID == account.ID && jar.RoundUp {
+			s.moveToJar(jar, account, diff)
+			s.roundUps = append(s.roundUps, &types.RoundUp{PaymentID: payment.ID, JarID: jar.ID, Amount: diff})
+			return
+		}
+	}
+}
+
+//reverseRoundUp возвращает на счет округление отклоненного платежа. Если из копилки уже сняли часть денег,
+//возвращается только остаток, если копилка закрыта - деньги уже на счете
+func (s *Service) reverseRoundUp(account *types.Account, payment *types.Payment) {
+	for i, roundUp := range s.roundUps {
+		if roundUp.PaymentID != payment.ID {
+			continue
+		}
+		s.roundUps = append(s.roundUps[:i], s.roundUps[i+1:]...)
+		jar, err := s.FindJarByID(roundUp.JarID)
+		if err != nil {
+			return
+		}
+		amount := roundUp.Amount
+		if amount > jar.Balance {
+			amount = jar.Balance
+		}
+		if amount > 0 {
+			jar.Balance -= amount
+			s.post(account, amount, types.PostingJarOut, jar.ID)
+		}
+		return
+	}
+}
+
+func (s *Service) findRoundUp(paymentID string) *types.RoundUp {
+	for _, roundUp := range s.roundUps {
+		if roundUp.PaymentID == paymentID {
+			return roundUp
+		}
+	}
+	return nil
+}
+
+func (s *Service) jarLines() []string {
+	var lines []string
+	for _, jar := range s.jars {
+		lines = append(lines, jar.ToString())
+	}
+	return lines
+}
+
+func (s *Service) importJar(jarStr []string) {
+	if len(jarStr) < 8 {
+		return
+	}
+	AccountID, _ := strconv.ParseInt(jarStr[1], 10, 64)
+	Goal, _ := strconv.ParseInt(jarStr[3], 10, 64)
+	Deadline, _ := strconv.ParseInt(jarStr[4], 10, 64)
+	Balance, _ := strconv.ParseInt(jarStr[5], 10, 64)
+	RoundUp, _ := strconv.ParseBool(jarStr[6])
+	Created, _ := strconv.ParseInt(jarStr[7], 10, 64)
+	jar, err := s.FindJarByID(jarStr[0])
+	if err != nil {
+		jar = &types.Jar{ID: jarStr[0]}
+		s.jars = append(s.jars, jar)
+	}
+	jar.AccountID = AccountID
+	jar.Name = jarStr[2]
+	jar.Goal = types.Money(Goal)
+	jar.Deadline = Deadline
+	jar.Balance = types.Money(Balance)
+	jar.RoundUp = RoundUp
+	jar.Created = Created
+}
+
+func (s *Service) roundUpLines() []string {
+	var lines []string
+	for _, roundUp := range s.roundUps {
+		lines = append(lines, roundUp.ToString())
+	}
+	return lines
+}
+
+func (s *Service) importRoundUp(roundUpStr []string) {
+	if len(roundUpStr) < 3 {
+		return
+	}
+	Amount, _ := strconv.ParseInt(roundUpStr[2], 10, 64)
+	roundUp := s.findRoundUp(roundUpStr[0])
+	if roundUp == nil {
+		roundUp = &types.RoundUp{PaymentID: roundUpStr[0]}
+		s.roundUps = append(s.roundUps, roundUp)
+	}
+	roundUp.JarID = roundUpStr[1]
+	roundUp.Amount = types.Money(Amount)
+}
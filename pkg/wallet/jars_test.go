@@ -0,0 +1,185 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func TestService_MoveToJar(t *testing.T) {
+	s := newTestService()
+	account, err := s.addAccountWithBalance("+992928885522", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateJar(account.ID, "", 0, 0); err != ErrInvalidJar {
+		t.Errorf("CreateJar(): must return ErrInvalidJar, returned = %v", err)
+	}
+	jar, err := s.CreateJar(account.ID, "bike", 300_00, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.MoveToJar(jar.ID, 60_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 40_00 || jar.Balance != 60_00 {
+		t.Errorf("MoveToJar(): balance = %v, jar = %v", account.Balance, jar.Balance)
+	}
+	if err := s.MoveToJar(jar.ID, 50_00); err != ErrNotEnoughBalance {
+		t.Errorf("MoveToJar(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	if err := s.MoveFromJar(jar.ID, 70_00); err != ErrNotEnoughInJar {
+		t.Errorf("MoveFromJar(): must return ErrNotEnoughInJar, returned = %v", err)
+	}
+	_ = s.MoveFromJar(jar.ID, 10_00)
+	if account.Balance != 50_00 || jar.Balance != 50_00 {
+		t.Errorf("MoveFromJar(): balance = %v, jar = %v", account.Balance, jar.Balance)
+	}
+
+	err = s.CloseJar(jar.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 100_00 {
+		t.Errorf("CloseJar(): balance = %v", account.Balance)
+	}
+	if _, err := s.FindJarByID(jar.ID); err != ErrJarNotFound {
+		t.Errorf("CloseJar(): jar must be removed, err = %v", err)
+	}
+}
+
+func TestService_JarGoals(t *testing.T) {
+	s, account, now := newClockedTestService(t, time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC), 1_000_00)
+	_, _ = s.CreateJar(account.ID, "coins", 0, 0)
+	bike, _ := s.CreateJar(account.ID, "bike", 300_00, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC).Unix())
+	phone, _ := s.CreateJar(account.ID, "phone", 200_00, 0)
+	_ = s.MoveToJar(bike.ID, 100_00)
+	_ = s.MoveToJar(phone.ID, 250_00)
+
+	goals, err := s.JarGoals(account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []JarGoal{
+		{Jar: *bike, Remaining: 200_00, Percent: 33, Monthly: 66_67},
+		{Jar: *phone, Remaining: 0, Percent: 125, Reached: true},
+	}
+	if !reflect.DeepEqual(goals, want) {
+		t.Errorf("JarGoals(): got %+v, want %+v", goals, want)
+	}
+
+	*now = time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC)
+	goals, _ = s.JarGoals(account.ID)
+	if !goals[0].Overdue || goals[0].Monthly != 200_00 {
+		t.Errorf("JarGoals(): goal after deadline = %+v", goals[0])
+	}
+	if _, err := s.JarGoals(404); err != ErrAccountNotFound {
+		t.Errorf("JarGoals(): must return ErrAccountNotFound, returned = %v", err)
+	}
+}
+
+func TestService_Pay_roundUp(t *testing.T) {
+	s := newTestService()
+	account, err := s.addAccountWithBalance("+992928885522", 100_05)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := s.CreateJar(account.ID, "first", 0, 0)
+	second, _ := s.CreateJar(account.ID, "second", 0, 0)
+	_ = s.SetRoundUp(first.ID, true)
+	_ = s.SetRoundUp(second.ID, true)
+	if first.RoundUp || !second.RoundUp {
+		t.Errorf("SetRoundUp(): only one jar must round up, first = %v, second = %v", first.RoundUp, second.RoundUp)
+	}
+
+	_, _ = s.Pay(account.ID, 12_30, "food")
+	if second.Balance != 70 || account.Balance != 87_05 {
+		t.Errorf("Pay(): jar = %v, balance = %v", second.Balance, account.Balance)
+	}
+	_, _ = s.Pay(account.ID, 10_00, "food")
+	if second.Balance != 70 {
+		t.Errorf("Pay(): whole amount must not round up, jar = %v", second.Balance)
+	}
+	_, _ = s.Pay(account.ID, 77_01, "food")
+	if second.Balance != 70 || account.Balance != 4 {
+		t.Errorf("Pay(): round up must be skipped without money, jar = %v, balance = %v", second.Balance, account.Balance)
+	}
+}
+
+func TestService_Reject_roundUp(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	m := s.requireConfirmation(t, account, 1_000_00)
+	jar, _ := s.CreateJar(account.ID, "coins", 0, 0)
+	_ = s.SetRoundUp(jar.ID, true)
+
+	payment, _ := s.Pay(account.ID, 1_000_30, "auto")
+	if payment.Status != types.PaymentStatusPending || jar.Balance != 0 {
+		t.Errorf("Pay(): pending payment must not round up, status = %v, jar = %v", payment.Status, jar.Balance)
+	}
+	_ = s.ConfirmPayment(payment.ID, lastCode(m))
+	if jar.Balance != 70 || account.Balance != 8_999_00 {
+		t.Errorf("ConfirmPayment(): jar = %v, balance = %v", jar.Balance, account.Balance)
+	}
+	_ = s.Reject(payment.ID)
+	if jar.Balance != 0 || account.Balance != 10_000_00 {
+		t.Errorf("Reject(): round up must be reversed, jar = %v, balance = %v", jar.Balance, account.Balance)
+	}
+
+	_ = s.SetFraudRules([]FraudRule{{Name: "new", Kind: FraudNewAccount, Action: FraudReview, Window: Duration(time.Hour), Amount: 1_00}})
+	payment, _ = s.Pay(account.ID, 10_40, "food")
+	_ = s.DeclineReview(payment.ID, "")
+	if payment.Status != types.PaymentStatusFail || jar.Balance != 0 || account.Balance != 10_000_00 {
+		t.Errorf("DeclineReview(): payment = %v, jar = %v, balance = %v", payment, jar.Balance, account.Balance)
+	}
+
+	_ = s.SetFraudRules(nil)
+	payment, _ = s.Pay(account.ID, 10_40, "food")
+	_ = s.MoveFromJar(jar.ID, 50)
+	_ = s.Reject(payment.ID)
+	if jar.Balance != 0 || account.Balance != 10_000_00 {
+		t.Errorf("Reject(): only jar balance must be returned, jar = %v, balance = %v", jar.Balance, account.Balance)
+	}
+}
+
+func TestService_Import_jars(t *testing.T) {
+	s := newTestService()
+	account, err := s.addAccountWithBalance("+992928885522", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar, _ := s.CreateJar(account.ID, "new; phone", 500_00, 1_700_000_000)
+	_ = s.SetRoundUp(jar.ID, true)
+	_ = s.MoveToJar(jar.ID, 25_00)
+	payment, _ := s.Pay(account.ID, 10_40, "food")
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	for i := 0; i < 2; i++ {
+		err = imported.Import(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, _ := s.AccountJars(account.ID)
+	got, _ := imported.AccountJars(account.ID)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Import(): jars = %v, want %v", got, want)
+	}
+	gotAccount, _ := imported.FindAccountByID(account.ID)
+	if gotAccount.Balance != 64_00 {
+		t.Errorf("Import(): balance = %v", gotAccount.Balance)
+	}
+	_ = imported.Reject(payment.ID)
+	gotJar, _ := imported.FindJarByID(jar.ID)
+	if gotAccount.Balance != 75_00 || gotJar.Balance != 25_00 {
+		t.Errorf("Reject(): imported round up must be reversed, balance = %v, jar = %v", gotAccount.Balance, gotJar.Balance)
+	}
+}
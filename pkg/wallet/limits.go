@@ -0,0 +1,176 @@
+package wallet
+
+import (
+	"errors"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+var (
+	ErrLimitPerTransaction = errors.New("transaction limit exceeded")
+	ErrLimitDaily          = errors.New("daily limit exceeded")
+	ErrLimitMonthly        = errors.New("monthly limit exceeded")
+	ErrLimitPerHour        = errors.New("hourly payment count limit exceeded")
+)
+
+//Unlimited означает отсутствие ограничения в Allowance
+const Unlimited = -1
+
+//Allowance представляет остаток лимитов. Поля равны Unlimited, если ограничения нет
+type Allowance struct {
+	PerTransaction types.Money
+	Daily          types.Money
+	Monthly        types.Money
+	PerHour        int
+}
+
+//SetLimit задает ограничения для счета и категории, заменяя прежние
+func (s *Service) SetLimit(limit types.Limit) error {
+	_, err := s.FindAccountByID(limit.AccountID)
+	if err != nil {
+		return err
+	}
+	for _, current := range s.limits {
+		if current.AccountID == limit.AccountID && current.Category == limit.Category {
+			*current = limit
+			return nil
+		}
+	}
+	s.limits = append(s.limits, &limit)
+	return nil
+}
+
+func (s *Service) RemoveLimit(accountID int64, category types.PaymentCategory) {
+	for i, limit := range s.limits {
+		if limit.AccountID == accountID && limit.Category == category {
+			s.limits = append(s.limits[:i], s.limits[i+1:]...)
+			return
+		}
+	}
+}
+
+//RemainingAllowance возвращает, сколько еще можно потратить в категории с учетом общих лимитов счета и лимитов категории
+func (s *Service) RemainingAllowance(accountID int64, category types.PaymentCategory) (*Allowance, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	allowance := &Allowance{
+		PerTransaction: Unlimited,
+		Daily:          Unlimited,
+		Monthly:        Unlimited,
+		PerHour:        Unlimited,
+	}
+	lower := func(dst *types.Money, v types.Money) {
+		if v < 0 {
+			v = 0
+		}
+		if *dst == Unlimited || v < *dst {
+			*dst = v
+		}
+	}
+	for _, limit := range s.accountLimits(accountID, category) {
+		usage := s.limitUsage(limit)
+		if limit.PerTransaction > 0 {
+			lower(&allowance.PerTransaction, limit.PerTransaction)
+		}
+		if limit.Daily > 0 {
+			lower(&allowance.Daily, limit.Daily-usage.daily)
+		}
+		if limit.Monthly > 0 {
+			lower(&allowance.Monthly, limit.Monthly-usage.monthly)
+		}
+		if limit.PerHour > 0 {
+			left := limit.PerHour - usage.hourly
+			if left < 0 {
+				left = 0
+			}
+			if allowance.PerHour == Unlimited || left < allowance.PerHour {
+				allowance.PerHour = left
+			}
+		}
+	}
+	return allowance, nil
+}
+
+//checkLimits проверяет, что платеж не нарушает ограничения счета
+func (s *Service) checkLimits(accountID int64, amount types.Money, category types.PaymentCategory) error {
+	for _, limit := range s.accountLimits(accountID, category) {
+		if limit.PerTransaction > 0 && amount > limit.PerTransaction {
+			return ErrLimitPerTransaction
+		}
+		usage := s.limitUsage(limit)
+		if limit.Daily > 0 && usage.daily+amount > limit.Daily {
+			return ErrLimitDaily
+		}
+		if limit.Monthly > 0 && usage.monthly+amount > limit.Monthly {
+			return ErrLimitMonthly
+		}
+		if limit.PerHour > 0 && usage.hourly+1 > limit.PerHour {
+			return ErrLimitPerHour
+		}
+	}
+	return nil
+}
+
+func (s *Service) accountLimits(accountID int64, category types.PaymentCategory) []*types.Limit {
+	var limits []*types.Limit
+	for _, limit := range s.limits {
+		if limit.AccountID == accountID && (limit.Category == "" || limit.Category == category) {
+			limits = append(limits, limit)
+		}
+	}
+	return limits
+}
+
+type limitUsage struct {
+	daily   types.Money
+	monthly types.Money
+	hourly  int
+}
+
+//limitUsage считает неотклоненные платежи и активные холды под ограничением: за текущие календарные день и месяц и за последний час
+func (s *Service) limitUsage(limit *types.Limit) limitUsage {
+	now := s.now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Unix()
+	hour := now.Add(-time.Hour).Unix()
+
+	usage := limitUsage{}
+	for _, payment := range s.payments {
+		if payment.AccountID != limit.AccountID || payment.Status == types.PaymentStatusFail {
+			continue
+		}
+		if limit.Category != "" && payment.Category != limit.Category {
+			continue
+		}
+		if payment.Created >= month {
+			usage.monthly += payment.Amount
+		}
+		if payment.Created >= day {
+			usage.daily += payment.Amount
+		}
+		if payment.Created > hour {
+			usage.hourly++
+		}
+	}
+	for _, hold := range s.holds {
+		if hold.AccountID != limit.AccountID || hold.Status != types.HoldActive {
+			continue
+		}
+		if limit.Category != "" && hold.Category != limit.Category {
+			continue
+		}
+		if hold.Created >= month {
+			usage.monthly += hold.Amount
+		}
+		if hold.Created >= day {
+			usage.daily += hold.Amount
+		}
+		if hold.Created > hour {
+			usage.hourly++
+		}
+	}
+	return usage
+}
@@ -0,0 +1,101 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+//monthEndEvening вечер 30 марта: через три часа начинается новый день, еще через сутки - новый месяц
+var monthEndEvening = time.Date(2021, 3, 30, 22, 0, 0, 0, time.UTC)
+
+func TestService_Pay_limits(t *testing.T) {
+	s, account, now := newClockedTestService(t, monthEndEvening, 100_000_00)
+	_ = s.SetLimit(types.Limit{AccountID: account.ID, PerTransaction: 500_00, Daily: 1_000_00, Monthly: 1_500_00})
+	_ = s.SetLimit(types.Limit{AccountID: account.ID, Category: "taxi", PerTransaction: 100_00})
+
+	if _, err := s.Pay(account.ID, 600_00, "food"); err != ErrLimitPerTransaction {
+		t.Errorf("Pay(): must return ErrLimitPerTransaction, returned = %v", err)
+	}
+	if _, err := s.Pay(account.ID, 200_00, "taxi"); err != ErrLimitPerTransaction {
+		t.Errorf("Pay(): must return ErrLimitPerTransaction for category, returned = %v", err)
+	}
+	first, _ := s.Pay(account.ID, 500_00, "food")
+	payment, _ := s.Pay(account.ID, 500_00, "food")
+	if _, err := s.Repeat(payment.ID); err != ErrLimitDaily {
+		t.Errorf("Repeat(): must return ErrLimitDaily, returned = %v", err)
+	}
+	//отклоненные платежи не учитываются
+	_ = s.Reject(first.ID)
+	if _, err := s.Repeat(payment.ID); err != nil {
+		t.Errorf("Repeat(): error = %v", err)
+	}
+
+	*now = now.Add(3 * time.Hour)
+	favorite, _ := s.FavoritePayment(payment.ID, "food")
+	if _, err := s.PayFromFavorite(favorite.ID); err != nil {
+		t.Errorf("PayFromFavorite(): error = %v", err)
+	}
+	if _, err := s.PayFromFavorite(favorite.ID); err != ErrLimitMonthly {
+		t.Errorf("PayFromFavorite(): must return ErrLimitMonthly, returned = %v", err)
+	}
+	*now = now.AddDate(0, 0, 1)
+	if _, err := s.PayFromFavorite(favorite.ID); err != nil {
+		t.Errorf("PayFromFavorite(): next month, error = %v", err)
+	}
+}
+
+func TestService_Pay_limitPerHour(t *testing.T) {
+	s, account, now := newClockedTestService(t, monthEndEvening, 100_000_00)
+	_ = s.SetLimit(types.Limit{AccountID: account.ID, PerHour: 3})
+	for i := 0; i < 3; i++ {
+		if _, err := s.Pay(account.ID, 1, "game"); err != nil {
+			t.Fatalf("Pay(): error = %v", err)
+		}
+		*now = now.Add(10 * time.Minute)
+	}
+	if _, err := s.Pay(account.ID, 1, "game"); err != ErrLimitPerHour {
+		t.Errorf("Pay(): must return ErrLimitPerHour, returned = %v", err)
+	}
+	*now = now.Add(30 * time.Minute)
+	if _, err := s.Pay(account.ID, 1, "game"); err != nil {
+		t.Errorf("Pay(): error = %v", err)
+	}
+}
+
+func TestService_RemainingAllowance(t *testing.T) {
+	s, account, _ := newClockedTestService(t, monthEndEvening, 100_000_00)
+	allowance, _ := s.RemainingAllowance(account.ID, "food")
+	if *allowance != (Allowance{Unlimited, Unlimited, Unlimited, Unlimited}) {
+		t.Errorf("RemainingAllowance(): got %+v", allowance)
+	}
+
+	_ = s.SetLimit(types.Limit{AccountID: account.ID, Daily: 1_000_00, Monthly: 5_000_00})
+	_ = s.SetLimit(types.Limit{AccountID: account.ID, Category: "food", Daily: 300_00, PerHour: 5})
+	_, _ = s.Pay(account.ID, 200_00, "food")
+	_, _ = s.Pay(account.ID, 500_00, "taxi")
+
+	allowance, err := s.RemainingAllowance(account.ID, "food")
+	if err != nil {
+		t.Fatalf("RemainingAllowance(): error = %v", err)
+	}
+	want := Allowance{PerTransaction: Unlimited, Daily: 100_00, Monthly: 4_300_00, PerHour: 4}
+	if *allowance != want {
+		t.Errorf("RemainingAllowance(): got %+v, want %+v", *allowance, want)
+	}
+	allowance, _ = s.RemainingAllowance(account.ID, "taxi")
+	want = Allowance{PerTransaction: Unlimited, Daily: 300_00, Monthly: 4_300_00, PerHour: Unlimited}
+	if *allowance != want {
+		t.Errorf("RemainingAllowance(): got %+v, want %+v", *allowance, want)
+	}
+
+	s.RemoveLimit(account.ID, "food")
+	allowance, _ = s.RemainingAllowance(account.ID, "food")
+	if allowance.PerHour != Unlimited || allowance.Daily != 300_00 {
+		t.Errorf("RemainingAllowance(): after RemoveLimit got %+v", allowance)
+	}
+	if _, err := s.RemainingAllowance(100, "food"); err != ErrAccountNotFound {
+		t.Errorf("RemainingAllowance(): must return ErrAccountNotFound, returned = %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package wallet
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCampaignNotFound = errors.New("campaign not found")
+	ErrInvalidCampaign  = errors.New("invalid campaign")
+	ErrNotEnoughPoints  = errors.New("not enough points")
+)
+
+//AddCampaign добавляет кампанию лояльности. Вознаграждения начисляются при завершении платежа через Complete
+func (s *Service) AddCampaign(campaign types.Campaign) (*types.Campaign, error) {
+	if campaign.Kind != types.CampaignCashback && campaign.Kind != types.CampaignPoints || campaign.Percent <= 0 ||
+		campaign.MaxPerPayment < 0 || campaign.MaxPerAccount < 0 || campaign.End != 0 && campaign.End <= campaign.Start {
+		return nil, ErrInvalidCampaign
+	}
+	campaign.ID = uuid.New().String()
+	s.campaigns = append(s.campaigns, &campaign)
+	return &campaign, nil
+}
+
+func (s *Service) FindCampaignByID(campaignID string) (*types.Campaign, error) {
+	for _, campaign := range s.campaigns {
+		if campaign.ID == campaignID {
+			return campaign, nil
+		}
+	}
+	return nil, ErrCampaignNotFound
+}
+
+//RemoveCampaign удаляет кампанию. Начисленные вознаграждения остаются и удерживаются при отмене платежа как прежде
+func (s *Service) RemoveCampaign(campaignID string) error {
+	for i, campaign := range s.campaigns {
+		if campaign.ID == campaignID {
+			s.campaigns = append(s.campaigns[:i], s.campaigns[i+1:]...)
+			return nil
+		}
+	}
+	return ErrCampaignNotFound
+}
+
+//AccountRewards возвращает вознаграждения счета в порядке начисления
+func (s *Service) AccountRewards(accountID int64) ([]types.Reward, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	var rewards []types.Reward
+	for _, reward := range s.rewards {
+		if reward.AccountID == accountID {
+			rewards = append(rewards, *reward)
+		}
+	}
+	return rewards, nil
+}
+
+//RedeemPoints переводит баллы на основной баланс, один балл равен одной минимальной единице денег
+func (s *Service) RedeemPoints(accountID int64, points int64) error {
+	if points <= 0 {
+		return ErrAmountMustBePositive
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if account.Points < points {
+		return ErrNotEnoughPoints
+	}
+	account.Points -= points
+	s.post(account, types.Money(points), types.PostingRedeem, "")
+	return nil
+}
+
+//award начисляет вознаграждения по всем подходящим кампаниям за невозвращенную часть платежа
+func (s *Service) award(account *types.Account, payment *types.Payment) {
+	amount := payment.Amount - payment.Refunded
+	for _, campaign := range s.campaigns {
+		if campaign.Category != "" && campaign.Category != payment.Category {
+			continue
+		}
+		if payment.Created < campaign.Start || campaign.End != 0 && payment.Created >= campaign.End {
+			continue
+		}
+		value := types.Money(math.Round(float64(amount) * campaign.Percent / 100))
+		if campaign.MaxPerPayment > 0 && value > campaign.MaxPerPayment {
+			value = campaign.MaxPerPayment
+		}
+		if campaign.MaxPerAccount > 0 {
+			left := campaign.MaxPerAccount - s.campaignRewards(campaign.ID, account.ID)
+			if value > left {
+				value = left
+			}
+		}
+		if value <= 0 {
+			continue
+		}
+
+		s.rewards = append(s.rewards, &types.Reward{
+			ID:         uuid.New().String(),
+			CampaignID: campaign.ID,
+			PaymentID:  payment.ID,
+			AccountID:  account.ID,
+			Kind:       campaign.Kind,
+			Amount:     value,
+			Created:    s.now().Unix(),
+		})
+		if campaign.Kind == types.CampaignCashback {
+			s.post(account, value, types.PostingCashback, payment.ID)
+		} else {
+			account.Points += int64(value)
+		}
+	}
+}
+
+//campaignRewards возвращает сумму неудержанных вознаграждений счета по кампании
+func (s *Service) campaignRewards(campaignID string, accountID int64) types.Money {
+	total := types.Money(0)
+	for _, reward := range s.rewards {
+		if reward.CampaignID == campaignID && reward.AccountID == accountID {
+			total += reward.Amount - reward.Clawed
+		}
+	}
+	return total
+}
+
+//clawback удерживает вознаграждения за платеж: полностью, если full, иначе пропорционально возвращенной сумме.
+//Баллы, которых уже нет на счете, потому что их обменяли на деньги, удерживаются с баланса
+func (s *Service) clawback(account *types.Account, payment *types.Payment, full bool) {
+	for _, reward := range s.rewards {
+		if reward.PaymentID != payment.ID {
+			continue
+		}
+		target := reward.Amount
+		if !full && payment.Amount > 0 && payment.Refunded < payment.Amount {
+			target = reward.Amount * payment.Refunded / payment.Amount
+		}
+		value := target - reward.Clawed
+		if value <= 0 {
+			continue
+		}
+		reward.Clawed += value
+		if reward.Kind == types.CampaignCashback {
+			s.post(account, -value, types.PostingCashbackClawback, payment.ID)
+		} else {
+			points := int64(value)
+			if points > account.Points {
+				points = account.Points
+			}
+			if points < 0 {
+				points = 0
+			}
+			account.Points -= points
+			if shortfall := value - types.Money(points); shortfall > 0 {
+				s.post(account, -shortfall, types.PostingPointsClawback, payment.ID)
+			}
+		}
+	}
+}
+
+func (s *Service) campaignLines() []string {
+	var lines []string
+	for _, campaign := range s.campaigns {
+		lines = append(lines, campaign.ToString())
+	}
+	return lines
+}
+
+func (s *Service) rewardLines() []string {
+	var lines []string
+	for _, reward := range s.rewards {
+		lines = append(lines, reward.ToString())
+	}
+	return lines
+}
+
+func (s *Service) importCampaign(campaignStr []string) {
+	if len(campaignStr) < 8 {
+		return
+	}
+	Percent, _ := strconv.ParseFloat(campaignStr[3], 64)
+	MaxPerPayment, _ := strconv.ParseInt(campaignStr[4], 10, 64)
+	MaxPerAccount, _ := strconv.ParseInt(campaignStr[5], 10, 64)
+	Start, _ := strconv.ParseInt(campaignStr[6], 10, 64)
+	End, _ := strconv.ParseInt(campaignStr[7], 10, 64)
+	campaign, err := s.FindCampaignByID(campaignStr[0])
+	if err != nil {
+		campaign = &types.Campaign{ID: campaignStr[0]}
+		s.campaigns = append(s.campaigns, campaign)
+	}
+	campaign.Category = types.PaymentCategory(campaignStr[1])
+	campaign.Kind = types.CampaignKind(campaignStr[2])
+	campaign.Percent = Percent
+	campaign.MaxPerPayment = types.Money(MaxPerPayment)
+	campaign.MaxPerAccount = types.Money(MaxPerAccount)
+	campaign.Start = Start
+	campaign.End = End
+}
+
+func (s *Service) importReward(rewardStr []string) {
+	if len(rewardStr) < 8 {
+		return
+	}
+	AccountID, _ := strconv.ParseInt(rewardStr[3], 10, 64)
+	Amount, _ := strconv.ParseInt(rewardStr[5], 10, 64)
+	Clawed, _ := strconv.ParseInt(rewardStr[6], 10, 64)
+	Created, _ := strconv.ParseInt(rewardStr[7], 10, 64)
+	var reward *types.Reward
+	for _, current := range s.rewards {
+		if current.ID == rewardStr[0] {
+			reward = current
+		}
+	}
+	if reward == nil {
+		reward = &types.Reward{ID: rewardStr[0]}
+		s.rewards = append(s.rewards, reward)
+	}
+	reward.CampaignID = rewardStr[1]
+	reward.PaymentID = rewardStr[2]
+	reward.AccountID = AccountID
+	reward.Kind = types.CampaignKind(rewardStr[4])
+	reward.Amount = types.Money(Amount)
+	reward.Clawed = types.Money(Clawed)
+	reward.Created = Created
+}
@@ -0,0 +1,169 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func (s *testService) payAndComplete(t *testing.T, accountID int64, amount types.Money, category types.PaymentCategory) *types.Payment {
+	payment, err := s.Pay(accountID, amount, category)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Complete(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+func TestService_Complete_cashback(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 10_000_00)
+	march := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	campaign, err := s.AddCampaign(types.Campaign{
+		Category:      "food",
+		Kind:          types.CampaignCashback,
+		Percent:       5,
+		MaxPerPayment: 20_00,
+		MaxPerAccount: 30_00,
+		Start:         march.Unix(),
+		End:           march.AddDate(0, 1, 0).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment, _ := s.Pay(account.ID, 100_00, "food")
+	if account.Balance != 9_900_00 {
+		t.Fatalf("Pay(): cashback must not be awarded before Complete, balance = %v", account.Balance)
+	}
+	_ = s.Complete(payment.ID)
+	if payment.Status != types.PaymentStatusOk || account.Balance != 9_905_00 {
+		t.Errorf("Complete(): payment = %v, balance = %v", payment, account.Balance)
+	}
+	if err := s.Complete(payment.ID); err != ErrPaymentNotInProgress {
+		t.Errorf("Complete(): must return ErrPaymentNotInProgress, returned = %v", err)
+	}
+
+	s.payAndComplete(t, account.ID, 1_000_00, "food")
+	s.payAndComplete(t, account.ID, 1_000_00, "food")
+	s.payAndComplete(t, account.ID, 1_000_00, "taxi")
+	if account.Balance != 10_000_00-3_100_00+30_00 {
+		t.Errorf("Complete(): caps, balance = %v", account.Balance)
+	}
+	rewards, _ := s.AccountRewards(account.ID)
+	if len(rewards) != 3 || rewards[0].Amount != 5_00 || rewards[1].Amount != 20_00 || rewards[2].Amount != 5_00 || rewards[0].CampaignID != campaign.ID {
+		t.Errorf("AccountRewards(): got %v", rewards)
+	}
+
+	*now = time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+	_ = s.RemoveCampaign(campaign.ID)
+	_, _ = s.AddCampaign(types.Campaign{Kind: types.CampaignCashback, Percent: 1, Start: now.Unix()})
+	before := account.Balance
+	s.payAndComplete(t, account.ID, 100_00, "food")
+	if account.Balance != before-100_00+1_00 {
+		t.Errorf("Complete(): balance = %v", account.Balance)
+	}
+}
+
+func TestService_Complete_points(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	_, _ = s.AddCampaign(types.Campaign{Category: "taxi", Kind: types.CampaignPoints, Percent: 10})
+	s.payAndComplete(t, account.ID, 500_00, "taxi")
+	if account.Points != 50_00 || account.Balance != 9_500_00 {
+		t.Errorf("Complete(): points = %v, balance = %v", account.Points, account.Balance)
+	}
+
+	if err := s.RedeemPoints(account.ID, 60_00); err != ErrNotEnoughPoints {
+		t.Errorf("RedeemPoints(): must return ErrNotEnoughPoints, returned = %v", err)
+	}
+	if err := s.RedeemPoints(account.ID, 30_00); err != nil {
+		t.Fatalf("RedeemPoints(): error = %v", err)
+	}
+	if account.Points != 20_00 || account.Balance != 9_530_00 {
+		t.Errorf("RedeemPoints(): points = %v, balance = %v", account.Points, account.Balance)
+	}
+	if _, err := s.AddCampaign(types.Campaign{Kind: "GIFT", Percent: 1}); err != ErrInvalidCampaign {
+		t.Errorf("AddCampaign(): must return ErrInvalidCampaign, returned = %v", err)
+	}
+}
+
+func TestService_clawback(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	_, _ = s.AddCampaign(types.Campaign{Kind: types.CampaignCashback, Percent: 10})
+	_, _ = s.AddCampaign(types.Campaign{Kind: types.CampaignPoints, Percent: 20})
+	payment := s.payAndComplete(t, account.ID, 1_000_00, "food")
+	if account.Balance != 9_100_00 || account.Points != 200_00 {
+		t.Fatalf("Complete(): balance = %v, points = %v", account.Balance, account.Points)
+	}
+
+	_, _ = s.Refund(payment.ID, 250_00, "")
+	if account.Balance != 9_100_00+250_00-25_00 || account.Points != 150_00 {
+		t.Errorf("Refund(): balance = %v, points = %v", account.Balance, account.Points)
+	}
+	_ = s.Reject(payment.ID)
+	if account.Balance != 10_000_00 || account.Points != 0 {
+		t.Errorf("Reject(): balance = %v, points = %v", account.Balance, account.Points)
+	}
+	rewards, _ := s.AccountRewards(account.ID)
+	for _, reward := range rewards {
+		if reward.Clawed != reward.Amount {
+			t.Errorf("Reject(): reward = %v", reward)
+		}
+	}
+}
+
+func TestService_clawback_redeemed(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	_, _ = s.AddCampaign(types.Campaign{Kind: types.CampaignPoints, Percent: 10})
+	payment := s.payAndComplete(t, account.ID, 1_000_00, "food")
+	_ = s.RedeemPoints(account.ID, 80_00)
+	if account.Points != 20_00 || account.Balance != 9_080_00 {
+		t.Fatalf("RedeemPoints(): points = %v, balance = %v", account.Points, account.Balance)
+	}
+
+	//обмененные баллы удерживаются с баланса, баллы не уходят в минус
+	_ = s.Reject(payment.ID)
+	if account.Points != 0 || account.Balance != 10_000_00 {
+		t.Errorf("Reject(): points = %v, balance = %v", account.Points, account.Balance)
+	}
+	postings, _ := s.AccountPostings(account.ID)
+	last := postings[len(postings)-1]
+	if last.Kind != types.PostingPointsClawback || last.Amount != -80_00 || last.PaymentID != payment.ID {
+		t.Errorf("Reject(): last posting = %v", last)
+	}
+}
+
+func TestService_Import_loyalty(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	campaign, _ := s.AddCampaign(types.Campaign{Kind: types.CampaignPoints, Percent: 2.5, MaxPerAccount: 100_00, End: 2_000_000_000})
+	payment := s.payAndComplete(t, account.ID, 1_000_00, "food")
+	_, _ = s.Refund(payment.ID, 100_00, "")
+
+	dir := t.TempDir()
+	err := s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotAccount, _ := imported.FindAccountByID(account.ID)
+	if !reflect.DeepEqual(gotAccount, account) {
+		t.Errorf("Import(): account = %+v, want %+v", gotAccount, account)
+	}
+	gotCampaign, _ := imported.FindCampaignByID(campaign.ID)
+	if !reflect.DeepEqual(gotCampaign, campaign) {
+		t.Errorf("Import(): campaign = %+v, want %+v", gotCampaign, campaign)
+	}
+	rewards, _ := s.AccountRewards(account.ID)
+	gotRewards, _ := imported.AccountRewards(account.ID)
+	if !reflect.DeepEqual(gotRewards, rewards) {
+		t.Errorf("Import(): rewards = %v, want %v", gotRewards, rewards)
+	}
+}
@@ -0,0 +1,210 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/messenger"
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+var (
+	ErrMessengerNotSet   = errors.New("messenger is not set")
+	ErrPaymentNotPending = errors.New("payment is not pending confirmation")
+	ErrInvalidCode       = errors.New("invalid confirmation code")
+	ErrCodeExpired       = errors.New("confirmation code expired")
+	ErrTooManyAttempts   = errors.New("too many confirmation attempts")
+)
+
+//Параметры подтверждения по умолчанию
+const (
+	DefaultOTPTTL      = 5 * time.Minute
+	DefaultOTPAttempts = 3
+)
+
+//otp одноразовый код подтверждения платежа. Код хранится и выгружается как HMAC с секретом сервиса,
+//сам секрет не выгружается: без него по выгрузке код не подобрать
+type otp struct {
+	hash     string
+	expires  time.Time
+	attempts int
+}
+
+//SetMessenger задает канал отправки одноразовых кодов
+func (s *Service) SetMessenger(m messenger.Messenger) {
+	s.messenger = m
+}
+
+//SetConfirmationThreshold задает сумму, платежи больше которой требуют подтверждения кодом. 0 отключает подтверждение
+func (s *Service) SetConfirmationThreshold(accountID int64, threshold types.Money) error {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+	if s.thresholds == nil {
+		s.thresholds = map[int64]types.Money{}
+	}
+	s.thresholds[accountID] = threshold
+	return nil
+}
+
+//SetOTPSecret задает секрет для HMAC кодов подтверждения. Если секрет не задан, при первом коде генерируется
+//случайный, и после перезапуска коды из выгрузки не подходят: такие платежи отклоняются по истечении кода
+func (s *Service) SetOTPSecret(secret []byte) {
+	s.otpSecret = append([]byte(nil), secret...)
+}
+
+//SetConfirmationPolicy задает время жизни кода и число попыток ввода
+func (s *Service) SetConfirmationPolicy(ttl time.Duration, attempts int) {
+	s.otpTTL = ttl
+	s.otpAttempts = attempts
+}
+
+func (s *Service) requiresConfirmation(accountID int64, amount types.Money) bool {
+	threshold := s.thresholds[accountID]
+	return threshold > 0 && amount > threshold
+}
+
+//payPending отправляет код на телефон счета и создает платеж в статусе PENDING.
+//Деньги списываются сразу и возвращаются, если платеж не подтвержден
+func (s *Service) payPending(account *types.Account, amount types.Money, fee types.Money, category types.PaymentCategory, kind types.PostingKind) (*types.Payment, error) {
+	if s.messenger == nil {
+		return nil, ErrMessengerNotSet
+	}
+	code, err := generateCode()
+	if err != nil {
+		return nil, err
+	}
+	err = s.messenger.Send(string(account.Phone), fmt.Sprintf("Код подтверждения платежа %s (%s): %s", amount.Decimal(), category, code))
+	if err != nil {
+		return nil, err
+	}
+
+	payment := s.debit(account, amount, fee, category, types.PaymentStatusPending, kind)
+	ttl := s.otpTTL
+	if ttl <= 0 {
+		ttl = DefaultOTPTTL
+	}
+	if s.otps == nil {
+		s.otps = map[string]*otp{}
+	}
+	s.otps[payment.ID] = &otp{hash: s.hashCode(code), expires: s.now().Add(ttl)}
+	return payment, nil
+}
+
+//ConfirmPayment подтверждает платеж кодом. Просроченный платеж и платеж, по которому исчерпаны попытки, отклоняются
+func (s *Service) ConfirmPayment(paymentID string, code string) error {
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return err
+	}
+	entry, ok := s.otps[paymentID]
+	if payment.Status != types.PaymentStatusPending || !ok {
+		return ErrPaymentNotPending
+	}
+	if !s.now().Before(entry.expires) {
+		err = s.Reject(paymentID)
+		if err != nil {
+			return err
+		}
+		return ErrCodeExpired
+	}
+	if subtle.ConstantTimeCompare([]byte(entry.hash), []byte(s.hashCode(code))) != 1 {
+		entry.attempts++
+		attempts := s.otpAttempts
+		if attempts <= 0 {
+			attempts = DefaultOTPAttempts
+		}
+		if entry.attempts < attempts {
+			return ErrInvalidCode
+		}
+		err = s.Reject(paymentID)
+		if err != nil {
+			return err
+		}
+		return ErrTooManyAttempts
+	}
+
+	delete(s.otps, paymentID)
+	payment.Status = types.PaymentStatusInProgress
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return err
+	}
+	s.accept(account, payment)
+	return nil
+}
+
+//RejectExpiredPayments отклоняет неподтвержденные платежи с истекшим кодом и возвращает их число.
+//Платеж в статусе PENDING без кода (например, из старой выгрузки) подтвердить нельзя, поэтому он тоже отклоняется
+func (s *Service) RejectExpiredPayments() int {
+	now := s.now()
+	count := 0
+	for _, payment := range s.payments {
+		if payment.Status != types.PaymentStatusPending {
+			continue
+		}
+		entry, ok := s.otps[payment.ID]
+		if ok && now.Before(entry.expires) {
+			continue
+		}
+		if s.Reject(payment.ID) == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Service) hashCode(code string) string {
+	if len(s.otpSecret) == 0 {
+		s.otpSecret = make([]byte, 32)
+		_, _ = rand.Read(s.otpSecret)
+	}
+	mac := hmac.New(sha256.New, s.otpSecret)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Service) otpLines() []string {
+	var lines []string
+	for _, payment := range s.payments {
+		entry, ok := s.otps[payment.ID]
+		if !ok {
+			continue
+		}
+		lines = append(lines, payment.ID+";"+entry.hash+";"+strconv.FormatInt(entry.expires.Unix(), 10)+";"+strconv.Itoa(entry.attempts))
+	}
+	return lines
+}
+
+func (s *Service) importOTP(otpStr []string) {
+	if len(otpStr) < 4 {
+		return
+	}
+	Expires, _ := strconv.ParseInt(otpStr[2], 10, 64)
+	Attempts, _ := strconv.Atoi(otpStr[3])
+	payment, err := s.FindPaymentByID(otpStr[0])
+	if err != nil || payment.Status != types.PaymentStatusPending {
+		return
+	}
+	if s.otps == nil {
+		s.otps = map[string]*otp{}
+	}
+	s.otps[payment.ID] = &otp{hash: otpStr[1], expires: time.Unix(Expires, 0), attempts: Attempts}
+}
+
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,192 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/messenger"
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+//requireConfirmation включает подтверждение кодом для платежей счета больше threshold и возвращает мессенджер, в который уходят коды
+func (s *testService) requireConfirmation(t *testing.T, account *types.Account, threshold types.Money) *messenger.Memory {
+	m := messenger.NewMemory()
+	s.SetMessenger(m)
+	err := s.SetConfirmationThreshold(account.ID, threshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func lastCode(m *messenger.Memory) string {
+	sent := m.Sent()
+	text := sent[len(sent)-1].Text
+	return text[strings.LastIndex(text, " ")+1:]
+}
+
+func TestService_ConfirmPayment_success(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	m := s.requireConfirmation(t, account, 1_000_00)
+	small, err := s.Pay(account.ID, 1_000_00, "auto")
+	if err != nil || small.Status != types.PaymentStatusInProgress {
+		t.Fatalf("Pay(): payment = %v, error = %v", small, err)
+	}
+	payment, err := s.Pay(account.ID, 2_000_00, "auto")
+	if err != nil || payment.Status != types.PaymentStatusPending {
+		t.Fatalf("Pay(): payment = %v, error = %v", payment, err)
+	}
+	if account.Balance != 7_000_00 {
+		t.Errorf("Pay(): pending payment must reserve funds, balance = %v", account.Balance)
+	}
+	sent := m.Sent()
+	if len(sent) != 1 || sent[0].To != "+992928885522" {
+		t.Fatalf("Pay(): wrong code messages = %v", sent)
+	}
+
+	err = s.ConfirmPayment(payment.ID, "wrong")
+	if err != ErrInvalidCode {
+		t.Errorf("ConfirmPayment(): must return ErrInvalidCode, returned = %v", err)
+	}
+	err = s.ConfirmPayment(payment.ID, lastCode(m))
+	if err != nil {
+		t.Errorf("ConfirmPayment(): error = %v", err)
+	}
+	if payment.Status != types.PaymentStatusInProgress {
+		t.Errorf("ConfirmPayment(): status = %v", payment.Status)
+	}
+	err = s.ConfirmPayment(payment.ID, lastCode(m))
+	if err != ErrPaymentNotPending {
+		t.Errorf("ConfirmPayment(): must return ErrPaymentNotPending, returned = %v", err)
+	}
+}
+
+func TestService_ConfirmPayment_attempts(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	s.requireConfirmation(t, account, 1_000_00)
+	s.SetConfirmationPolicy(time.Minute, 2)
+	payment, _ := s.Pay(account.ID, 2_000_00, "auto")
+	_ = s.ConfirmPayment(payment.ID, "wrong")
+	err := s.ConfirmPayment(payment.ID, "wrong")
+	if err != ErrTooManyAttempts {
+		t.Errorf("ConfirmPayment(): must return ErrTooManyAttempts, returned = %v", err)
+	}
+	if payment.Status != types.PaymentStatusFail || account.Balance != 10_000_00 {
+		t.Errorf("ConfirmPayment(): payment = %v, balance = %v", payment, account.Balance)
+	}
+}
+
+func TestService_ConfirmPayment_expired(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 10_000_00)
+	m := s.requireConfirmation(t, account, 1_000_00)
+	payment, _ := s.Pay(account.ID, 2_000_00, "auto")
+	*now = now.Add(DefaultOTPTTL)
+	err := s.ConfirmPayment(payment.ID, lastCode(m))
+	if err != ErrCodeExpired {
+		t.Errorf("ConfirmPayment(): must return ErrCodeExpired, returned = %v", err)
+	}
+	if payment.Status != types.PaymentStatusFail || account.Balance != 10_000_00 {
+		t.Errorf("ConfirmPayment(): payment = %v, balance = %v", payment, account.Balance)
+	}
+}
+
+func TestService_RejectExpiredPayments(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 10_000_00)
+	s.requireConfirmation(t, account, 1_000_00)
+	first, _ := s.Pay(account.ID, 2_000_00, "auto")
+	*now = now.Add(time.Minute)
+	second, _ := s.Pay(account.ID, 3_000_00, "auto")
+	*now = now.Add(DefaultOTPTTL - time.Second)
+
+	if count := s.RejectExpiredPayments(); count != 1 {
+		t.Errorf("RejectExpiredPayments(): rejected %d payments, want 1", count)
+	}
+	if first.Status != types.PaymentStatusFail || second.Status != types.PaymentStatusPending {
+		t.Errorf("RejectExpiredPayments(): first = %v, second = %v", first.Status, second.Status)
+	}
+	if account.Balance != 7_000_00 {
+		t.Errorf("RejectExpiredPayments(): balance = %v", account.Balance)
+	}
+}
+
+func TestService_Pay_messengerNotSet(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	s.requireConfirmation(t, account, 1_000_00)
+	s.SetMessenger(nil)
+	_, err := s.Pay(account.ID, 2_000_00, "auto")
+	if err != ErrMessengerNotSet {
+		t.Errorf("Pay(): must return ErrMessengerNotSet, returned = %v", err)
+	}
+	if account.Balance != 10_000_00 {
+		t.Errorf("Pay(): balance changed = %v", account.Balance)
+	}
+}
+
+func TestService_Import_otp(t *testing.T) {
+	s, account, now := newClockedTestService(t, testNow, 10_000_00)
+	m := s.requireConfirmation(t, account, 1_000_00)
+	s.SetOTPSecret([]byte("server secret"))
+	payment, _ := s.Pay(account.ID, 2_000_00, "auto")
+	_ = s.ConfirmPayment(payment.ID, "wrong")
+	other, _ := s.Pay(account.ID, 3_000_00, "auto")
+
+	dir := t.TempDir()
+	err := s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump, _ := ioutil.ReadFile(dir + "/otps.dump")
+	sum := sha256.Sum256([]byte(lastCode(m)))
+	if strings.Contains(string(dump), lastCode(m)) || strings.Contains(string(dump), hex.EncodeToString(sum[:])) {
+		t.Errorf("Export(): code must not be recoverable from dump without secret, dump = %s", dump)
+	}
+
+	//без секрета коды из выгрузки не подходят
+	foreign := newTestService()
+	foreign.SetClock(func() time.Time { return *now })
+	err = foreign.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := foreign.ConfirmPayment(other.ID, lastCode(m)); err != ErrInvalidCode {
+		t.Errorf("ConfirmPayment(): must return ErrInvalidCode without secret, returned = %v", err)
+	}
+
+	imported := newTestService()
+	imported.SetClock(func() time.Time { return *now })
+	imported.SetConfirmationPolicy(time.Minute, 2)
+	imported.SetOTPSecret([]byte("server secret"))
+	err = imported.Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imported.ConfirmPayment(other.ID, lastCode(m)); err != nil {
+		t.Errorf("ConfirmPayment(): error = %v", err)
+	}
+	//попытки переносятся из выгрузки: вторая неверная попытка отклоняет платеж
+	if err := imported.ConfirmPayment(payment.ID, "wrong"); err != ErrTooManyAttempts {
+		t.Errorf("ConfirmPayment(): must return ErrTooManyAttempts, returned = %v", err)
+	}
+
+	gotAccount, _ := imported.FindAccountByID(account.ID)
+	if gotAccount.Balance != 7_000_00 {
+		t.Errorf("ConfirmPayment(): balance = %v", gotAccount.Balance)
+	}
+}
+
+func TestService_RejectExpiredPayments_withoutCode(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	s.requireConfirmation(t, account, 1_000_00)
+	payment, _ := s.Pay(account.ID, 2_000_00, "auto")
+	delete(s.otps, payment.ID)
+	if err := s.ConfirmPayment(payment.ID, "000000"); err != ErrPaymentNotPending {
+		t.Errorf("ConfirmPayment(): must return ErrPaymentNotPending, returned = %v", err)
+	}
+	if count := s.RejectExpiredPayments(); count != 1 || payment.Status != types.PaymentStatusFail {
+		t.Errorf("RejectExpiredPayments(): rejected %d payments, status = %v", count, payment.Status)
+	}
+}
@@ -0,0 +1,121 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPaymentNotRefundable = errors.New("payment can not be refunded")
+	ErrRefundExceedsPayment = errors.New("refund amount exceeds payment")
+	ErrRefundNotFound       = errors.New("refund not found")
+)
+
+//Refund возвращает на счет часть суммы завершенного платежа. Возвратов может быть несколько, пока их сумма не превысит сумму платежа.
+//Платеж INPROGRESS еще может быть отклонен, его не возвращают, а отклоняют
+func (s *Service) Refund(paymentID string, amount types.Money, reason string) (*types.Refund, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	switch payment.Status {
+	case types.PaymentStatusOk, types.PaymentStatusPartiallyRefunded:
+	default:
+		return nil, ErrPaymentNotRefundable
+	}
+	if s.findTransfer(payment.ID) != nil {
+		return nil, ErrPaymentNotRefundable
+	}
+	if s.openDispute(payment.ID) != nil {
+		return nil, ErrPaymentDisputed
+	}
+	if payment.Refunded+amount > payment.Amount {
+		return nil, ErrRefundExceedsPayment
+	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &types.Refund{
+		ID:        uuid.New().String(),
+		PaymentID: payment.ID,
+		AccountID: account.ID,
+		Amount:    amount,
+		Reason:    dumpText(reason),
+		Created:   s.now().Unix(),
+	}
+	s.refunds = append(s.refunds, refund)
+	payment.Refunded += amount
+	payment.Status = types.PaymentStatusPartiallyRefunded
+	if payment.Refunded == payment.Amount {
+		payment.Status = types.PaymentStatusRefunded
+	}
+	s.post(account, amount, types.PostingRefund, payment.ID)
+	s.clawback(account, payment, false)
+	s.emit(EventRefund, account, amount, payment)
+	return refund, nil
+}
+
+func (s *Service) FindRefundByID(refundID string) (*types.Refund, error) {
+	for _, refund := range s.refunds {
+		if refund.ID == refundID {
+			return refund, nil
+		}
+	}
+	return nil, ErrRefundNotFound
+}
+
+//PaymentRefunds возвращает возвраты по платежу в порядке создания
+func (s *Service) PaymentRefunds(paymentID string) ([]types.Refund, error) {
+	_, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	var refunds []types.Refund
+	for _, refund := range s.refunds {
+		if refund.PaymentID == paymentID {
+			refunds = append(refunds, *refund)
+		}
+	}
+	return refunds, nil
+}
+
+func (s *Service) refundLines() []string {
+	var lines []string
+	for _, refund := range s.refunds {
+		lines = append(lines, refund.ToString())
+	}
+	return lines
+}
+
+//importRefund восстанавливает возврат и пересчитывает сумму возвратов платежа
+func (s *Service) importRefund(refundStr []string) {
+	if len(refundStr) < 6 {
+		return
+	}
+	AccountID, _ := strconv.ParseInt(refundStr[2], 10, 64)
+	Amount, _ := strconv.ParseInt(refundStr[3], 10, 64)
+	Created, _ := strconv.ParseInt(refundStr[5], 10, 64)
+	refund, err := s.FindRefundByID(refundStr[0])
+	if err != nil {
+		refund = &types.Refund{ID: refundStr[0]}
+		s.refunds = append(s.refunds, refund)
+	} else if payment, err := s.FindPaymentByID(refund.PaymentID); err == nil {
+		payment.Refunded -= refund.Amount
+	}
+	refund.PaymentID = refundStr[1]
+	refund.AccountID = AccountID
+	refund.Amount = types.Money(Amount)
+	refund.Reason = refundStr[4]
+	refund.Created = Created
+	if payment, err := s.FindPaymentByID(refund.PaymentID); err == nil {
+		payment.Refunded += refund.Amount
+	}
+}
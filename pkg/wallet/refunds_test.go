@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func TestService_Refund(t *testing.T) {
+	s := newTestService()
+	s.SetClock(func() time.Time { return time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC) })
+	account, _ := s.addAccountWithBalance("+992928885522", 1_000_00)
+	var events []Event
+	s.Subscribe(func(event Event) { events = append(events, event) })
+	payment, _ := s.Pay(account.ID, 300_00, "food")
+	if _, err := s.Refund(payment.ID, 100_00, ""); err != ErrPaymentNotRefundable {
+		t.Errorf("Refund(): INPROGRESS payment must return ErrPaymentNotRefundable, returned = %v", err)
+	}
+	_ = s.Complete(payment.ID)
+
+	first, err := s.Refund(payment.ID, 100_00, "damaged; box")
+	if err != nil {
+		t.Fatalf("Refund(): error = %v", err)
+	}
+	if first.PaymentID != payment.ID || first.Amount != 100_00 || first.Reason != "damaged, box" || first.AccountID != account.ID {
+		t.Errorf("Refund(): refund = %v", first)
+	}
+	if payment.Status != types.PaymentStatusPartiallyRefunded || payment.Refunded != 100_00 || account.Balance != 800_00 {
+		t.Errorf("Refund(): payment = %v, balance = %v", payment, account.Balance)
+	}
+	if _, err := s.Refund(payment.ID, 250_00, ""); err != ErrRefundExceedsPayment {
+		t.Errorf("Refund(): must return ErrRefundExceedsPayment, returned = %v", err)
+	}
+	second, _ := s.Refund(payment.ID, 200_00, "")
+	if payment.Status != types.PaymentStatusRefunded || payment.Refunded != 300_00 || account.Balance != 1_000_00 {
+		t.Errorf("Refund(): payment = %v, balance = %v", payment, account.Balance)
+	}
+	if _, err := s.Refund(payment.ID, 1, ""); err != ErrPaymentNotRefundable {
+		t.Errorf("Refund(): must return ErrPaymentNotRefundable, returned = %v", err)
+	}
+	if _, err := s.Refund(payment.ID, 0, ""); err != ErrAmountMustBePositive {
+		t.Errorf("Refund(): must return ErrAmountMustBePositive, returned = %v", err)
+	}
+	if _, err := s.Refund("unknown", 1, ""); err != ErrPaymentNotFound {
+		t.Errorf("Refund(): must return ErrPaymentNotFound, returned = %v", err)
+	}
+
+	refunds, _ := s.PaymentRefunds(payment.ID)
+	if len(refunds) != 2 || refunds[0] != *first || refunds[1] != *second {
+		t.Errorf("PaymentRefunds(): got %v", refunds)
+	}
+	if len(events) != 3 || events[1].Type != EventRefund || events[1].Amount != 100_00 || events[2].Balance != 1_000_00 {
+		t.Errorf("events = %v", events)
+	}
+	postings, _ := s.AccountPostings(account.ID)
+	if last := postings[len(postings)-1]; last.Kind != types.PostingRefund || last.Amount != 200_00 || last.PaymentID != payment.ID {
+		t.Errorf("AccountPostings(): last = %v", last)
+	}
+}
+
+func TestService_Reject_afterRefund(t *testing.T) {
+	s := newTestService()
+	account, _ := s.addAccountWithBalance("+992928885522", 1_000_00)
+	payment, _ := s.Pay(account.ID, 300_00, "food")
+	_ = s.Complete(payment.ID)
+	_, _ = s.Refund(payment.ID, 100_00, "")
+
+	err := s.Reject(payment.ID)
+	if err != nil {
+		t.Fatalf("Reject(): error = %v", err)
+	}
+	if account.Balance != 1_000_00 {
+		t.Errorf("Reject(): balance = %v, want %v", account.Balance, types.Money(1_000_00))
+	}
+	if _, err := s.Refund(payment.ID, 100_00, ""); err != ErrPaymentNotRefundable {
+		t.Errorf("Refund(): must return ErrPaymentNotRefundable, returned = %v", err)
+	}
+}
+
+func TestService_Import_refunds(t *testing.T) {
+	s := newTestService()
+	account, _ := s.addAccountWithBalance("+992928885522", 1_000_00)
+	payment, _ := s.Pay(account.ID, 300_00, "food")
+	_ = s.Complete(payment.ID)
+	refund, _ := s.Refund(payment.ID, 100_00, "damaged")
+
+	dir := t.TempDir()
+	err := s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	for i := 0; i < 2; i++ {
+		err = imported.Import(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, _ := imported.FindPaymentByID(payment.ID)
+	if !reflect.DeepEqual(got, payment) {
+		t.Errorf("Import(): payment = %v, want %v", got, payment)
+	}
+	gotRefund, err := imported.FindRefundByID(refund.ID)
+	if err != nil || !reflect.DeepEqual(gotRefund, refund) {
+		t.Errorf("Import(): refund = %v, want %v", gotRefund, refund)
+	}
+}
@@ -0,0 +1,390 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRequestNotFound     = errors.New("payment request not found")
+	ErrRequestNotPending   = errors.New("payment request is not pending")
+	ErrNotRequestRecipient = errors.New("account is not payment request recipient")
+	ErrInvalidRequest      = errors.New("invalid payment request")
+	ErrSameAccount         = errors.New("can not transfer to the same account")
+	ErrTransferCompleted   = errors.New("completed transfer can not be rejected")
+	ErrTransferNotFound    = errors.New("transfer not found")
+)
+
+//DefaultRequestTTL - время, за которое получатель должен ответить на запрос денег
+const DefaultRequestTTL = 7 * 24 * time.Hour
+
+//TransferCategory - категория платежей, которыми проводятся переводы
+const TransferCategory types.PaymentCategory = "transfer"
+
+//SetRequestTTL задает время, через которое запрос денег без ответа истекает
+func (s *Service) SetRequestTTL(ttl time.Duration) {
+	s.requestTTL = ttl
+}
+
+//Transfer переводит деньги между счетами без комиссии и возвращает платеж категории TransferCategory.
+//Перевод проходит те же лимиты, антифрод и подтверждение кодом, что и Pay. Получатель получает деньги,
+//когда платеж проходит, после этого перевод нельзя отклонить, вернуть или оспорить
+func (s *Service) Transfer(fromAccountID int64, toAccountID int64, amount types.Money) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	from, err := s.FindAccountByID(fromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.FindAccountByID(toAccountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.transfer(from, to, amount, "")
+}
+
+//RequestMoney запрашивает одинаковую сумму у каждого телефона из phones
+func (s *Service) RequestMoney(accountID int64, amount types.Money, phones []types.Phone, note string) ([]types.PaymentRequest, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	amounts := make([]types.Money, len(phones))
+	for i := range amounts {
+		amounts[i] = amount
+	}
+	return s.request(accountID, phones, amounts, note)
+}
+
+//SplitBill делит сумму поровну между автором и телефонами phones и запрашивает у каждого его долю.
+//Остаток в минимальных единицах достается первым участникам, начиная с автора
+func (s *Service) SplitBill(accountID int64, total types.Money, phones []types.Phone, note string) ([]types.PaymentRequest, error) {
+	if total <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	shares := total.Split(len(phones) + 1)
+	if shares[len(shares)-1] <= 0 {
+		return nil, ErrInvalidRequest
+	}
+	return s.request(accountID, phones, shares[1:], note)
+}
+
+//AcceptRequest переводит запрошенную сумму со счета получателя автору запроса так же, как Transfer.
+//Запрос считается оплаченным, когда проходит платеж перевода, до этого повторно ответить на него нельзя
+func (s *Service) AcceptRequest(requestID string, accountID int64) (*types.Payment, error) {
+	request, account, err := s.recipientRequest(requestID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	author, err := s.FindAccountByID(request.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.transfer(account, author, request.Amount, request.ID)
+}
+
+//DeclineRequest отклоняет запрос денег
+func (s *Service) DeclineRequest(requestID string, accountID int64) error {
+	request, account, err := s.recipientRequest(requestID, accountID)
+	if err != nil {
+		return err
+	}
+	author, err := s.FindAccountByID(request.AccountID)
+	if err != nil {
+		return err
+	}
+	s.answer(request, types.RequestDeclined, author, account.Phone, EventRequestDeclined)
+	return nil
+}
+
+//ExpireRequests закрывает просроченные запросы и возвращает их
+func (s *Service) ExpireRequests() []types.PaymentRequest {
+	now := s.now().Unix()
+	var expired []types.PaymentRequest
+	for _, request := range s.requests {
+		if request.Status == types.RequestPending && request.Expires <= now && !s.requestInFlight(request.ID) {
+			request.Status = types.RequestExpired
+			request.Resolved = now
+			expired = append(expired, *request)
+		}
+	}
+	return expired
+}
+
+//IncomingRequests возвращает запросы денег, ожидающие ответа владельца счета
+func (s *Service) IncomingRequests(accountID int64) ([]types.PaymentRequest, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	s.ExpireRequests()
+	var requests []types.PaymentRequest
+	for _, request := range s.requests {
+		if request.Phone == account.Phone && request.Status == types.RequestPending {
+			requests = append(requests, *request)
+		}
+	}
+	return requests, nil
+}
+
+//OutgoingRequests возвращает все запросы денег, созданные счетом
+func (s *Service) OutgoingRequests(accountID int64) ([]types.PaymentRequest, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	s.ExpireRequests()
+	var requests []types.PaymentRequest
+	for _, request := range s.requests {
+		if request.AccountID == accountID {
+			requests = append(requests, *request)
+		}
+	}
+	return requests, nil
+}
+
+func (s *Service) FindRequestByID(requestID string) (*types.PaymentRequest, error) {
+	for _, request := range s.requests {
+		if request.ID == requestID {
+			return request, nil
+		}
+	}
+	return nil, ErrRequestNotFound
+}
+
+func (s *Service) FindTransferByPaymentID(paymentID string) (*types.Transfer, error) {
+	transfer := s.findTransfer(paymentID)
+	if transfer == nil {
+		return nil, ErrTransferNotFound
+	}
+	return transfer, nil
+}
+
+//transfer списывает сумму со счета from платежом-переводом. Проверки те же, что в Authorize и Capture
+func (s *Service) transfer(from *types.Account, to *types.Account, amount types.Money, requestID string) (*types.Payment, error) {
+	if from.ID == to.ID {
+		return nil, ErrSameAccount
+	}
+	err := s.checkLimits(from.ID, amount, TransferCategory)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkFunds(from, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	var payment *types.Payment
+	before := from.Balance
+	fraud := s.checkFraud(from.ID, amount, TransferCategory)
+	switch {
+	case fraud.Action == FraudBlock:
+		return s.block(from, amount, TransferCategory, fraud.Reason()), ErrPaymentBlocked
+	case fraud.Action == FraudReview:
+		payment = s.debit(from, amount, 0, TransferCategory, types.PaymentStatusReview, types.PostingTransferOut)
+		payment.Reason = fraud.Reason()
+	case s.requiresConfirmation(from.ID, amount):
+		payment, err = s.payPending(from, amount, 0, TransferCategory, types.PostingTransferOut)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		payment = s.debit(from, amount, 0, TransferCategory, types.PaymentStatusInProgress, types.PostingTransferOut)
+	}
+	s.transfers = append(s.transfers, &types.Transfer{PaymentID: payment.ID, ToID: to.ID, RequestID: requestID})
+	if payment.Status == types.PaymentStatusInProgress {
+		s.accept(from, payment)
+	}
+	s.emitLowBalance(from, before, payment)
+	return payment, nil
+}
+
+//settleTransfer зачисляет прошедший перевод получателю и закрывает запрос денег, по которому он сделан.
+//Возвращает false, если платеж не перевод
+func (s *Service) settleTransfer(from *types.Account, payment *types.Payment) bool {
+	transfer := s.findTransfer(payment.ID)
+	if transfer == nil {
+		return false
+	}
+	to, err := s.FindAccountByID(transfer.ToID)
+	if err != nil {
+		return true
+	}
+	payment.Status = types.PaymentStatusOk
+	s.post(to, payment.Amount, types.PostingTransferIn, payment.ID)
+	if request, err := s.FindRequestByID(transfer.RequestID); err == nil {
+		s.answer(request, types.RequestAccepted, to, from.Phone, EventRequestAccepted)
+	}
+	return true
+}
+
+func (s *Service) findTransfer(paymentID string) *types.Transfer {
+	for _, transfer := range s.transfers {
+		if transfer.PaymentID == paymentID {
+			return transfer
+		}
+	}
+	return nil
+}
+
+//requestInFlight сообщает, ждет ли перевод по запросу подтверждения кодом или проверки
+func (s *Service) requestInFlight(requestID string) bool {
+	for _, transfer := range s.transfers {
+		if transfer.RequestID != requestID {
+			continue
+		}
+		payment, err := s.FindPaymentByID(transfer.PaymentID)
+		if err == nil && (payment.Status == types.PaymentStatusPending || payment.Status == types.PaymentStatusReview) {
+			return true
+		}
+	}
+	return false
+}
+
+//request создает запросы денег к телефонам phones на суммы amounts и уведомляет получателей
+func (s *Service) request(accountID int64, phones []types.Phone, amounts []types.Money, note string) ([]types.PaymentRequest, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if len(phones) == 0 {
+		return nil, ErrInvalidRequest
+	}
+	seen := map[types.Phone]bool{}
+	for _, phone := range phones {
+		if phone == "" || phone == account.Phone || seen[phone] {
+			return nil, ErrInvalidRequest
+		}
+		seen[phone] = true
+	}
+
+	ttl := s.requestTTL
+	if ttl <= 0 {
+		ttl = DefaultRequestTTL
+	}
+	now := s.now()
+	groupID := uuid.New().String()
+	requests := make([]types.PaymentRequest, 0, len(phones))
+	for i, phone := range phones {
+		request := &types.PaymentRequest{
+			ID:        uuid.New().String(),
+			GroupID:   groupID,
+			AccountID: account.ID,
+			Phone:     phone,
+			Amount:    amounts[i],
+			Note:      dumpText(note),
+			Status:    types.RequestPending,
+			Created:   now.Unix(),
+			Expires:   now.Add(ttl).Unix(),
+		}
+		s.requests = append(s.requests, request)
+		requests = append(requests, *request)
+
+		event := Event{
+			Type:         EventMoneyRequested,
+			Phone:        phone,
+			Amount:       request.Amount,
+			Counterparty: account.Phone,
+			Created:      request.Created,
+		}
+		if recipient, err := s.FindAccountByPhone(phone); err == nil {
+			event.AccountID = recipient.ID
+			event.Balance = recipient.Balance
+		}
+		s.emitEvent(event)
+	}
+	return requests, nil
+}
+
+//recipientRequest возвращает ожидающий ответа запрос, адресованный владельцу счета
+func (s *Service) recipientRequest(requestID string, accountID int64) (*types.PaymentRequest, *types.Account, error) {
+	request, err := s.FindRequestByID(requestID)
+	if err != nil {
+		return nil, nil, err
+	}
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if request.Phone != account.Phone {
+		return nil, nil, ErrNotRequestRecipient
+	}
+	s.ExpireRequests()
+	if request.Status != types.RequestPending || s.requestInFlight(request.ID) {
+		return nil, nil, ErrRequestNotPending
+	}
+	return request, account, nil
+}
+
+func (s *Service) answer(request *types.PaymentRequest, status types.RequestStatus, author *types.Account, phone types.Phone, eventType EventType) {
+	request.Status = status
+	request.Resolved = s.now().Unix()
+	s.emitEvent(Event{
+		Type:         eventType,
+		AccountID:    author.ID,
+		Phone:        author.Phone,
+		Amount:       request.Amount,
+		Balance:      author.Balance,
+		Counterparty: phone,
+		Created:      request.Resolved,
+	})
+}
+
+func (s *Service) requestLines() []string {
+	var lines []string
+	for _, request := range s.requests {
+		lines = append(lines, request.ToString())
+	}
+	return lines
+}
+
+func (s *Service) importRequest(requestStr []string) {
+	if len(requestStr) < 10 {
+		return
+	}
+	AccountID, _ := strconv.ParseInt(requestStr[2], 10, 64)
+	Amount, _ := strconv.ParseInt(requestStr[4], 10, 64)
+	Created, _ := strconv.ParseInt(requestStr[7], 10, 64)
+	Expires, _ := strconv.ParseInt(requestStr[8], 10, 64)
+	Resolved, _ := strconv.ParseInt(requestStr[9], 10, 64)
+	request, err := s.FindRequestByID(requestStr[0])
+	if err != nil {
+		request = &types.PaymentRequest{ID: requestStr[0]}
+		s.requests = append(s.requests, request)
+	}
+	request.GroupID = requestStr[1]
+	request.AccountID = AccountID
+	request.Phone = types.Phone(requestStr[3])
+	request.Amount = types.Money(Amount)
+	request.Note = requestStr[5]
+	request.Status = types.RequestStatus(requestStr[6])
+	request.Created = Created
+	request.Expires = Expires
+	request.Resolved = Resolved
+}
+
+func (s *Service) transferLines() []string {
+	var lines []string
+	for _, transfer := range s.transfers {
+		lines = append(lines, transfer.ToString())
+	}
+	return lines
+}
+
+func (s *Service) importTransfer(transferStr []string) {
+	if len(transferStr) < 3 {
+		return
+	}
+	ToID, _ := strconv.ParseInt(transferStr[1], 10, 64)
+	transfer := s.findTransfer(transferStr[0])
+	if transfer == nil {
+		transfer = &types.Transfer{PaymentID: transferStr[0]}
+		s.transfers = append(s.transfers, transfer)
+	}
+	transfer.ToID = ToID
+	transfer.RequestID = transferStr[2]
+}
@@ -0,0 +1,247 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func TestMoney_Split(t *testing.T) {
+	tests := []struct {
+		amount types.Money
+		parts  int
+		want   []types.Money
+	}{
+		{100_00, 3, []types.Money{33_34, 33_33, 33_33}},
+		{100_00, 4, []types.Money{25_00, 25_00, 25_00, 25_00}},
+		{2, 3, []types.Money{1, 1, 0}},
+		{100_00, 0, nil},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Split(tt.parts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%v, %v) = %v, want %v", tt.amount, tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestService_Transfer(t *testing.T) {
+	s := newTestService()
+	from, err := s.addAccountWithBalance("+992928885522", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, _ := s.RegisterAccount("+992928885533")
+
+	_, err = s.Transfer(from.ID, to.ID, 40_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from.Balance != 60_00 || to.Balance != 40_00 {
+		t.Errorf("Transfer(): from = %v, to = %v", from.Balance, to.Balance)
+	}
+	if _, err := s.Transfer(from.ID, to.ID, 70_00); err != ErrNotEnoughBalance {
+		t.Errorf("Transfer(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	if _, err := s.Transfer(from.ID, from.ID, 10_00); err != ErrSameAccount {
+		t.Errorf("Transfer(): must return ErrSameAccount, returned = %v", err)
+	}
+}
+
+func TestService_Transfer_checks(t *testing.T) {
+	s, from, _ := newClockedTestService(t, testNow, 10_000_00)
+	to, _ := s.RegisterAccount("+992928885533")
+	_ = s.SetLimit(types.Limit{AccountID: from.ID, Category: TransferCategory, PerTransaction: 3_000_00})
+	_ = s.SetFraudRules([]FraudRule{
+		{Name: "new-account", Kind: FraudNewAccount, Action: FraudBlock, Window: Duration(24 * time.Hour), Amount: 2_000_00},
+	})
+
+	if _, err := s.Transfer(from.ID, to.ID, 4_000_00); err != ErrLimitPerTransaction {
+		t.Errorf("Transfer(): must return ErrLimitPerTransaction, returned = %v", err)
+	}
+	payment, err := s.Transfer(from.ID, to.ID, 2_500_00)
+	if err != ErrPaymentBlocked {
+		t.Fatalf("Transfer(): must return ErrPaymentBlocked, returned = %v", err)
+	}
+	if payment.Status != types.PaymentStatusFail || payment.Category != TransferCategory || from.Balance != 10_000_00 || to.Balance != 0 {
+		t.Errorf("Transfer(): payment = %v, from = %v, to = %v", payment, from.Balance, to.Balance)
+	}
+
+	payment, err = s.Transfer(from.ID, to.ID, 1_000_00)
+	if err != nil || payment.Status != types.PaymentStatusOk || from.Balance != 9_000_00 || to.Balance != 1_000_00 {
+		t.Fatalf("Transfer(): payment = %v, from = %v, to = %v, error = %v", payment, from.Balance, to.Balance, err)
+	}
+	if err := s.Reject(payment.ID); err != ErrTransferCompleted {
+		t.Errorf("Reject(): must return ErrTransferCompleted, returned = %v", err)
+	}
+	if _, err := s.Refund(payment.ID, 1_00, ""); err != ErrPaymentNotRefundable {
+		t.Errorf("Refund(): must return ErrPaymentNotRefundable, returned = %v", err)
+	}
+	if _, err := s.OpenDispute(payment.ID, "", ""); err != ErrPaymentNotDisputable {
+		t.Errorf("OpenDispute(): must return ErrPaymentNotDisputable, returned = %v", err)
+	}
+}
+
+func TestService_AcceptRequest_confirmation(t *testing.T) {
+	s, friend, _ := newClockedTestService(t, testNow, 10_000_00)
+	author, _ := s.RegisterAccount("+992928885533")
+	m := s.requireConfirmation(t, friend, 1_000_00)
+	requests, _ := s.RequestMoney(author.ID, 2_000_00, []types.Phone{friend.Phone}, "rent")
+
+	payment, err := s.AcceptRequest(requests[0].ID, friend.ID)
+	if err != nil || payment.Status != types.PaymentStatusPending || friend.Balance != 8_000_00 || author.Balance != 0 {
+		t.Fatalf("AcceptRequest(): payment = %v, friend = %v, author = %v, error = %v", payment, friend.Balance, author.Balance, err)
+	}
+	if _, err := s.AcceptRequest(requests[0].ID, friend.ID); err != ErrRequestNotPending {
+		t.Errorf("AcceptRequest(): must return ErrRequestNotPending while confirming, returned = %v", err)
+	}
+	_ = s.Reject(payment.ID)
+	if friend.Balance != 10_000_00 || author.Balance != 0 {
+		t.Errorf("Reject(): friend = %v, author = %v", friend.Balance, author.Balance)
+	}
+
+	payment, _ = s.AcceptRequest(requests[0].ID, friend.ID)
+	err = s.ConfirmPayment(payment.ID, lastCode(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, _ := s.FindRequestByID(requests[0].ID)
+	if payment.Status != types.PaymentStatusOk || request.Status != types.RequestAccepted || author.Balance != 2_000_00 {
+		t.Errorf("ConfirmPayment(): payment = %v, request = %v, author = %v", payment, request, author.Balance)
+	}
+	history, _ := s.ExportAccountHistory(friend.ID)
+	if len(history) != 2 || history[1].Category != TransferCategory {
+		t.Errorf("ExportAccountHistory(): transfers must be recorded, history = %v", history)
+	}
+}
+
+func TestService_SplitBill(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2021, 3, 10, 20, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	author, _ := s.RegisterAccount("+992928885522")
+	first, err := s.addAccountWithBalance("+992928885533", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.addAccountWithBalance("+992928885544", 10_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	s.Subscribe(func(event Event) {
+		events = append(events, event)
+	})
+
+	if _, err := s.SplitBill(author.ID, 100_00, []types.Phone{first.Phone, first.Phone}, "dinner"); err != ErrInvalidRequest {
+		t.Errorf("SplitBill(): must return ErrInvalidRequest for duplicate phones, returned = %v", err)
+	}
+	requests, err := s.SplitBill(author.ID, 100_00, []types.Phone{first.Phone, second.Phone}, "dinner; drinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || requests[0].Amount != 33_33 || requests[1].Amount != 33_33 ||
+		requests[0].GroupID != requests[1].GroupID || requests[0].Note != "dinner, drinks" {
+		t.Errorf("SplitBill(): requests = %v", requests)
+	}
+	if len(events) != 2 || events[0].Type != EventMoneyRequested || events[0].Phone != first.Phone || events[0].Counterparty != author.Phone {
+		t.Errorf("SplitBill(): events = %v", events)
+	}
+
+	if _, err := s.AcceptRequest(requests[0].ID, second.ID); err != ErrNotRequestRecipient {
+		t.Errorf("AcceptRequest(): must return ErrNotRequestRecipient, returned = %v", err)
+	}
+	_, err = s.AcceptRequest(requests[0].ID, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author.Balance != 33_33 || first.Balance != 66_67 {
+		t.Errorf("AcceptRequest(): author = %v, first = %v", author.Balance, first.Balance)
+	}
+	if _, err := s.AcceptRequest(requests[0].ID, first.ID); err != ErrRequestNotPending {
+		t.Errorf("AcceptRequest(): must return ErrRequestNotPending, returned = %v", err)
+	}
+	if _, err := s.AcceptRequest(requests[1].ID, second.ID); err != ErrNotEnoughBalance {
+		t.Errorf("AcceptRequest(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	err = s.DeclineRequest(requests[1].ID, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := events[len(events)-1]
+	if last.Type != EventRequestDeclined || last.AccountID != author.ID || last.Counterparty != second.Phone {
+		t.Errorf("DeclineRequest(): event = %v", last)
+	}
+
+	outgoing, _ := s.OutgoingRequests(author.ID)
+	if len(outgoing) != 2 || outgoing[0].Status != types.RequestAccepted || outgoing[1].Status != types.RequestDeclined {
+		t.Errorf("OutgoingRequests(): %v", outgoing)
+	}
+}
+
+func TestService_ExpireRequests(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2021, 3, 10, 20, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	s.SetRequestTTL(24 * time.Hour)
+	author, _ := s.RegisterAccount("+992928885522")
+	friend, err := s.addAccountWithBalance("+992928885533", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests, _ := s.RequestMoney(author.ID, 20_00, []types.Phone{friend.Phone, "+992928885599"}, "")
+
+	incoming, _ := s.IncomingRequests(friend.ID)
+	if len(incoming) != 1 || incoming[0].ID != requests[0].ID {
+		t.Errorf("IncomingRequests(): %v", incoming)
+	}
+	now = now.Add(24 * time.Hour)
+	if expired := s.ExpireRequests(); len(expired) != 2 {
+		t.Errorf("ExpireRequests(): %v", expired)
+	}
+	if _, err := s.AcceptRequest(requests[0].ID, friend.ID); err != ErrRequestNotPending {
+		t.Errorf("AcceptRequest(): must return ErrRequestNotPending, returned = %v", err)
+	}
+	if friend.Balance != 100_00 {
+		t.Errorf("AcceptRequest(): balance = %v", friend.Balance)
+	}
+}
+
+func TestService_Import_requests(t *testing.T) {
+	s := newTestService()
+	author, _ := s.RegisterAccount("+992928885522")
+	friend, err := s.addAccountWithBalance("+992928885533", 100_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests, _ := s.SplitBill(author.ID, 50_00, []types.Phone{friend.Phone}, "taxi")
+	payment, _ := s.AcceptRequest(requests[0].ID, friend.ID)
+
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	for i := 0; i < 2; i++ {
+		err = imported.Import(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, _ := s.OutgoingRequests(author.ID)
+	got, _ := imported.OutgoingRequests(author.ID)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Import(): requests = %v, want %v", got, want)
+	}
+	gotAuthor, _ := imported.FindAccountByID(author.ID)
+	if gotAuthor.Balance != 25_00 {
+		t.Errorf("Import(): author balance = %v", gotAuthor.Balance)
+	}
+	transfer, _ := s.FindTransferByPaymentID(payment.ID)
+	gotTransfer, err := imported.FindTransferByPaymentID(payment.ID)
+	if err != nil || !reflect.DeepEqual(gotTransfer, transfer) {
+		t.Errorf("Import(): transfer = %v, want %v, error = %v", gotTransfer, transfer, err)
+	}
+}
@@ -0,0 +1,142 @@
+package wallet
+
+import (
+	"context"
+	"sync"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+//ScanOptions настраивает параллельный обход платежей
+type ScanOptions struct {
+	//Workers число горутин, не меньше 1
+	Workers int
+	//ChunkSize размер куска, по умолчанию платежи делятся поровну между горутинами
+	ChunkSize int
+}
+
+//MapFunc обрабатывает кусок платежей и возвращает промежуточный результат
+type MapFunc func(payments []*types.Payment) interface{}
+
+//ReduceFunc сворачивает промежуточные результаты, вызывается по порядку кусков
+type ReduceFunc func(acc interface{}, part interface{}) interface{}
+
+//chunks делит n элементов на куски и возвращает их границы [from, to)
+func (o ScanOptions) chunks(n int) [][2]int {
+	size := o.ChunkSize
+	if size <= 0 {
+		size = (n + o.workers() - 1) / o.workers()
+	}
+	if size <= 0 {
+		size = 1
+	}
+	var bounds [][2]int
+	for from := 0; from < n; from += size {
+		to := from + size
+		if to > n {
+			to = n
+		}
+		bounds = append(bounds, [2]int{from, to})
+	}
+	return bounds
+}
+
+func (o ScanOptions) workers() int {
+	if o.Workers < 1 {
+		return 1
+	}
+	return o.Workers
+}
+
+//scan вызывает fn для каждого куска платежей в opts.Workers горутинах.
+//Если контекст отменен, оставшиеся куски не обрабатываются и возвращается ошибка контекста
+func scan(ctx context.Context, payments []*types.Payment, opts ScanOptions, fn func(part int, chunk []*types.Payment)) error {
+	bounds := opts.chunks(len(payments))
+	parts := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < opts.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range parts {
+				if ctx.Err() != nil {
+					continue
+				}
+				fn(part, payments[bounds[part][0]:bounds[part][1]])
+			}
+		}()
+	}
+
+	var err error
+	for part := range bounds {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case parts <- part:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(parts)
+	wg.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+//MapReduce параллельно применяет mapFn к кускам платежей и сворачивает результаты reduceFn начиная с initial.
+//Порядок свертки совпадает с порядком платежей, поэтому результат не зависит от числа горутин
+func (s *Service) MapReduce(ctx context.Context, opts ScanOptions, mapFn MapFunc, reduceFn ReduceFunc, initial interface{}) (interface{}, error) {
+	payments := s.payments
+	results := make([]interface{}, len(opts.chunks(len(payments))))
+	err := scan(ctx, payments, opts, func(part int, chunk []*types.Payment) {
+		results[part] = mapFn(chunk)
+	})
+	if err != nil {
+		return nil, err
+	}
+	acc := initial
+	for _, result := range results {
+		acc = reduceFn(acc, result)
+	}
+	return acc, nil
+}
+
+func sumChunk(payments []*types.Payment) interface{} {
+	sum := types.Money(0)
+	for _, payment := range payments {
+		if payment == nil {
+			continue
+		}
+		sum += payment.Amount
+	}
+	return sum
+}
+
+func addMoney(acc interface{}, part interface{}) interface{} {
+	return acc.(types.Money) + part.(types.Money)
+}
+
+func filterChunk(filter func(payment types.Payment) bool) MapFunc {
+	return func(payments []*types.Payment) interface{} {
+		var res []types.Payment
+		for _, payment := range payments {
+			if payment == nil {
+				continue
+			}
+			if filter(*payment) {
+				res = append(res, *payment)
+			}
+		}
+		return res
+	}
+}
+
+func appendPayments(acc interface{}, part interface{}) interface{} {
+	return append(acc.([]types.Payment), part.([]types.Payment)...)
+}
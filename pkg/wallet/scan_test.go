@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func newScanTestService(count int) *Service {
+	s := &Service{}
+	for i := 0; i < 5; i++ {
+		account, _ := s.RegisterAccount(types.Phone(fmt.Sprint("+99292000000", i)))
+		_ = s.Deposit(account.ID, 1_000_000_00)
+	}
+	for i := 0; i < count; i++ {
+		_, _ = s.Pay(int64(i%5+1), types.Money(i+1), "auto")
+	}
+	return s
+}
+
+func TestService_SumPayments_equivalence(t *testing.T) {
+	for _, count := range []int{0, 1, 7, 100, 1001} {
+		s := newScanTestService(count)
+		want := types.Money(count * (count + 1) / 2)
+		for _, goroutines := range []int{0, 1, 2, 3, 8, 2000} {
+			got := s.SumPayments(goroutines)
+			if got != want {
+				t.Errorf("SumPayments(%d) with %d payments = %v, want %v", goroutines, count, got, want)
+			}
+		}
+	}
+}
+
+func TestService_FilterPayments_equivalence(t *testing.T) {
+	s := newScanTestService(1001)
+	var want []types.Payment
+	for _, payment := range s.payments {
+		if payment.AccountID == 3 {
+			want = append(want, *payment)
+		}
+	}
+	for _, goroutines := range []int{1, 2, 3, 8, 2000} {
+		got, err := s.FilterPayments(3, goroutines)
+		if err != nil {
+			t.Errorf("FilterPayments(%d): error = %v", goroutines, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FilterPayments(%d): got %d payments, want %d in the same order", goroutines, len(got), len(want))
+		}
+	}
+}
+
+func TestService_FilterPaymentsByFn_equivalence(t *testing.T) {
+	s := newScanTestService(1001)
+	filter := func(payment types.Payment) bool {
+		return payment.Amount%7 == 0
+	}
+	var want []types.Payment
+	for _, payment := range s.payments {
+		if filter(*payment) {
+			want = append(want, *payment)
+		}
+	}
+	for _, goroutines := range []int{1, 2, 3, 8, 2000} {
+		got, err := s.FilterPaymentsByFn(filter, goroutines)
+		if err != nil {
+			t.Errorf("FilterPaymentsByFn(%d): error = %v", goroutines, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FilterPaymentsByFn(%d): got %d payments, want %d in the same order", goroutines, len(got), len(want))
+		}
+	}
+}
+
+func TestService_FilterPaymentsByFn_notFound(t *testing.T) {
+	s := newScanTestService(10)
+	_, err := s.FilterPaymentsByFn(func(payment types.Payment) bool { return false }, 4)
+	if err != ErrAccountNotFound {
+		t.Errorf("FilterPaymentsByFn(): must return ErrAccountNotFound, returned = %v", err)
+	}
+	//в один поток, как и раньше, пустой результат без ошибки
+	got, err := s.FilterPaymentsByFn(func(payment types.Payment) bool { return false }, 1)
+	if got != nil || err != nil {
+		t.Errorf("FilterPaymentsByFn(1): got %v, error = %v, want nil, nil", got, err)
+	}
+}
+
+func TestService_MapReduce_chunkSize(t *testing.T) {
+	s := newScanTestService(10)
+	count := func(payments []*types.Payment) interface{} {
+		return []int{len(payments)}
+	}
+	collect := func(acc interface{}, part interface{}) interface{} {
+		return append(acc.([]int), part.([]int)...)
+	}
+	got, err := s.MapReduce(context.Background(), ScanOptions{Workers: 2, ChunkSize: 4}, count, collect, []int(nil))
+	if err != nil {
+		t.Errorf("MapReduce(): error = %v", err)
+		return
+	}
+	want := []int{4, 4, 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MapReduce(): chunks = %v, want %v", got, want)
+	}
+}
+
+func TestService_MapReduce_cancel(t *testing.T) {
+	s := newScanTestService(100)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	mapFn := func(payments []*types.Payment) interface{} {
+		calls++
+		cancel()
+		return nil
+	}
+	_, err := s.MapReduce(ctx, ScanOptions{Workers: 1, ChunkSize: 10}, mapFn, func(acc, part interface{}) interface{} { return acc }, nil)
+	if err != context.Canceled {
+		t.Errorf("MapReduce(): must return context.Canceled, returned = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("MapReduce(): processed %d chunks after cancel, want 1", calls)
+	}
+}
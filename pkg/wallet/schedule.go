@@ -0,0 +1,179 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrScheduleNotFound    = errors.New("schedule not found")
+	ErrInvalidScheduleKind = errors.New("invalid schedule kind")
+)
+
+//ScheduleRun представляет результат выполнения расписания
+type ScheduleRun struct {
+	ScheduleID string
+	PaymentID  string
+	At         time.Time
+	Err        error
+}
+
+//AddSchedule создает расписание платежа из избранного с первым платежом в start.
+//Для ScheduleCron start задает нижнюю границу, а время платежей берется из spec в формате cron
+func (s *Service) AddSchedule(favoriteID string, kind types.ScheduleKind, start time.Time, spec string) (*types.Schedule, error) {
+	_, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+	schedule := &types.Schedule{
+		ID:         uuid.New().String(),
+		FavoriteID: favoriteID,
+		Kind:       kind,
+		Start:      start.Unix(),
+		Next:       start.Unix(),
+	}
+	switch kind {
+	case types.ScheduleOnce, types.ScheduleDaily, types.ScheduleWeekly, types.ScheduleMonthly:
+	case types.ScheduleCron:
+		cron, err := parseCron(spec)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Spec = spec
+		schedule.Next = cron.next(start.Add(-time.Minute)).Unix()
+	default:
+		return nil, ErrInvalidScheduleKind
+	}
+	s.schedules = append(s.schedules, schedule)
+	return schedule, nil
+}
+
+func (s *Service) FindScheduleByID(scheduleID string) (*types.Schedule, error) {
+	for _, schedule := range s.schedules {
+		if schedule.ID == scheduleID {
+			return schedule, nil
+		}
+	}
+	return nil, ErrScheduleNotFound
+}
+
+func (s *Service) RemoveSchedule(scheduleID string) error {
+	for i, schedule := range s.schedules {
+		if schedule.ID == scheduleID {
+			s.schedules = append(s.schedules[:i], s.schedules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrScheduleNotFound
+}
+
+//RunDueSchedules выполняет платежи по расписаниям, время которых наступило по часам сервиса.
+//Пропущенные периоды не догоняются: платеж выполняется один раз, а следующий назначается после текущего времени.
+//Ошибки платежа, например ErrNotEnoughBalance, сохраняются в расписании и не останавливают его
+func (s *Service) RunDueSchedules() []ScheduleRun {
+	now := s.now()
+	var runs []ScheduleRun
+	for _, schedule := range s.schedules {
+		if schedule.Next == 0 || schedule.Next > now.Unix() {
+			continue
+		}
+		run := ScheduleRun{ScheduleID: schedule.ID, At: now}
+		payment, err := s.PayFromFavorite(schedule.FavoriteID)
+		schedule.LastRun = now.Unix()
+		schedule.LastError = ""
+		schedule.LastPaymentID = ""
+		if err != nil {
+			run.Err = err
+			schedule.LastError = err.Error()
+			schedule.Failures++
+		} else {
+			run.PaymentID = payment.ID
+			schedule.LastPaymentID = payment.ID
+		}
+		schedule.Next = nextRun(schedule, now)
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+//nextRun возвращает время следующего платежа после now или 0
+func nextRun(schedule *types.Schedule, now time.Time) int64 {
+	start := time.Unix(schedule.Start, 0).In(now.Location())
+	step := func(n int) time.Time {
+		switch schedule.Kind {
+		case types.ScheduleDaily:
+			return start.AddDate(0, 0, n)
+		case types.ScheduleWeekly:
+			return start.AddDate(0, 0, 7*n)
+		}
+		return addMonths(start, n)
+	}
+
+	switch schedule.Kind {
+	case types.ScheduleDaily, types.ScheduleWeekly, types.ScheduleMonthly:
+		n := 1
+		for !step(n).After(now) {
+			n++
+		}
+		return step(n).Unix()
+	case types.ScheduleCron:
+		cron, err := parseCron(schedule.Spec)
+		if err != nil {
+			return 0
+		}
+		next := cron.next(now)
+		if next.IsZero() {
+			return 0
+		}
+		return next.Unix()
+	}
+	return 0
+}
+
+//addMonths прибавляет месяцы, оставаясь в последнем дне месяца, если в нем нет дня start
+func addMonths(start time.Time, n int) time.Time {
+	first := time.Date(start.Year(), start.Month()+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	day := start.Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func (s *Service) scheduleLines() []string {
+	var lines []string
+	for _, schedule := range s.schedules {
+		lines = append(lines, schedule.ToString())
+	}
+	return lines
+}
+
+func (s *Service) importSchedule(scheduleStr []string) {
+	if len(scheduleStr) < 10 {
+		return
+	}
+	ID := scheduleStr[0]
+	Start, _ := strconv.ParseInt(scheduleStr[4], 10, 64)
+	Next, _ := strconv.ParseInt(scheduleStr[5], 10, 64)
+	LastRun, _ := strconv.ParseInt(scheduleStr[6], 10, 64)
+	Failures, _ := strconv.Atoi(scheduleStr[9])
+	schedule, err := s.FindScheduleByID(ID)
+	if err != nil {
+		schedule = &types.Schedule{ID: ID}
+		s.schedules = append(s.schedules, schedule)
+	}
+	schedule.FavoriteID = scheduleStr[1]
+	schedule.Kind = types.ScheduleKind(scheduleStr[2])
+	schedule.Spec = scheduleStr[3]
+	schedule.Start = Start
+	schedule.Next = Next
+	schedule.LastRun = LastRun
+	schedule.LastPaymentID = scheduleStr[7]
+	schedule.LastError = scheduleStr[8]
+	schedule.Failures = Failures
+}
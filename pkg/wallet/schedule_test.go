@@ -0,0 +1,173 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+//monthEnd последний день месяца, на котором проверяется перенос ежемесячных платежей
+var monthEnd = time.Date(2021, 1, 31, 9, 0, 0, 0, time.UTC)
+
+//addFavorite оплачивает со счета 10.00 за телефон и сохраняет платеж в избранное
+func (s *testService) addFavorite(t *testing.T, account *types.Account) *types.Favorite {
+	payment, err := s.Pay(account.ID, 10_00, "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	favorite, err := s.FavoritePayment(payment.ID, "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return favorite
+}
+
+func TestService_RunDueSchedules_monthly(t *testing.T) {
+	s, account, now := newClockedTestService(t, monthEnd, 100_00)
+	favorite := s.addFavorite(t, account)
+	schedule, err := s.AddSchedule(favorite.ID, types.ScheduleMonthly, *now, "")
+	if err != nil {
+		t.Fatalf("AddSchedule(): error = %v", err)
+	}
+
+	var got []time.Time
+	for day := 0; day < 100; day++ {
+		for _, run := range s.RunDueSchedules() {
+			if run.Err != nil {
+				t.Errorf("RunDueSchedules(): error = %v", run.Err)
+			}
+			got = append(got, run.At)
+		}
+		*now = now.AddDate(0, 0, 1)
+	}
+	want := []string{"2021-01-31", "2021-02-28", "2021-03-31", "2021-04-30"}
+	if len(got) != len(want) {
+		t.Fatalf("RunDueSchedules(): runs at %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Format("2006-01-02") != want[i] {
+			t.Errorf("RunDueSchedules(): run %d at %v, want %v", i, got[i], want[i])
+		}
+	}
+	if schedule.Failures != 0 || schedule.LastPaymentID == "" {
+		t.Errorf("RunDueSchedules(): schedule = %+v", schedule)
+	}
+}
+
+func TestService_RunDueSchedules_failure(t *testing.T) {
+	s, account, now := newClockedTestService(t, monthEnd, 100_00)
+	favorite := s.addFavorite(t, account)
+	schedule, _ := s.AddSchedule(favorite.ID, types.ScheduleDaily, now.Add(time.Hour), "")
+	if runs := s.RunDueSchedules(); len(runs) != 0 {
+		t.Errorf("RunDueSchedules(): must not run before start, runs = %v", runs)
+	}
+
+	//10 дней баланса хватает на 9 платежей, пропущенные периоды не догоняются
+	for day := 0; day < 10; day++ {
+		*now = now.AddDate(0, 0, 1)
+		s.RunDueSchedules()
+	}
+	if account.Balance != 0 || schedule.Failures != 1 || schedule.LastError != ErrNotEnoughBalance.Error() {
+		t.Errorf("RunDueSchedules(): balance = %v, schedule = %+v", account.Balance, schedule)
+	}
+	*now = now.AddDate(0, 0, 5)
+	if runs := s.RunDueSchedules(); len(runs) != 1 || runs[0].Err != ErrNotEnoughBalance {
+		t.Errorf("RunDueSchedules(): runs = %v", runs)
+	}
+	want := time.Date(2021, 2, 15, 10, 0, 0, 0, time.UTC).Unix()
+	if schedule.Next != want {
+		t.Errorf("RunDueSchedules(): next = %v, want %v", time.Unix(schedule.Next, 0).UTC(), time.Unix(want, 0).UTC())
+	}
+}
+
+func TestService_RunDueSchedules_once(t *testing.T) {
+	s, account, now := newClockedTestService(t, monthEnd, 100_00)
+	favorite := s.addFavorite(t, account)
+	schedule, _ := s.AddSchedule(favorite.ID, types.ScheduleOnce, *now, "")
+	s.RunDueSchedules()
+	*now = now.AddDate(1, 0, 0)
+	if runs := s.RunDueSchedules(); len(runs) != 0 || schedule.Next != 0 {
+		t.Errorf("RunDueSchedules(): runs = %v, schedule = %+v", runs, schedule)
+	}
+}
+
+func TestService_AddSchedule_cron(t *testing.T) {
+	s, account, now := newClockedTestService(t, monthEnd, 100_00)
+	favorite := s.addFavorite(t, account)
+	//по будням в 9:30 и 18:30
+	schedule, err := s.AddSchedule(favorite.ID, types.ScheduleCron, *now, "30 9,18 * * 1-5")
+	if err != nil {
+		t.Fatalf("AddSchedule(): error = %v", err)
+	}
+	//31.01.2021 воскресенье
+	want := []time.Time{
+		time.Date(2021, 2, 1, 9, 30, 0, 0, time.UTC),
+		time.Date(2021, 2, 1, 18, 30, 0, 0, time.UTC),
+		time.Date(2021, 2, 2, 9, 30, 0, 0, time.UTC),
+	}
+	for _, at := range want {
+		if schedule.Next != at.Unix() {
+			t.Fatalf("AddSchedule(): next = %v, want %v", time.Unix(schedule.Next, 0).UTC(), at)
+		}
+		*now = at
+		s.RunDueSchedules()
+	}
+
+	_, err = s.AddSchedule(favorite.ID, types.ScheduleCron, *now, "61 * * * *")
+	if err != ErrInvalidCronSpec {
+		t.Errorf("AddSchedule(): must return ErrInvalidCronSpec, returned = %v", err)
+	}
+	_, err = s.AddSchedule(favorite.ID, "HOURLY", *now, "")
+	if err != ErrInvalidScheduleKind {
+		t.Errorf("AddSchedule(): must return ErrInvalidScheduleKind, returned = %v", err)
+	}
+}
+
+func TestCronSpec_next(t *testing.T) {
+	from := time.Date(2021, 1, 31, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2021, 1, 31, 9, 15, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * 0", time.Date(2021, 1, 31, 12, 0, 0, 0, time.UTC)},
+		{"0 8 * * 7", time.Date(2021, 2, 7, 8, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		cron, err := parseCron(tt.spec)
+		if err != nil {
+			t.Errorf("parseCron(%q): error = %v", tt.spec, err)
+			continue
+		}
+		if got := cron.next(from); !got.Equal(tt.want) {
+			t.Errorf("next(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestService_Export_schedules(t *testing.T) {
+	s, account, now := newClockedTestService(t, monthEnd, 100_00)
+	favorite := s.addFavorite(t, account)
+	schedule, _ := s.AddSchedule(favorite.ID, types.ScheduleWeekly, *now, "")
+	s.RunDueSchedules()
+	dir := t.TempDir()
+	err := s.Export(dir)
+	if err != nil {
+		t.Fatalf("Export(): error = %v", err)
+	}
+	srv := newTestService()
+	err = srv.Import(dir)
+	if err != nil {
+		t.Fatalf("Import(): error = %v", err)
+	}
+	got, err := srv.FindScheduleByID(schedule.ID)
+	if err != nil || *got != *schedule {
+		t.Fatalf("Import(): schedule = %+v, want %+v, error = %v", got, schedule, err)
+	}
+	if _, err := srv.FindFavoriteByID(got.FavoriteID); err != nil {
+		t.Errorf("Import(): schedule favorite not found, error = %v", err)
+	}
+}
@@ -1,16 +1,20 @@
 package wallet
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/SonnLarissa/wallet/pkg/messenger"
 	"github.com/SonnLarissa/wallet/pkg/types"
 	"github.com/google/uuid"
 	"io"
 	"io/ioutil"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -21,13 +25,80 @@ var (
 	ErrNotEnoughBalance     = errors.New("not enough balance")
 	ErrPaymentNotFound      = errors.New("payment not found")
 	ErrFavoriteNotFound     = errors.New("favorite not found")
+	ErrPaymentNotInProgress = errors.New("payment is not in progress")
 )
 
 type Service struct {
-	nextAccountID int64
-	accounts      []*types.Account
-	payments      []*types.Payment
-	favorites     []*types.Favorite
+	nextAccountID  int64
+	accounts       []*types.Account
+	payments       []*types.Payment
+	favorites      []*types.Favorite
+	postings       []*types.Posting
+	clock          func() time.Time
+	handlers       []func(event Event)
+	lowBalance     types.Money
+	messenger      messenger.Messenger
+	thresholds     map[int64]types.Money
+	otps           map[string]*otp
+	otpTTL         time.Duration
+	otpSecret      []byte
+	otpAttempts    int
+	schedules      []*types.Schedule
+	limits         []*types.Limit
+	fraudRules     []FraudRule
+	holds          []*types.Hold
+	holdTTL        time.Duration
+	refunds        []*types.Refund
+	disputes       []*types.Dispute
+	disputeRespond time.Duration
+	disputeResolve time.Duration
+	feeRules       []types.FeeRule
+	campaigns      []*types.Campaign
+	rewards        []*types.Reward
+	vouchers       []*types.Voucher
+	redemptions    []*types.VoucherRedemption
+	requests       []*types.PaymentRequest
+	requestTTL     time.Duration
+	transfers      []*types.Transfer
+	jars           []*types.Jar
+	roundUps       []*types.RoundUp
+	budgets        []*types.Budget
+	plans          []*types.InstallmentPlan
+	installments   []*types.Installment
+	lateFee        types.Money
+	lateGrace      time.Duration
+	interestRate   float64
+	interestDay    int64
+}
+
+//SetClock подменяет источник текущего времени, по умолчанию time.Now
+func (s *Service) SetClock(clock func() time.Time) {
+	s.clock = clock
+}
+
+func (s *Service) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
+}
+
+//post изменяет баланс счета и записывает движение в журнал
+func (s *Service) post(account *types.Account, amount types.Money, kind types.PostingKind, paymentID string) {
+	s.postAt(account, amount, kind, paymentID, s.now())
+}
+
+//postAt записывает движение, датированное моментом at
+func (s *Service) postAt(account *types.Account, amount types.Money, kind types.PostingKind, paymentID string, at time.Time) {
+	account.Balance += amount
+	s.postings = append(s.postings, &types.Posting{
+		ID:        uuid.New().String(),
+		AccountID: account.ID,
+		Amount:    amount,
+		Kind:      kind,
+		PaymentID: paymentID,
+		Created:   at.Unix(),
+	})
 }
 
 func (s *Service) RegisterAccount(phone types.Phone) (*types.Account, error) {
@@ -61,38 +132,52 @@ func (s *Service) Deposit(accountID int64, amount types.Money) error {
 	if account == nil {
 		return ErrAccountNotFound
 	}
-	account.Balance += amount
+	s.post(account, amount, types.PostingDeposit, "")
+	s.emit(EventDeposit, account, amount, nil)
 	return nil
 }
 
+//Pay сразу списывает сумму со счета: резервирует ее через Authorize и списывает через Capture.
+//Если на счете есть копилка с округлением, после прохождения платежа в нее переводится разница
+//до следующей целой единицы, при отклонении платежа округление возвращается
 func (s *Service) Pay(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
-	if amount <= 0 {
-		return nil, ErrAmountMustBePositive
-	}
-	var account *types.Account
-	for _, acc := range s.accounts {
-		if acc.ID == accountID {
-			account = acc
-		}
+	hold, err := s.Authorize(accountID, amount, category)
+	if err != nil {
+		return nil, err
 	}
+	return s.Capture(hold.ID, amount)
+}
 
-	if account == nil {
-		return nil, ErrAccountNotFound
-	}
-	if account.Balance < amount {
-		return nil, ErrNotEnoughBalance
-	}
-	account.Balance -= amount
+//debit списывает сумму движением kind и комиссию и создает платеж. Уведомления отправляет вызывающий
+func (s *Service) debit(account *types.Account, amount types.Money, fee types.Money, category types.PaymentCategory, status types.PaymentStatus, kind types.PostingKind) *types.Payment {
 	paymentID := uuid.New().String()
+	s.post(account, -amount, kind, paymentID)
+	if fee > 0 {
+		s.post(account, -fee, types.PostingFee, paymentID)
+	}
 	payment := &types.Payment{
 		ID:        paymentID,
-		AccountID: accountID,
+		AccountID: account.ID,
 		Amount:    amount,
 		Category:  category,
-		Status:    types.PaymentStatusInProgress,
+		Status:    status,
+		Created:   s.now().Unix(),
+		Fee:       fee,
 	}
 	s.payments = append(s.payments, payment)
-	return payment, nil
+	return payment
+}
+
+//accept вызывается, когда платеж проходит: сразу при списании, после подтверждения кодом или после проверки.
+//Перевод зачисляется получателю, по обычному платежу отправляется уведомление и проверяется бюджет
+func (s *Service) accept(account *types.Account, payment *types.Payment) {
+	if s.settleTransfer(account, payment) {
+		return
+	}
+	s.emit(EventPayment, account, payment.Amount, payment)
+	s.checkBudget(account, payment)
+	s.roundUp(payment)
+	s.settleInstallments(payment)
 }
 
 func (s *Service) Reject(paymentID string) error {
@@ -100,15 +185,50 @@ func (s *Service) Reject(paymentID string) error {
 	if err != nil {
 		return err
 	}
+	switch payment.Status {
+	case types.PaymentStatusFail, types.PaymentStatusRefunded, types.PaymentStatusChargeback:
+		return nil
+	}
+	if s.openDispute(payment.ID) != nil {
+		return ErrPaymentDisputed
+	}
+	if payment.Status == types.PaymentStatusOk && s.findTransfer(payment.ID) != nil {
+		return ErrTransferCompleted
+	}
 	account, err := s.FindAccountByID(payment.AccountID)
 	if err != nil {
 		return err
 	}
 	payment.Status = types.PaymentStatusFail
-	account.Balance += payment.Amount
+	delete(s.otps, payment.ID)
+	amount := payment.Amount - payment.Refunded
+	s.post(account, amount, types.PostingReject, payment.ID)
+	s.refundFee(account, payment)
+	s.clawback(account, payment, true)
+	s.releaseVoucher(payment)
+	s.reverseRoundUp(account, payment)
+	s.releaseInstallments(payment)
+	s.emit(EventReject, account, amount, payment)
 	return nil
 }
 
+//Complete завершает платеж со статусом INPROGRESS и начисляет вознаграждения по кампаниям лояльности
+func (s *Service) Complete(paymentID string) error {
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return err
+	}
+	if payment.Status != types.PaymentStatusInProgress {
+		return ErrPaymentNotInProgress
+	}
+	account, err := s.FindAccountByID(payment.AccountID)
+	if err != nil {
+		return err
+	}
+	payment.Status = types.PaymentStatusOk
+	s.award(account, payment)
+	return nil
+}
 func (s *Service) Repeat(paymentID string) (*types.Payment, error) {
 	p, err := s.FindPaymentByID(paymentID)
 	if err != nil {
@@ -159,6 +279,15 @@ func (s *Service) FindAccountByID(accountID int64) (*types.Account, error) {
 	return nil, ErrAccountNotFound
 }
 
+func (s *Service) FindAccountByPhone(phone types.Phone) (*types.Account, error) {
+	for _, acc := range s.accounts {
+		if acc.Phone == phone {
+			return acc, nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
 func (s *Service) FindPaymentByID(paymentID string) (*types.Payment, error) {
 	for _, py := range s.payments {
 		if py.ID == paymentID {
@@ -177,6 +306,30 @@ func (s *Service) FindFavoriteByID(favoriteID string) (*types.Favorite, error) {
 	return nil, ErrFavoriteNotFound
 }
 
+func (s *Service) FindFavoriteByName(accountID int64, name string) (*types.Favorite, error) {
+	for _, fw := range s.favorites {
+		if fw.AccountID == accountID && fw.Name == name {
+			return fw, nil
+		}
+	}
+	return nil, ErrFavoriteNotFound
+}
+
+//AccountPostings возвращает движения по счету в порядке их совершения
+func (s *Service) AccountPostings(accountID int64) ([]types.Posting, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	var postings []types.Posting
+	for _, posting := range s.postings {
+		if posting.AccountID == account.ID {
+			postings = append(postings, *posting)
+		}
+	}
+	return postings, nil
+}
+
 func (s *Service) ExportToFile(path string) error {
 	file, err := os.Create(path)
 	if err != nil {
@@ -217,7 +370,46 @@ func (s *Service) ImportFromFile(path string) error {
 	return nil
 }
 
+//dumpSection описывает один файл выгрузки: строки для Export и разбор строки для Import
+type dumpSection struct {
+	name  string
+	lines func() []string
+	parse func(fields []string)
+}
+
+func (s *Service) sections() []dumpSection {
+	return []dumpSection{
+		{name: "accounts", lines: s.accountLines, parse: s.importAccount},
+		{name: "payments", lines: s.paymentLines, parse: s.importPayment},
+		{name: "otps", lines: s.otpLines, parse: s.importOTP},
+		{name: "favorites", lines: s.favoriteLines, parse: s.importFavorite},
+		{name: "postings", lines: s.postingLines, parse: s.importPosting},
+		{name: "schedules", lines: s.scheduleLines, parse: s.importSchedule},
+		{name: "holds", lines: s.holdLines, parse: s.importHold},
+		{name: "refunds", lines: s.refundLines, parse: s.importRefund},
+		{name: "disputes", lines: s.disputeLines, parse: s.importDispute},
+		{name: "campaigns", lines: s.campaignLines, parse: s.importCampaign},
+		{name: "rewards", lines: s.rewardLines, parse: s.importReward},
+		{name: "vouchers", lines: s.voucherLines, parse: s.importVoucher},
+		{name: "redemptions", lines: s.redemptionLines, parse: s.importRedemption},
+		{name: "requests", lines: s.requestLines, parse: s.importRequest},
+		{name: "transfers", lines: s.transferLines, parse: s.importTransfer},
+		{name: "jars", lines: s.jarLines, parse: s.importJar},
+		{name: "roundups", lines: s.roundUpLines, parse: s.importRoundUp},
+		{name: "budgets", lines: s.budgetLines, parse: s.importBudget},
+		{name: "plans", lines: s.planLines, parse: s.importPlan},
+		{name: "installments", lines: s.installmentLines, parse: s.importInstallment},
+		{name: "interest", lines: s.interestLines, parse: s.importInterest},
+	}
+}
+
 func (s *Service) Export(dir string) error {
+	return lastErr(s.ExportWithProgress(context.Background(), dir))
+}
+
+//ExportWithProgress выгружает данные в dir, отправляя прогресс после каждого файла.
+//Канал нужно читать до закрытия, ошибка передается в поле Err последнего сообщения
+func (s *Service) ExportWithProgress(ctx context.Context, dir string) <-chan types.Progress {
 	save := func(data string, name string) error {
 		_ = os.Mkdir(dir, 0777)
 		f, err := os.Create(dir + "/" + name + ".dump")
@@ -232,42 +424,36 @@ func (s *Service) Export(dir string) error {
 		return nil
 	}
 
-	if len(s.accounts) > 0 {
-		data := strings.Builder{}
-		for _, account := range s.accounts {
-			data.WriteString(account.ToString() + "\n")
-		}
-		err := save(data.String(), "accounts")
-		if err != nil {
-			return err
-		}
-	}
-
-	if len(s.favorites) > 0 {
-		data := strings.Builder{}
-		for _, favorite := range s.favorites {
-			data.WriteString(favorite.ToString() + "\n")
-		}
-		err := save(data.String(), "favorites")
-		if err != nil {
-			return err
-		}
-	}
-
-	if len(s.payments) > 0 {
-		data := strings.Builder{}
-		for _, payment := range s.payments {
-			data.WriteString(payment.ToString() + "\n")
-		}
-		err := save(data.String(), "payments")
-		if err != nil {
-			return err
+	sections := s.sections()
+	ch := make(chan types.Progress)
+	go func() {
+		defer close(ch)
+		for i, section := range sections {
+			if err := ctx.Err(); err != nil {
+				ch <- sectionProgress(i, len(sections), 0, err)
+				return
+			}
+			lines := section.lines()
+			if len(lines) > 0 {
+				err := save(strings.Join(lines, "\n")+"\n", section.name)
+				if err != nil {
+					ch <- sectionProgress(i, len(sections), 0, err)
+					return
+				}
+			}
+			ch <- sectionProgress(i+1, len(sections), len(lines), nil)
 		}
-	}
-	return nil
+	}()
+	return ch
 }
 
 func (s *Service) Import(dir string) error {
+	return lastErr(s.ImportWithProgress(context.Background(), dir))
+}
+
+//ImportWithProgress загружает данные из dir, отправляя прогресс после каждого файла.
+//Отсутствующие файлы пропускаются. Канал нужно читать до закрытия
+func (s *Service) ImportWithProgress(ctx context.Context, dir string) <-chan types.Progress {
 	read := func(name string) string {
 		data, err := ioutil.ReadFile(dir + "/" + name + ".dump")
 		if err != nil {
@@ -276,87 +462,223 @@ func (s *Service) Import(dir string) error {
 		return string(data)
 	}
 
-	data := read("accounts")
-	arr := strings.Split(data, "\n")
-	for _, ac := range arr {
-		accountStr := strings.Split(ac, ";")
-		if len(accountStr) < 2 {
-			continue
-		}
-		ID, _ := strconv.Atoi(accountStr[0])
-		Phone := types.Phone(accountStr[1])
-		Balance, _ := strconv.Atoi(accountStr[2])
-		fw, err := s.FindAccountByID(int64(ID))
-		if err != nil {
-			fw = &types.Account{
-				ID:      int64(ID),
-				Phone:   Phone,
-				Balance: types.Money(Balance),
+	sections := s.sections()
+	ch := make(chan types.Progress)
+	go func() {
+		defer close(ch)
+		for i, section := range sections {
+			if err := ctx.Err(); err != nil {
+				ch <- sectionProgress(i, len(sections), 0, err)
+				return
 			}
-			s.accounts = append(s.accounts, fw)
-			s.nextAccountID = int64(ID)
+			count := 0
+			for _, line := range strings.Split(read(section.name), "\n") {
+				fields := strings.Split(line, ";")
+				if len(fields) < 2 {
+					continue
+				}
+				section.parse(fields)
+				count++
+			}
+			ch <- sectionProgress(i+1, len(sections), count, nil)
 		}
-		fw.Phone = Phone
-		fw.Balance = types.Money(Balance)
+	}()
+	return ch
+}
+
+//dumpText заменяет в произвольном тексте разделители записей и полей дампа
+func dumpText(text string) string {
+	return strings.NewReplacer(";", ",", "\r", " ", "\n", " ").Replace(text)
+}
+
+func sectionProgress(done int, parts int, records int, err error) types.Progress {
+	return types.Progress{
+		Part:    records,
+		Done:    done,
+		Parts:   parts,
+		Percent: done * 100 / parts,
+		Err:     err,
 	}
+}
 
-	data = read("payments")
-	payments := strings.Split(data, "\n")
-	for _, ac := range payments {
-		paymentStr := strings.Split(ac, ";")
-		if len(paymentStr) < 2 {
-			continue
-		}
-		ID := paymentStr[0]
-		AccountID, _ := strconv.Atoi(paymentStr[1])
-		Amount, _ := strconv.Atoi(paymentStr[2])
-		Category := paymentStr[3]
-		Status := paymentStr[4]
-		py, err := s.FindPaymentByID(ID)
-		if err == nil {
-			py.AccountID = int64(AccountID)
-			py.Amount = types.Money(Amount)
-			py.Category = types.PaymentCategory(Category)
-			py.Status = types.PaymentStatus(Status)
-			continue
+//lastErr дочитывает канал прогресса и возвращает ошибку из него
+func lastErr(ch <-chan types.Progress) error {
+	var err error
+	for progress := range ch {
+		if progress.Err != nil {
+			err = progress.Err
 		}
-		s.payments = append(s.payments, &types.Payment{
-			ID:        ID,
-			AccountID: int64(AccountID),
-			Amount:    types.Money(Amount),
-			Category:  types.PaymentCategory(Category),
-			Status:    types.PaymentStatus(Status),
-		})
 	}
+	return err
+}
 
-	data = read("favorites")
-	favorites := strings.Split(data, "\n")
-	for _, ac := range favorites {
-		favoriteStr := strings.Split(ac, ";")
-		if len(favoriteStr) < 2 {
-			continue
-		}
-		ID := favoriteStr[0]
-		AccountID, _ := strconv.Atoi(favoriteStr[1])
-		Name := favoriteStr[2]
-		Amount, _ := strconv.Atoi(favoriteStr[3])
-		Category := favoriteStr[4]
-		fw, err := s.FindFavoriteByID(ID)
+func (s *Service) accountLines() []string {
+	var lines []string
+	for _, account := range s.accounts {
+		lines = append(lines, account.ToString())
+	}
+	return lines
+}
+
+func (s *Service) paymentLines() []string {
+	var lines []string
+	for _, payment := range s.payments {
+		lines = append(lines, payment.ToString())
+	}
+	return lines
+}
+
+func (s *Service) favoriteLines() []string {
+	var lines []string
+	for _, favorite := range s.favorites {
+		lines = append(lines, favorite.ToString())
+	}
+	return lines
+}
+
+func (s *Service) postingLines() []string {
+	var lines []string
+	for _, posting := range s.postings {
+		lines = append(lines, posting.ToString())
+	}
+	return lines
+}
+
+func (s *Service) importAccount(accountStr []string) {
+	if len(accountStr) < 3 {
+		return
+	}
+	ID, _ := strconv.Atoi(accountStr[0])
+	Phone := types.Phone(accountStr[1])
+	Balance, _ := strconv.Atoi(accountStr[2])
+	var Type types.AccountType
+	if len(accountStr) > 3 {
+		Type = types.AccountType(accountStr[3])
+	}
+	var Points int64
+	if len(accountStr) > 4 {
+		Points, _ = strconv.ParseInt(accountStr[4], 10, 64)
+	}
+	var CreditLimit int64
+	if len(accountStr) > 5 {
+		CreditLimit, _ = strconv.ParseInt(accountStr[5], 10, 64)
+	}
+	fw, err := s.FindAccountByID(int64(ID))
+	if err != nil {
+		fw = &types.Account{
+			ID:      int64(ID),
+			Phone:   Phone,
+			Balance: types.Money(Balance),
+		}
+		s.accounts = append(s.accounts, fw)
+		s.nextAccountID = int64(ID)
+	}
+	fw.Phone = Phone
+	fw.Balance = types.Money(Balance)
+	fw.Type = Type
+	fw.Points = Points
+	fw.CreditLimit = types.Money(CreditLimit)
+}
+
+func (s *Service) importPayment(paymentStr []string) {
+	if len(paymentStr) < 5 {
+		return
+	}
+	ID := paymentStr[0]
+	AccountID, _ := strconv.Atoi(paymentStr[1])
+	Amount, _ := strconv.Atoi(paymentStr[2])
+	Category := paymentStr[3]
+	Status := paymentStr[4]
+	var Created int64
+	if len(paymentStr) > 5 {
+		Created, _ = strconv.ParseInt(paymentStr[5], 10, 64)
+	}
+	var Reason string
+	if len(paymentStr) > 6 {
+		Reason = paymentStr[6]
+	}
+	py, err := s.FindPaymentByID(ID)
+	if err == nil {
+		py.AccountID = int64(AccountID)
+		py.Amount = types.Money(Amount)
+		py.Category = types.PaymentCategory(Category)
+		py.Status = types.PaymentStatus(Status)
+		py.Created = Created
+		py.Reason = Reason
+		return
+	}
+	s.payments = append(s.payments, &types.Payment{
+		ID:        ID,
+		AccountID: int64(AccountID),
+		Amount:    types.Money(Amount),
+		Category:  types.PaymentCategory(Category),
+		Status:    types.PaymentStatus(Status),
+		Created:   Created,
+		Reason:    Reason,
+	})
+}
+
+func (s *Service) importFavorite(favoriteStr []string) {
+	if len(favoriteStr) < 5 {
+		return
+	}
+	ID := favoriteStr[0]
+	AccountID, _ := strconv.Atoi(favoriteStr[1])
+	Name := favoriteStr[2]
+	Amount, _ := strconv.Atoi(favoriteStr[3])
+	Category := favoriteStr[4]
+	fw, err := s.FindFavoriteByID(ID)
+	if err == nil {
+		fw.AccountID = int64(AccountID)
+		fw.Amount = types.Money(Amount)
+		fw.Name = Name
+		fw.Category = types.PaymentCategory(Category)
+		return
+	}
+	favorite := &types.Favorite{
+		ID:        ID,
+		AccountID: int64(AccountID),
+		Amount:    types.Money(Amount),
+		Name:      Name,
+		Category:  types.PaymentCategory(Category),
+	}
+	s.favorites = append(s.favorites, favorite)
+}
+
+func (s *Service) importPosting(postingStr []string) {
+	if len(postingStr) < 6 {
+		return
+	}
+	ID := postingStr[0]
+	if s.findPostingByID(ID) != nil {
+		return
+	}
+	AccountID, _ := strconv.Atoi(postingStr[1])
+	Amount, _ := strconv.Atoi(postingStr[2])
+	Created, _ := strconv.ParseInt(postingStr[5], 10, 64)
+	posting := &types.Posting{
+		ID:        ID,
+		AccountID: int64(AccountID),
+		Amount:    types.Money(Amount),
+		Kind:      types.PostingKind(postingStr[3]),
+		PaymentID: postingStr[4],
+		Created:   Created,
+	}
+	s.postings = append(s.postings, posting)
+	//комиссия платежа не хранится в его записи и собирается из движений
+	if posting.Kind == types.PostingFee || posting.Kind == types.PostingFeeRefund {
+		payment, err := s.FindPaymentByID(posting.PaymentID)
 		if err == nil {
-			fw.AccountID = int64(AccountID)
-			fw.Amount = types.Money(Amount)
-			fw.Name = Name
-			fw.Category = types.PaymentCategory(Category)
-			continue
+			payment.Fee -= posting.Amount
 		}
-		favorite := &types.Favorite{
-			ID:        uuid.New().String(),
-			AccountID: int64(AccountID),
-			Amount:    types.Money(Amount),
-			Name:      Name,
-			Category:  types.PaymentCategory(Category),
+	}
+}
+
+func (s *Service) findPostingByID(postingID string) *types.Posting {
+	for _, posting := range s.postings {
+		if posting.ID == postingID {
+			return posting
 		}
-		s.favorites = append(s.favorites, favorite)
 	}
 	return nil
 }
@@ -378,6 +700,10 @@ func (s *Service) ExportAccountHistory(accountID int64) ([]types.Payment, error)
 				Amount:    v.Amount,
 				Category:  v.Category,
 				Status:    v.Status,
+				Created:   v.Created,
+				Reason:    v.Reason,
+				Refunded:  v.Refunded,
+				Fee:       v.Fee,
 			}
 			payments = append(payments, data)
 		}
@@ -425,302 +751,96 @@ func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records i
 }
 
 func (s *Service) SumPayments(goroutines int) types.Money {
-
-	wg := sync.WaitGroup{}
-	mu := sync.Mutex{}
-	len1 := len(s.payments) / goroutines
-	len2 := len(s.payments) % goroutines
-	sum := types.Money(0)
-	if goroutines > 1 {
-		currentPosition := int(0)
-		for i := 0; i < goroutines; i++ {
-			wg.Add(1)
-			lastPosition := currentPosition
-
-			go func(lastPosition int) {
-				defer wg.Done()
-				res := types.Money(0)
-				curPaymArray := s.payments[lastPosition : lastPosition+len1]
-				for _, currentPayment := range curPaymArray {
-					if currentPayment == nil {
-						continue
-					}
-					res += currentPayment.Amount
-				}
-				mu.Lock()
-				mu.Unlock()
-				sum += res
-			}(lastPosition)
-
-			currentPosition += len1
-
-		}
-	} else {
-		for _, currentPayment := range s.payments {
-			sum += currentPayment.Amount
-		}
-	}
-	if len2 != 0 {
-		for _, curPayment := range s.payments[(len(s.payments) - len2):len(s.payments)] {
-			sum += curPayment.Amount
-		}
-	}
-
-	wg.Wait()
-	return sum
-
+	sum, _ := s.MapReduce(context.Background(), ScanOptions{Workers: goroutines}, sumChunk, addMoney, types.Money(0))
+	return sum.(types.Money)
 }
 
 func (s *Service) FilterPayments(accountID int64, goroutines int) (resPayments []types.Payment, err error) {
-	wg := sync.WaitGroup{}
-	mu := sync.Mutex{}
-	len1 := len(s.payments) / goroutines
-	len2 := len(s.payments) % goroutines
-
-	if goroutines < 2 {
-		for _, res := range s.payments {
-			if res.AccountID == accountID {
-				resPayments = append(resPayments, *res)
-			}
-			if res == nil {
-				return nil, ErrAccountNotFound
-			}
-		}
-		return
-	}
-
-	currentPosition := int(0)
-	for i := 0; i < goroutines; i++ {
-		wg.Add(1)
-		lastPosition := currentPosition
-		go func(lastPosition int) {
-			defer wg.Done()
-			res := []types.Payment{}
-			curPaymArray := s.payments[lastPosition : lastPosition+len1]
-
-			for _, currentPayment := range curPaymArray {
-				if currentPayment == nil {
-					continue
-				}
-				if currentPayment.AccountID == accountID {
-					res = append(res, *currentPayment)
-				}
-			}
-			mu.Lock()
-			resPayments = append(resPayments, res...)
-			mu.Unlock()
-		}(lastPosition)
-
-		currentPosition += len1
-	}
-
-	if len2 != 0 {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			res := []types.Payment{}
-			for _, currentPayment := range s.payments[(len(s.payments) - len2):len(s.payments)] {
-				if currentPayment == nil {
-					continue
-				}
-				if currentPayment.AccountID == accountID {
-					res = append(res, *currentPayment)
-				}
-			}
-
-			mu.Lock()
-			resPayments = append(resPayments, res...)
-			mu.Unlock()
-
-		}()
-	}
-
-	wg.Wait()
-	if resPayments == nil {
-		return nil, ErrAccountNotFound
-	}
-	return
+	return s.FilterPaymentsByFn(func(payment types.Payment) bool {
+		return payment.AccountID == accountID
+	}, goroutines)
 }
 
+//FilterPaymentsByFn возвращает платежи, подходящие под filter, в порядке создания. Если ничего не найдено,
+//при goroutines < 2 возвращается nil без ошибки, иначе ErrAccountNotFound
 func (s *Service) FilterPaymentsByFn(filter func(payment types.Payment) bool, goroutines int) (resPayments []types.Payment, err error) {
-	wg := sync.WaitGroup{}
-	mu := sync.Mutex{}
-	len1 := len(s.payments) / goroutines
-	len2 := len(s.payments) % goroutines
-
-	if goroutines < 2 {
-		for _, res := range s.payments {
-			if filter(*res) {
-				resPayments = append(resPayments, *res)
-			}
-			if res == nil {
-				return nil, ErrAccountNotFound
-			}
-		}
-		return
-	}
-
-	currentPosition := int(0)
-	for i := 0; i < goroutines; i++ {
-		wg.Add(1)
-		lastPosition := currentPosition
-		go func(lastPosition int) {
-			defer wg.Done()
-			res := []types.Payment{}
-			curPaymArray := s.payments[lastPosition : lastPosition+len1]
-
-			for _, currentPayment := range curPaymArray {
-				if currentPayment == nil {
-					continue
-				}
-				if filter(*currentPayment) {
-					res = append(res, *currentPayment)
-				}
-			}
-			mu.Lock()
-			resPayments = append(resPayments, res...)
-			mu.Unlock()
-		}(lastPosition)
-
-		currentPosition += len1
-	}
-
-	if len2 != 0 {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			res := []types.Payment{}
-			for _, currentPayment := range s.payments[(len(s.payments) - len2):len(s.payments)] {
-				if currentPayment == nil {
-					continue
-				}
-				if filter(*currentPayment) {
-					res = append(res, *currentPayment)
-				}
-			}
-
-			mu.Lock()
-			resPayments = append(resPayments, res...)
-			mu.Unlock()
-
-		}()
+	res, err := s.MapReduce(context.Background(), ScanOptions{Workers: goroutines}, filterChunk(filter), appendPayments, []types.Payment(nil))
+	if err != nil {
+		return nil, err
 	}
-
-	wg.Wait()
-	if resPayments == nil {
+	resPayments = res.([]types.Payment)
+	if resPayments == nil && goroutines >= 2 {
 		return nil, ErrAccountNotFound
 	}
 	return
 }
 
-//func (s *Service) SumPaymentsWithProgress() <-chan types.Progress {
-//	paymentCurrentSize := 100_000 //piece's size
-//	goroutinesNumber := len(s.payments) / paymentCurrentSize
-//
-//	wg := sync.WaitGroup{}
-//	i := 0
-//	if goroutinesNumber <= 1 {
-//		goroutinesNumber = 1
-//	}
-//	//creating channel for writing
-//	channels := make(chan types.Progress)
-//
-//	// filled channels
-//	for i := 0; i < goroutinesNumber; i++ {
-//		wg.Add(1)
-//		var payments []*types.Payment
-//		if len(s.payments) < paymentCurrentSize {
-//			payments = s.payments
-//		} else {
-//			payments = s.payments[i*paymentCurrentSize : (i+1)*paymentCurrentSize]
-//		}
-//		go func(channels chan types.Progress, data []*types.Payment) {
-//			defer wg.Done()
-//			sumAmount := types.Money(0)
-//			for _, curpayment := range data {
-//				sumAmount += curpayment.Amount
-//			}
-//			if len(s.payments) < paymentCurrentSize {
-//				channels <- types.Progress{
-//					Part:   len(data),
-//					Result: sumAmount,
-//				}
-//			}
-//		}(channels, payments)
-//	}
-//
-//	if len(s.payments) > paymentCurrentSize {
-//		wg.Add(1)
-//		var paymentArray []*types.Payment
-//		paymentArray = s.payments[i*paymentCurrentSize]
-//		go func(ch chan types.Progress, data []*types.Payment) {
-//			defer wg.Done()
-//			res := types.Money(0)
-//			for _, curpayment := range data {
-//				res += curpayment.Amount
-//			}
-//			ch <- types.Progress{
-//				Part:   len(data),
-//				Result: res,
-//			}
-//		}(channels, paymentArray)
-//	}
-//
-//	go func() {
-//		defer close(channels)
-//		wg.Wait()
-//	}()
-//	return channels
-//
-//}
-//func merge(channels []<-chan types.Progress) <-chan types.Progress {
-//	wg := sync.WaitGroup{}
-//	wg.Add(len(channels))
-//	merged := make(chan types.Progress)
-//	for _, ch := range channels {
-//		go func(ch <-chan types.Progress) {
-//			defer wg.Done()
-//			for val := range ch {
-//				merged <- val
-//			}
-//		}(ch)
-//	}
-//	go func() {
-//		defer close(merged)
-//		wg.Wait()
-//	}()
-//	return merged
-//}
-
 func (s *Service) SumPaymentsWithProgress() <-chan types.Progress {
-	gorotunCountSize := 100_0000
+	return s.SumPaymentsWithProgressContext(context.Background(), ScanOptions{
+		Workers:   runtime.NumCPU(),
+		ChunkSize: 100_000,
+	})
+}
 
-	wg := sync.WaitGroup{}
-	goroutines := len(s.payments) / gorotunCountSize
-	if goroutines <= 1 {
-		goroutines = 1
+//SumPaymentsWithProgressContext суммирует платежи кусками по opts.ChunkSize и после каждого куска
+//отправляет его сумму, нарастающий итог и процент выполнения. Канал нужно читать до закрытия
+func (s *Service) SumPaymentsWithProgressContext(ctx context.Context, opts ScanOptions) <-chan types.Progress {
+	return s.withProgress(ctx, opts, func(payments []*types.Payment) types.Progress {
+		return types.Progress{
+			Part:   len(payments),
+			Result: sumChunk(payments).(types.Money),
+		}
+	})
+}
 
-	}
+//FilterPaymentsByFnWithProgress фильтрует платежи кусками, отправляя найденные в каждом куске платежи в поле Payments
+func (s *Service) FilterPaymentsByFnWithProgress(ctx context.Context, filter func(payment types.Payment) bool, opts ScanOptions) <-chan types.Progress {
+	mapFn := filterChunk(filter)
+	return s.withProgress(ctx, opts, func(payments []*types.Payment) types.Progress {
+		found := mapFn(payments).([]types.Payment)
+		return types.Progress{
+			Part:     len(payments),
+			Payments: found,
+		}
+	})
+}
+
+//withProgress обходит платежи через scan и дополняет результат fn по каждому куску счетчиками прогресса
+func (s *Service) withProgress(ctx context.Context, opts ScanOptions, fn func(payments []*types.Payment) types.Progress) <-chan types.Progress {
+	payments := s.payments
+	parts := len(opts.chunks(len(payments)))
 	ch := make(chan types.Progress)
 
-	for i := 0; i < goroutines; i++ {
-		wg.Add(1)
-		go func(ch chan<- types.Progress, payments []*types.Payment) {
-			var sum types.Money = 0
-			defer wg.Done()
-			for _, pay := range payments {
-				sum += pay.Amount
+	go func() {
+		defer close(ch)
+		mu := sync.Mutex{}
+		done := 0
+		total := types.Money(0)
+		err := scan(ctx, payments, opts, func(part int, chunk []*types.Payment) {
+			progress := fn(chunk)
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			total += progress.Result
+			progress.Done = done
+			progress.Parts = parts
+			progress.Total = total
+			progress.Percent = done * 100 / parts
+			ch <- progress
+		})
+		if err != nil || parts == 0 {
+			percent := 100
+			if parts > 0 {
+				percent = done * 100 / parts
 			}
 			ch <- types.Progress{
-				Part:   len(payments),
-				Result: sum,
+				Done:    done,
+				Parts:   parts,
+				Total:   total,
+				Percent: percent,
+				Err:     err,
 			}
-		}(ch, s.payments)
-	}
-
-	go func() {
-		defer close(ch)
-		wg.Wait()
+		}
 	}()
 
 	return ch
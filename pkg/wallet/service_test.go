@@ -1,12 +1,14 @@
 package wallet
 
 import (
+	"context"
 	"fmt"
 	"github.com/SonnLarissa/wallet/pkg/types"
 	"github.com/google/uuid"
 	"log"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type testService struct {
@@ -29,6 +31,21 @@ func (s *testService) addAccountWithBalance(phone types.Phone, balance types.Mon
 	return account, nil
 }
 
+//testNow момент, на котором останавливаются часы в тестах с подменой времени
+var testNow = time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+//newClockedTestService создает сервис с часами, остановленными на now, и счетом +992928885522 с балансом balance.
+//Часы переводятся через возвращенный указатель
+func newClockedTestService(t *testing.T, now time.Time, balance types.Money) (*testService, *types.Account, *time.Time) {
+	s := newTestService()
+	s.SetClock(func() time.Time { return now })
+	account, err := s.addAccountWithBalance("+992928885522", balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, account, &now
+}
+
 type testAccount struct {
 	phone    types.Phone
 	balance  types.Money
@@ -517,3 +534,163 @@ func BenchmarkService_FilterPaymentsByFn(b *testing.B) {
 	}
 	log.Println(len(account))
 }
+
+func TestService_AccountPostings_success(t *testing.T) {
+	s := newTestService()
+	account, payments, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = s.Reject(payments[0].ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	postings, err := s.AccountPostings(account.ID)
+	if err != nil {
+		t.Errorf("AccountPostings(): error = %v", err)
+		return
+	}
+	kinds := []types.PostingKind{types.PostingDeposit, types.PostingPayment, types.PostingReject}
+	if len(postings) != len(kinds) {
+		t.Errorf("AccountPostings(): wrong postings returned = %v", postings)
+		return
+	}
+	sum := types.Money(0)
+	for i, posting := range postings {
+		if posting.Kind != kinds[i] {
+			t.Errorf("AccountPostings(): posting %d kind = %v, want %v", i, posting.Kind, kinds[i])
+		}
+		sum += posting.Amount
+	}
+	if sum != account.Balance {
+		t.Errorf("AccountPostings(): postings sum = %v, balance = %v", sum, account.Balance)
+	}
+}
+
+func TestService_Import_postings(t *testing.T) {
+	s := newTestService()
+	_, payments, err := s.addAccount(defaultTestAccount)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dir := t.TempDir()
+	err = s.Export(dir)
+	if err != nil {
+		t.Errorf("Export(): error = %v", err)
+		return
+	}
+	srv := newTestService()
+	err = srv.Import(dir)
+	if err != nil {
+		t.Errorf("Import(): error = %v", err)
+		return
+	}
+	if !reflect.DeepEqual(s.postings, srv.postings) {
+		t.Errorf("Import(): postings = %v, want %v", srv.postings, s.postings)
+	}
+	payment, err := srv.FindPaymentByID(payments[0].ID)
+	if err != nil || payment.Created != payments[0].Created {
+		t.Errorf("Import(): wrong payment imported = %v, error = %v", payment, err)
+	}
+}
+
+func TestService_SumPaymentsWithProgressContext(t *testing.T) {
+	s := newScanTestService(1001)
+	want := types.Money(1001 * 1002 / 2)
+	ch := s.SumPaymentsWithProgressContext(context.Background(), ScanOptions{Workers: 3, ChunkSize: 100})
+	count := 0
+	sum := types.Money(0)
+	var last types.Progress
+	for progress := range ch {
+		count++
+		sum += progress.Result
+		if progress.Done != count || progress.Total != sum {
+			t.Errorf("SumPaymentsWithProgressContext(): wrong running progress = %+v", progress)
+		}
+		last = progress
+	}
+	if count != 11 || last.Parts != 11 || last.Percent != 100 || last.Total != want || last.Err != nil {
+		t.Errorf("SumPaymentsWithProgressContext(): wrong last progress = %+v after %d messages", last, count)
+	}
+}
+
+func TestService_SumPaymentsWithProgress_empty(t *testing.T) {
+	s := newTestService()
+	var last types.Progress
+	for progress := range s.SumPaymentsWithProgress() {
+		last = progress
+	}
+	if last.Percent != 100 || last.Total != 0 {
+		t.Errorf("SumPaymentsWithProgress(): wrong last progress = %+v", last)
+	}
+}
+
+func TestService_SumPaymentsWithProgressContext_cancel(t *testing.T) {
+	s := newScanTestService(100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var last types.Progress
+	for progress := range s.SumPaymentsWithProgressContext(ctx, ScanOptions{Workers: 1, ChunkSize: 10}) {
+		cancel()
+		last = progress
+	}
+	if last.Err != context.Canceled || last.Done == last.Parts {
+		t.Errorf("SumPaymentsWithProgressContext(): wrong last progress after cancel = %+v", last)
+	}
+}
+
+func TestService_FilterPaymentsByFnWithProgress(t *testing.T) {
+	s := newScanTestService(1001)
+	filter := func(payment types.Payment) bool {
+		return payment.AccountID == 2
+	}
+	want, _ := s.FilterPaymentsByFn(filter, 1)
+	var got []types.Payment
+	for progress := range s.FilterPaymentsByFnWithProgress(context.Background(), filter, ScanOptions{Workers: 4, ChunkSize: 50}) {
+		got = append(got, progress.Payments...)
+	}
+	if len(got) != len(want) {
+		t.Errorf("FilterPaymentsByFnWithProgress(): found %d payments, want %d", len(got), len(want))
+	}
+}
+
+func TestService_ExportImportWithProgress(t *testing.T) {
+	s := newScanTestService(10)
+	dir := t.TempDir()
+	var last types.Progress
+	for progress := range s.ExportWithProgress(context.Background(), dir) {
+		last = progress
+	}
+	if last.Err != nil || last.Percent != 100 {
+		t.Errorf("ExportWithProgress(): wrong last progress = %+v", last)
+		return
+	}
+	srv := newTestService()
+	records := 0
+	for progress := range srv.ImportWithProgress(context.Background(), dir) {
+		records += progress.Part
+		last = progress
+	}
+	if last.Err != nil || last.Percent != 100 {
+		t.Errorf("ImportWithProgress(): wrong last progress = %+v", last)
+	}
+	if records != len(s.accounts)+len(s.payments)+len(s.postings)+len(s.holds) {
+		t.Errorf("ImportWithProgress(): imported %d records", records)
+	}
+	if srv.SumPayments(2) != s.SumPayments(2) {
+		t.Errorf("ImportWithProgress(): payments sum = %v, want %v", srv.SumPayments(2), s.SumPayments(2))
+	}
+}
+
+func TestService_ExportWithProgress_cancel(t *testing.T) {
+	s := newScanTestService(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := lastErr(s.ExportWithProgress(ctx, t.TempDir()))
+	if err != context.Canceled {
+		t.Errorf("ExportWithProgress(): must return context.Canceled, returned = %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+var (
+	ErrInvalidVoucher         = errors.New("invalid voucher")
+	ErrVoucherExists          = errors.New("voucher already exists")
+	ErrVoucherNotFound        = errors.New("voucher not found")
+	ErrVoucherExpired         = errors.New("voucher expired")
+	ErrVoucherUsedUp          = errors.New("voucher used up")
+	ErrVoucherAlreadyRedeemed = errors.New("voucher already redeemed by account")
+	ErrVoucherNotApplicable   = errors.New("voucher is not applicable")
+)
+
+const voucherAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+//GenerateVoucher создает ваучер. Если код не задан, он генерируется случайно. Коды не зависят от регистра.
+//Процентная скидка должна быть меньше 100, иначе платеж по ваучеру был бы нулевым
+func (s *Service) GenerateVoucher(voucher types.Voucher) (*types.Voucher, error) {
+	switch voucher.Kind {
+	case types.VoucherCredit:
+		if voucher.Value <= 0 || voucher.Percent != 0 {
+			return nil, ErrInvalidVoucher
+		}
+	case types.VoucherDiscount:
+		if voucher.Value < 0 || voucher.Percent < 0 || voucher.Percent >= 100 || (voucher.Value > 0) == (voucher.Percent > 0) {
+			return nil, ErrInvalidVoucher
+		}
+	default:
+		return nil, ErrInvalidVoucher
+	}
+	if voucher.MaxUses < 0 || strings.ContainsAny(voucher.Code, ";,\r\n ") {
+		return nil, ErrInvalidVoucher
+	}
+	for _, category := range voucher.Categories {
+		if strings.ContainsAny(string(category), ";,") {
+			return nil, ErrInvalidVoucher
+		}
+	}
+
+	if voucher.Code == "" {
+		code, err := generateVoucherCode()
+		if err != nil {
+			return nil, err
+		}
+		voucher.Code = code
+	}
+	voucher.Code = strings.ToUpper(voucher.Code)
+	if _, err := s.FindVoucherByCode(voucher.Code); err == nil {
+		return nil, ErrVoucherExists
+	}
+	voucher.Uses = 0
+	voucher.Categories = append([]types.PaymentCategory(nil), voucher.Categories...)
+	voucher.Created = s.now().Unix()
+	s.vouchers = append(s.vouchers, &voucher)
+	return &voucher, nil
+}
+
+func (s *Service) FindVoucherByCode(code string) (*types.Voucher, error) {
+	code = strings.ToUpper(code)
+	for _, voucher := range s.vouchers {
+		if voucher.Code == code {
+			return voucher, nil
+		}
+	}
+	return nil, ErrVoucherNotFound
+}
+
+//RedeemVoucher зачисляет ваучер CREDIT на счет так же, как Deposit, и возвращает зачисленную сумму
+func (s *Service) RedeemVoucher(accountID int64, code string) (types.Money, error) {
+	voucher, err := s.usableVoucher(accountID, code, types.VoucherCredit)
+	if err != nil {
+		return 0, err
+	}
+	err = s.Deposit(accountID, voucher.Value)
+	if err != nil {
+		return 0, err
+	}
+	s.redeem(voucher, accountID, "", voucher.Value)
+	return voucher.Value, nil
+}
+
+//PayWithVoucher оплачивает сумму за вычетом скидки по ваучеру DISCOUNT. Ваучер считается использованным,
+//только если платеж создан без ошибки, и возвращается, если платеж отклонен (в том числе по неверному
+//или просроченному коду подтверждения или после проверки). Скидка не больше суммы без одной минимальной единицы,
+//поэтому ваучер на сумму больше платежа тоже можно использовать
+func (s *Service) PayWithVoucher(accountID int64, amount types.Money, category types.PaymentCategory, code string) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+	voucher, err := s.usableVoucher(accountID, code, types.VoucherDiscount)
+	if err != nil {
+		return nil, err
+	}
+	if len(voucher.Categories) > 0 && !containsCategory(voucher.Categories, category) {
+		return nil, ErrVoucherNotApplicable
+	}
+	discount := voucher.Value
+	if voucher.Percent > 0 {
+		discount = types.Money(math.Round(float64(amount) * voucher.Percent / 100))
+	}
+	if discount > amount-1 {
+		discount = amount - 1
+	}
+	payment, err := s.Pay(accountID, amount-discount, category)
+	if err != nil {
+		return payment, err
+	}
+	s.redeem(voucher, accountID, payment.ID, discount)
+	return payment, nil
+}
+
+//AccountRedemptions возвращает использованные счетом ваучеры
+func (s *Service) AccountRedemptions(accountID int64) ([]types.VoucherRedemption, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	var redemptions []types.VoucherRedemption
+	for _, redemption := range s.redemptions {
+		if redemption.AccountID == accountID {
+			redemptions = append(redemptions, *redemption)
+		}
+	}
+	return redemptions, nil
+}
+
+func (s *Service) usableVoucher(accountID int64, code string, kind types.VoucherKind) (*types.Voucher, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	voucher, err := s.FindVoucherByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if voucher.Kind != kind {
+		return nil, ErrVoucherNotApplicable
+	}
+	if voucher.Expires != 0 && s.now().Unix() >= voucher.Expires {
+		return nil, ErrVoucherExpired
+	}
+	if voucher.MaxUses > 0 && voucher.Uses >= voucher.MaxUses {
+		return nil, ErrVoucherUsedUp
+	}
+	for _, redemption := range s.redemptions {
+		if redemption.Code == voucher.Code && redemption.AccountID == accountID {
+			return nil, ErrVoucherAlreadyRedeemed
+		}
+	}
+	return voucher, nil
+}
+
+func (s *Service) redeem(voucher *types.Voucher, accountID int64, paymentID string, amount types.Money) {
+	voucher.Uses++
+	s.redemptions = append(s.redemptions, &types.VoucherRedemption{
+		Code:      voucher.Code,
+		AccountID: accountID,
+		PaymentID: paymentID,
+		Amount:    amount,
+		Created:   s.now().Unix(),
+	})
+}
+
+//releaseVoucher возвращает ваучер, использованный при оплате отклоненного платежа
+func (s *Service) releaseVoucher(payment *types.Payment) {
+	for i, redemption := range s.redemptions {
+		if redemption.PaymentID != payment.ID {
+			continue
+		}
+		voucher, err := s.FindVoucherByCode(redemption.Code)
+		if err == nil && voucher.Uses > 0 {
+			voucher.Uses--
+		}
+		s.redemptions = append(s.redemptions[:i], s.redemptions[i+1:]...)
+		return
+	}
+}
+
+func containsCategory(categories []types.PaymentCategory, category types.PaymentCategory) bool {
+	for _, current := range categories {
+		if current == category {
+			return true
+		}
+	}
+	return false
+}
+
+func generateVoucherCode() (string, error) {
+	code := make([]byte, 10)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(voucherAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = voucherAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+func (s *Service) voucherLines() []string {
+	var lines []string
+	for _, voucher := range s.vouchers {
+		lines = append(lines, voucher.ToString())
+	}
+	return lines
+}
+
+func (s *Service) redemptionLines() []string {
+	var lines []string
+	for _, redemption := range s.redemptions {
+		lines = append(lines, redemption.ToString())
+	}
+	return lines
+}
+
+func (s *Service) importVoucher(voucherStr []string) {
+	if len(voucherStr) < 9 {
+		return
+	}
+	Value, _ := strconv.ParseInt(voucherStr[2], 10, 64)
+	Percent, _ := strconv.ParseFloat(voucherStr[3], 64)
+	MaxUses, _ := strconv.Atoi(voucherStr[4])
+	Uses, _ := strconv.Atoi(voucherStr[5])
+	Expires, _ := strconv.ParseInt(voucherStr[6], 10, 64)
+	Created, _ := strconv.ParseInt(voucherStr[8], 10, 64)
+	var Categories []types.PaymentCategory
+	if voucherStr[7] != "" {
+		for _, category := range strings.Split(voucherStr[7], ",") {
+			Categories = append(Categories, types.PaymentCategory(category))
+		}
+	}
+	voucher, err := s.FindVoucherByCode(voucherStr[0])
+	if err != nil {
+		voucher = &types.Voucher{Code: voucherStr[0]}
+		s.vouchers = append(s.vouchers, voucher)
+	}
+	voucher.Kind = types.VoucherKind(voucherStr[1])
+	voucher.Value = types.Money(Value)
+	voucher.Percent = Percent
+	voucher.MaxUses = MaxUses
+	voucher.Uses = Uses
+	voucher.Expires = Expires
+	voucher.Categories = Categories
+	voucher.Created = Created
+}
+
+func (s *Service) importRedemption(redemptionStr []string) {
+	if len(redemptionStr) < 5 {
+		return
+	}
+	AccountID, _ := strconv.ParseInt(redemptionStr[1], 10, 64)
+	Amount, _ := strconv.ParseInt(redemptionStr[3], 10, 64)
+	Created, _ := strconv.ParseInt(redemptionStr[4], 10, 64)
+	for _, redemption := range s.redemptions {
+		if redemption.Code == redemptionStr[0] && redemption.AccountID == AccountID {
+			return
+		}
+	}
+	s.redemptions = append(s.redemptions, &types.VoucherRedemption{
+		Code:      redemptionStr[0],
+		AccountID: AccountID,
+		PaymentID: redemptionStr[2],
+		Amount:    types.Money(Amount),
+		Created:   Created,
+	})
+}
@@ -0,0 +1,209 @@
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SonnLarissa/wallet/pkg/types"
+)
+
+func TestService_GenerateVoucher(t *testing.T) {
+	s := newTestService()
+	voucher, err := s.GenerateVoucher(types.Voucher{Kind: types.VoucherCredit, Value: 50_00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(voucher.Code) != 10 {
+		t.Errorf("GenerateVoucher(): code = %v", voucher.Code)
+	}
+
+	_, err = s.GenerateVoucher(types.Voucher{Code: "spring", Kind: types.VoucherDiscount, Percent: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.FindVoucherByCode("Spring"); err != nil {
+		t.Errorf("FindVoucherByCode(): codes must be case insensitive, err = %v", err)
+	}
+	_, err = s.GenerateVoucher(types.Voucher{Code: "SPRING", Kind: types.VoucherCredit, Value: 1_00})
+	if err != ErrVoucherExists {
+		t.Errorf("GenerateVoucher(): must return ErrVoucherExists, returned = %v", err)
+	}
+
+	invalid := []types.Voucher{
+		{Kind: "GIFT", Value: 1_00},
+		{Kind: types.VoucherCredit},
+		{Kind: types.VoucherDiscount, Value: 1_00, Percent: 5},
+		{Kind: types.VoucherDiscount, Percent: 150},
+		{Code: "A;B", Kind: types.VoucherCredit, Value: 1_00},
+	}
+	for _, voucher := range invalid {
+		if _, err := s.GenerateVoucher(voucher); err != ErrInvalidVoucher {
+			t.Errorf("GenerateVoucher(%+v): must return ErrInvalidVoucher, returned = %v", voucher, err)
+		}
+	}
+}
+
+func TestService_RedeemVoucher(t *testing.T) {
+	s := newTestService()
+	now := time.Date(2021, 3, 10, 10, 0, 0, 0, time.UTC)
+	s.SetClock(func() time.Time { return now })
+	first, _ := s.RegisterAccount("+992928885522")
+	second, _ := s.RegisterAccount("+992928885533")
+	third, _ := s.RegisterAccount("+992928885544")
+	voucher, _ := s.GenerateVoucher(types.Voucher{
+		Code:    "WELCOME",
+		Kind:    types.VoucherCredit,
+		Value:   50_00,
+		MaxUses: 2,
+		Expires: now.AddDate(0, 0, 7).Unix(),
+	})
+
+	amount, err := s.RedeemVoucher(first.ID, "welcome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount != 50_00 || first.Balance != 50_00 || voucher.Uses != 1 {
+		t.Errorf("RedeemVoucher(): amount = %v, balance = %v, uses = %v", amount, first.Balance, voucher.Uses)
+	}
+	if _, err := s.RedeemVoucher(first.ID, "WELCOME"); err != ErrVoucherAlreadyRedeemed {
+		t.Errorf("RedeemVoucher(): must return ErrVoucherAlreadyRedeemed, returned = %v", err)
+	}
+	_, _ = s.RedeemVoucher(second.ID, "WELCOME")
+	if _, err := s.RedeemVoucher(third.ID, "WELCOME"); err != ErrVoucherUsedUp {
+		t.Errorf("RedeemVoucher(): must return ErrVoucherUsedUp, returned = %v", err)
+	}
+
+	_, _ = s.GenerateVoucher(types.Voucher{Code: "LATE", Kind: types.VoucherCredit, Value: 10_00, Expires: now.Unix()})
+	if _, err := s.RedeemVoucher(third.ID, "LATE"); err != ErrVoucherExpired {
+		t.Errorf("RedeemVoucher(): must return ErrVoucherExpired, returned = %v", err)
+	}
+	_, _ = s.GenerateVoucher(types.Voucher{Code: "SALE", Kind: types.VoucherDiscount, Value: 10_00})
+	if _, err := s.RedeemVoucher(third.ID, "SALE"); err != ErrVoucherNotApplicable {
+		t.Errorf("RedeemVoucher(): must return ErrVoucherNotApplicable, returned = %v", err)
+	}
+	if _, err := s.RedeemVoucher(third.ID, "NONE"); err != ErrVoucherNotFound {
+		t.Errorf("RedeemVoucher(): must return ErrVoucherNotFound, returned = %v", err)
+	}
+}
+
+func TestService_PayWithVoucher(t *testing.T) {
+	s := newTestService()
+	account, err := s.addAccountWithBalance("+992928885522", 1_000_00)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = s.GenerateVoucher(types.Voucher{Code: "FOOD10", Kind: types.VoucherDiscount, Percent: 10, Categories: []types.PaymentCategory{"food"}})
+	_, _ = s.GenerateVoucher(types.Voucher{Code: "BIG", Kind: types.VoucherDiscount, Value: 500_00})
+
+	if _, err := s.PayWithVoucher(account.ID, 100_00, "auto", "FOOD10"); err != ErrVoucherNotApplicable {
+		t.Errorf("PayWithVoucher(): must return ErrVoucherNotApplicable, returned = %v", err)
+	}
+	payment, err := s.PayWithVoucher(account.ID, 100_00, "food", "FOOD10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Amount != 90_00 || account.Balance != 910_00 {
+		t.Errorf("PayWithVoucher(): payment = %v, balance = %v", payment, account.Balance)
+	}
+	if _, err := s.PayWithVoucher(account.ID, 100_00, "food", "FOOD10"); err != ErrVoucherAlreadyRedeemed {
+		t.Errorf("PayWithVoucher(): must return ErrVoucherAlreadyRedeemed, returned = %v", err)
+	}
+
+	payment, err = s.PayWithVoucher(account.ID, 2_000_00, "auto", "BIG")
+	if err != ErrNotEnoughBalance {
+		t.Fatalf("PayWithVoucher(): must return ErrNotEnoughBalance, returned = %v", err)
+	}
+	voucher, _ := s.FindVoucherByCode("BIG")
+	if voucher.Uses != 0 {
+		t.Errorf("PayWithVoucher(): failed payment must not use voucher, uses = %v", voucher.Uses)
+	}
+	if _, err := s.PayWithVoucher(account.ID, 0, "auto", "BIG"); err != ErrAmountMustBePositive {
+		t.Errorf("PayWithVoucher(): must return ErrAmountMustBePositive, returned = %v", err)
+	}
+	payment, _ = s.PayWithVoucher(account.ID, 600_00, "auto", "BIG")
+	if payment.Amount != 100_00 || account.Balance != 810_00 {
+		t.Errorf("PayWithVoucher(): payment = %v, balance = %v", payment, account.Balance)
+	}
+	redemptions, _ := s.AccountRedemptions(account.ID)
+	if len(redemptions) != 2 || redemptions[1].Amount != 500_00 || redemptions[1].PaymentID != payment.ID {
+		t.Errorf("AccountRedemptions(): %v", redemptions)
+	}
+}
+
+func TestService_PayWithVoucher_fullDiscount(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 1_000_00)
+	if _, err := s.GenerateVoucher(types.Voucher{Code: "FREE", Kind: types.VoucherDiscount, Percent: 100}); err != ErrInvalidVoucher {
+		t.Errorf("GenerateVoucher(): 100%% discount must return ErrInvalidVoucher, returned = %v", err)
+	}
+	_, _ = s.GenerateVoucher(types.Voucher{Code: "GIFT", Kind: types.VoucherDiscount, Value: 500_00})
+
+	//ваучер на сумму больше платежа оставляет минимальный платеж
+	payment, err := s.PayWithVoucher(account.ID, 300_00, "auto", "GIFT")
+	if err != nil {
+		t.Fatalf("PayWithVoucher(): error = %v", err)
+	}
+	redemptions, _ := s.AccountRedemptions(account.ID)
+	if payment.Amount != 1 || account.Balance != 999_99 || len(redemptions) != 1 || redemptions[0].Amount != 299_99 {
+		t.Errorf("PayWithVoucher(): payment = %v, balance = %v, redemptions = %v", payment, account.Balance, redemptions)
+	}
+}
+
+func TestService_PayWithVoucher_rejected(t *testing.T) {
+	s, account, _ := newClockedTestService(t, testNow, 10_000_00)
+	s.requireConfirmation(t, account, 1_000_00)
+	s.SetConfirmationPolicy(time.Minute, 1)
+	voucher, _ := s.GenerateVoucher(types.Voucher{Code: "ONCE", Kind: types.VoucherDiscount, Value: 100_00, MaxUses: 1})
+
+	payment, err := s.PayWithVoucher(account.ID, 2_000_00, "auto", "ONCE")
+	if err != nil || payment.Status != types.PaymentStatusPending {
+		t.Fatalf("PayWithVoucher(): payment = %v, error = %v", payment, err)
+	}
+	if _, err := s.PayWithVoucher(account.ID, 2_000_00, "auto", "ONCE"); err != ErrVoucherUsedUp {
+		t.Errorf("PayWithVoucher(): pending payment must hold voucher, returned = %v", err)
+	}
+	if err := s.ConfirmPayment(payment.ID, "wrong"); err != ErrTooManyAttempts {
+		t.Fatalf("ConfirmPayment(): must return ErrTooManyAttempts, returned = %v", err)
+	}
+	redemptions, _ := s.AccountRedemptions(account.ID)
+	if voucher.Uses != 0 || len(redemptions) != 0 {
+		t.Errorf("ConfirmPayment(): rejected payment must release voucher, uses = %v, redemptions = %v", voucher.Uses, redemptions)
+	}
+	payment, err = s.PayWithVoucher(account.ID, 500_00, "auto", "ONCE")
+	if err != nil || payment.Amount != 400_00 || voucher.Uses != 1 {
+		t.Errorf("PayWithVoucher(): payment = %v, uses = %v, error = %v", payment, voucher.Uses, err)
+	}
+}
+
+func TestService_Import_vouchers(t *testing.T) {
+	s := newTestService()
+	account, _ := s.RegisterAccount("+992928885522")
+	voucher, _ := s.GenerateVoucher(types.Voucher{Kind: types.VoucherDiscount, Percent: 5, Categories: []types.PaymentCategory{"food", "auto"}, MaxUses: 10})
+	_, _ = s.GenerateVoucher(types.Voucher{Code: "GIFT", Kind: types.VoucherCredit, Value: 20_00})
+	_, _ = s.RedeemVoucher(account.ID, "GIFT")
+
+	dir := t.TempDir()
+	err := s.Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := newTestService()
+	for i := 0; i < 2; i++ {
+		err = imported.Import(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, _ := imported.FindVoucherByCode(voucher.Code)
+	if !reflect.DeepEqual(got, voucher) {
+		t.Errorf("Import(): voucher = %+v, want %+v", got, voucher)
+	}
+	redemptions, _ := s.AccountRedemptions(account.ID)
+	gotRedemptions, _ := imported.AccountRedemptions(account.ID)
+	if !reflect.DeepEqual(gotRedemptions, redemptions) {
+		t.Errorf("Import(): redemptions = %v, want %v", gotRedemptions, redemptions)
+	}
+	if _, err := imported.RedeemVoucher(account.ID, "GIFT"); err != ErrVoucherAlreadyRedeemed {
+		t.Errorf("RedeemVoucher(): must return ErrVoucherAlreadyRedeemed after import, returned = %v", err)
+	}
+}